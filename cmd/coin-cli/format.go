@@ -0,0 +1,117 @@
+package main
+
+import (
+	"Coin/pkg/peer"
+	"Coin/pkg/pro"
+	"fmt"
+	"google.golang.org/protobuf/proto"
+	"io"
+	"text/tabwriter"
+	"time"
+)
+
+// printBlock prints a block as text.
+func printBlock(w io.Writer, m proto.Message) {
+	info := m.(*pro.BlockInfo)
+	h := info.Block.GetHeader()
+	fmt.Fprintf(w, "hash:          %v\n", info.Hash)
+	fmt.Fprintf(w, "height:        %v\n", info.Height)
+	fmt.Fprintf(w, "previous hash: %v\n", h.GetPreviousHash())
+	fmt.Fprintf(w, "timestamp:     %v\n", time.Unix(int64(h.GetTimestamp()), 0).UTC().Format(time.RFC3339))
+	fmt.Fprintf(w, "nonce:         %v\n", h.GetNonce())
+	fmt.Fprintf(w, "transactions:  %v\n", len(info.Block.GetTransactions()))
+	for i, tx := range info.Block.GetTransactions() {
+		var total uint32
+		for _, out := range tx.Outputs {
+			total += out.Amount
+		}
+		fmt.Fprintf(w, "  %v: %v inputs, %v outputs, %v coins\n", i, len(tx.Inputs), len(tx.Outputs), total)
+	}
+}
+
+// printPeers prints a list of peers as a table.
+func printPeers(w io.Writer, m proto.Message) {
+	tw := tabwriter.NewWriter(w, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(tw, "ADDRESS\tDIRECTION\tVERSION\tSERVICES\tAGENT\tHEIGHT\tRTT\tMISBEHAVIOR")
+	for _, p := range m.(*pro.PeerList).Peers {
+		direction := "outbound"
+		if p.Inbound {
+			direction = "inbound"
+		}
+		fmt.Fprintf(tw, "%v\t%v\t%v\t%v\t%v\t%v\t%v\t%v\n", p.Addr, direction, p.Version,
+			peer.Services(p.Services), p.UserAgent, p.BestHeight, time.Duration(p.RttNanos), p.Misbehavior)
+	}
+	tw.Flush()
+}
+
+// printNodeInfo prints what a node is as text.
+func printNodeInfo(w io.Writer, m proto.Message) {
+	info := m.(*pro.NodeInfo)
+	fmt.Fprintf(w, "address:    %v\n", info.Address)
+	fmt.Fprintf(w, "public key: %v\n", info.PublicKey)
+	fmt.Fprintf(w, "user agent: %v\n", info.UserAgent)
+	fmt.Fprintf(w, "protocol:   %v (accepts %v and up)\n", info.Version, info.MinVersion)
+	fmt.Fprintf(w, "services:   %v\n", peer.Services(info.Services))
+	fmt.Fprintf(w, "paused:     %v\n", info.Paused)
+	fmt.Fprintf(w, "peers:      %v inbound, %v outbound\n", info.Inbound, info.Outbound)
+}
+
+// printChainInfo prints the state of a chain as text.
+func printChainInfo(w io.Writer, m proto.Message) {
+	info := m.(*pro.ChainInfo)
+	fmt.Fprintf(w, "network:          %v\n", info.Network)
+	fmt.Fprintf(w, "height:           %v\n", info.Height)
+	fmt.Fprintf(w, "best hash:        %v\n", info.BestHash)
+	fmt.Fprintf(w, "genesis hash:     %v\n", info.GenesisHash)
+	fmt.Fprintf(w, "best peer height: %v\n", info.BestPeerHeight)
+}
+
+// printWalletInfo prints the state of a wallet as text.
+func printWalletInfo(w io.Writer, m proto.Message) {
+	info := m.(*pro.WalletInfo)
+	fmt.Fprintf(w, "public key:    %v\n", info.PublicKey)
+	fmt.Fprintf(w, "balance:       %v\n", info.Balance)
+	fmt.Fprintf(w, "chain balance: %v\n", info.ChainBalance)
+}
+
+// printBans prints a list of bans as a table.
+func printBans(w io.Writer, m proto.Message) {
+	tw := tabwriter.NewWriter(w, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(tw, "ADDRESS\tUNTIL\tREASON")
+	for _, b := range m.(*pro.BanList).Bans {
+		fmt.Fprintf(tw, "%v\t%v\t%v\n", b.Addr, time.Unix(b.Until, 0).Format(time.RFC3339), b.Reason)
+	}
+	tw.Flush()
+}
+
+// printMempool prints the transactions waiting to be mined as
+// a table.
+func printMempool(w io.Writer, m proto.Message) {
+	pool := m.(*pro.Mempool)
+	fmt.Fprintf(w, "%v of %v transactions, priority %v of %v needed to mine\n",
+		pool.Count, pool.Capacity, pool.TotalPriority, pool.PriorityLimit)
+	if len(pool.Entries) == 0 {
+		return
+	}
+	tw := tabwriter.NewWriter(w, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(tw, "HASH\tPRIORITY\tSIZE\tINPUTS\tOUTPUTS\tAMOUNT")
+	for _, e := range pool.Entries {
+		fmt.Fprintf(tw, "%v\t%v\t%v\t%v\t%v\t%v\n", e.Hash, e.Priority, e.Size, e.Inputs, e.Outputs, e.OutputAmount)
+	}
+	tw.Flush()
+}
+
+// printMiningInfo prints the state of a miner as text.
+func printMiningInfo(w io.Writer, m proto.Message) {
+	info := m.(*pro.MiningInfo)
+	if !info.HasMiner {
+		fmt.Fprintln(w, "node has no miner")
+		return
+	}
+	fmt.Fprintf(w, "active:       %v\n", info.Active)
+	fmt.Fprintf(w, "mining:       %v\n", info.Mining)
+	fmt.Fprintf(w, "chain length: %v\n", info.ChainLength)
+	fmt.Fprintf(w, "subsidy:      %v\n", info.Subsidy)
+	fmt.Fprintf(w, "target:       %v\n", info.DifficultyTarget)
+	fmt.Fprintf(w, "pool size:    %v\n", info.PoolSize)
+}
@@ -0,0 +1,368 @@
+// Command coin-cli queries and controls a running Coin node
+// through its Admin service.
+//
+// Usage:
+//
+//	coin-cli [flags] <command> [arguments]
+//
+// Commands:
+//
+//	getnodeinfo                          print what the node is and how it is connected
+//	getchaininfo                         print the state of the main chain
+//	getblockcount                        print the length of the main chain
+//	getblock <hash|height>               print a block of the main chain
+//	getbalance [public key]              print the balance of a key, by default the node's
+//	getwalletinfo                        print the state of the node's wallet
+//	send <public key> <amount> [fee]     send coins from the node's wallet
+//	listpeers                            list the node's peers
+//	addpeer <address>                    connect to a node
+//	disconnectpeer <address>             drop a peer
+//	banpeer <address> [duration] [reason] ban an address, by default for the node's ban duration
+//	unbanpeer <address>                  lift the ban on an address
+//	listbans                             list the banned addresses
+//	getmempool                           list the transactions waiting to be mined
+//	getmininginfo                        print the state of the miner
+//	startmining, stopmining              start or stop the node's miner
+//	pause, resume                        take the node off the network, or put it back
+//
+// Public keys are hex encoded. With -json, results are
+// printed as JSON instead of text.
+//
+// The node's Admin service is found from -network, unless
+// -rpc is given. Its token is taken from -token, the
+// COIN_ADMIN_TOKEN environment variable, or the admin.token
+// file that coind writes to its data directory, in that
+// order.
+package main
+
+import (
+	"Coin/pkg"
+	"Coin/pkg/network"
+	"Coin/pkg/pro"
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/encoding/protojson"
+	"google.golang.org/protobuf/proto"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// errUsage is returned for commands called with the wrong
+// arguments.
+var errUsage = errors.New("wrong arguments")
+
+// command is a command of the CLI.
+// args describes the arguments it takes.
+// run calls the Admin service, and returns the result.
+// text prints the result as text.
+type command struct {
+	args string
+	run  func(ctx context.Context, c pro.AdminClient, args []string) (proto.Message, error)
+	text func(w io.Writer, m proto.Message)
+}
+
+var commands = map[string]command{
+	"getnodeinfo": {
+		run: func(ctx context.Context, c pro.AdminClient, args []string) (proto.Message, error) {
+			if len(args) != 0 {
+				return nil, errUsage
+			}
+			return c.GetNodeInfo(ctx, &pro.Empty{})
+		},
+		text: printNodeInfo,
+	},
+	"getchaininfo": {
+		run: func(ctx context.Context, c pro.AdminClient, args []string) (proto.Message, error) {
+			if len(args) != 0 {
+				return nil, errUsage
+			}
+			return c.GetChainInfo(ctx, &pro.Empty{})
+		},
+		text: printChainInfo,
+	},
+	"getblockcount": {
+		run: func(ctx context.Context, c pro.AdminClient, args []string) (proto.Message, error) {
+			if len(args) != 0 {
+				return nil, errUsage
+			}
+			return c.GetBlockCount(ctx, &pro.Empty{})
+		},
+		text: func(w io.Writer, m proto.Message) {
+			fmt.Fprintln(w, m.(*pro.BlockCount).Count)
+		},
+	},
+	"getblock": {
+		args: "<hash|height>",
+		run: func(ctx context.Context, c pro.AdminClient, args []string) (proto.Message, error) {
+			if len(args) != 1 {
+				return nil, errUsage
+			}
+			req := &pro.GetBlockRequest{Hash: args[0]}
+			if h, err := strconv.ParseUint(args[0], 10, 32); err == nil {
+				req = &pro.GetBlockRequest{Height: uint32(h)}
+			}
+			return c.GetBlock(ctx, req)
+		},
+		text: printBlock,
+	},
+	"getbalance": {
+		args: "[public key]",
+		run: func(ctx context.Context, c pro.AdminClient, args []string) (proto.Message, error) {
+			if len(args) > 1 {
+				return nil, errUsage
+			}
+			req := &pro.BalanceRequest{}
+			if len(args) == 1 {
+				req.PublicKey = args[0]
+			}
+			return c.GetBalance(ctx, req)
+		},
+		text: func(w io.Writer, m proto.Message) {
+			fmt.Fprintln(w, m.(*pro.Balance).Amount)
+		},
+	},
+	"getwalletinfo": {
+		run: func(ctx context.Context, c pro.AdminClient, args []string) (proto.Message, error) {
+			if len(args) != 0 {
+				return nil, errUsage
+			}
+			return c.GetWalletInfo(ctx, &pro.Empty{})
+		},
+		text: printWalletInfo,
+	},
+	"send": {
+		args: "<public key> <amount> [fee]",
+		run: func(ctx context.Context, c pro.AdminClient, args []string) (proto.Message, error) {
+			if len(args) < 2 || len(args) > 3 {
+				return nil, errUsage
+			}
+			amount, err := strconv.ParseUint(args[1], 10, 32)
+			if err != nil {
+				return nil, fmt.Errorf("invalid amount %q", args[1])
+			}
+			var fee uint64
+			if len(args) == 3 {
+				if fee, err = strconv.ParseUint(args[2], 10, 32); err != nil {
+					return nil, fmt.Errorf("invalid fee %q", args[2])
+				}
+			}
+			return c.Send(ctx, &pro.SendRequest{PublicKey: args[0], Amount: uint32(amount), Fee: uint32(fee)})
+		},
+		text: func(w io.Writer, m proto.Message) {
+			fmt.Fprintln(w, m.(*pro.SendResponse).TransactionHash)
+		},
+	},
+	"listpeers": {
+		run: func(ctx context.Context, c pro.AdminClient, args []string) (proto.Message, error) {
+			if len(args) != 0 {
+				return nil, errUsage
+			}
+			return c.ListPeers(ctx, &pro.Empty{})
+		},
+		text: printPeers,
+	},
+	"addpeer": {
+		args: "<address>",
+		run: func(ctx context.Context, c pro.AdminClient, args []string) (proto.Message, error) {
+			if len(args) != 1 {
+				return nil, errUsage
+			}
+			return c.AddPeer(ctx, &pro.AddPeerRequest{Addr: args[0]})
+		},
+	},
+	"disconnectpeer": {
+		args: "<address>",
+		run: func(ctx context.Context, c pro.AdminClient, args []string) (proto.Message, error) {
+			if len(args) != 1 {
+				return nil, errUsage
+			}
+			return c.DisconnectPeer(ctx, &pro.PeerRequest{Addr: args[0]})
+		},
+	},
+	"banpeer": {
+		args: "<address> [duration] [reason]",
+		run: func(ctx context.Context, c pro.AdminClient, args []string) (proto.Message, error) {
+			if len(args) < 1 || len(args) > 3 {
+				return nil, errUsage
+			}
+			req := &pro.BanRequest{Addr: args[0]}
+			if len(args) > 1 {
+				d, err := time.ParseDuration(args[1])
+				if err != nil || d < 0 {
+					return nil, fmt.Errorf("invalid duration %q", args[1])
+				}
+				req.DurationSeconds = int64(d / time.Second)
+			}
+			if len(args) > 2 {
+				req.Reason = args[2]
+			}
+			return c.BanPeer(ctx, req)
+		},
+	},
+	"unbanpeer": {
+		args: "<address>",
+		run: func(ctx context.Context, c pro.AdminClient, args []string) (proto.Message, error) {
+			if len(args) != 1 {
+				return nil, errUsage
+			}
+			return c.UnbanPeer(ctx, &pro.PeerRequest{Addr: args[0]})
+		},
+		text: func(w io.Writer, m proto.Message) {
+			if !m.(*pro.UnbanResponse).WasBanned {
+				fmt.Fprintln(w, "address was not banned")
+			}
+		},
+	},
+	"listbans": {
+		run: func(ctx context.Context, c pro.AdminClient, args []string) (proto.Message, error) {
+			if len(args) != 0 {
+				return nil, errUsage
+			}
+			return c.ListBans(ctx, &pro.Empty{})
+		},
+		text: printBans,
+	},
+	"getmempool": {
+		run: func(ctx context.Context, c pro.AdminClient, args []string) (proto.Message, error) {
+			if len(args) != 0 {
+				return nil, errUsage
+			}
+			return c.GetMempool(ctx, &pro.Empty{})
+		},
+		text: printMempool,
+	},
+	"getmininginfo": {
+		run: func(ctx context.Context, c pro.AdminClient, args []string) (proto.Message, error) {
+			if len(args) != 0 {
+				return nil, errUsage
+			}
+			return c.GetMiningInfo(ctx, &pro.Empty{})
+		},
+		text: printMiningInfo,
+	},
+	"startmining": noArgs(pro.AdminClient.StartMining),
+	"stopmining":  noArgs(pro.AdminClient.StopMining),
+	"pause":       noArgs(pro.AdminClient.Pause),
+	"resume":      noArgs(pro.AdminClient.Resume),
+}
+
+// noArgs returns a command without arguments or output.
+func noArgs(rpc func(pro.AdminClient, context.Context, *pro.Empty, ...grpc.CallOption) (*pro.Empty, error)) command {
+	return command{
+		run: func(ctx context.Context, c pro.AdminClient, args []string) (proto.Message, error) {
+			if len(args) != 0 {
+				return nil, errUsage
+			}
+			return rpc(c, ctx, &pro.Empty{})
+		},
+	}
+}
+
+func main() {
+	os.Exit(run(os.Args[1:], os.Stdout, os.Stderr))
+}
+
+// run runs the command in args, and returns the exit code.
+func run(args []string, stdout io.Writer, stderr io.Writer) int {
+	fs := flag.NewFlagSet("coin-cli", flag.ContinueOnError)
+	fs.SetOutput(stderr)
+	netName := fs.String("network", network.Mainnet.Name, "network of the node, which decides where its Admin service is")
+	dataDir := fs.String("datadir", ".coin", "data directory of the node, where its admin token is")
+	addr := fs.String("rpc", "", "address of the node's Admin service, by default the network's")
+	token := fs.String("token", "", "token of the node's Admin service")
+	asJSON := fs.Bool("json", false, "print results as JSON")
+	timeout := fs.Duration("timeout", time.Minute, "how long to wait for the node")
+	fs.Usage = func() {
+		fmt.Fprintf(stderr, "usage: coin-cli [flags] <command> [arguments]\n\nflags:\n")
+		fs.PrintDefaults()
+		fmt.Fprintf(stderr, "\nrun \"go doc Coin/cmd/coin-cli\" for the commands\n")
+	}
+	if err := fs.Parse(args); err != nil {
+		if err == flag.ErrHelp {
+			return 0
+		}
+		return 2
+	}
+	if fs.NArg() == 0 {
+		fs.Usage()
+		return 2
+	}
+	name := fs.Arg(0)
+	cmd, ok := commands[name]
+	if !ok {
+		fmt.Fprintf(stderr, "coin-cli: unknown command %q\n", name)
+		return 2
+	}
+
+	p, err := network.Lookup(*netName)
+	if err != nil {
+		fmt.Fprintf(stderr, "coin-cli: %v\n", err)
+		return 2
+	}
+	if *addr == "" {
+		*addr = pkg.DefaultAdminAddr(p)
+	}
+	if *token == "" {
+		*token = adminToken(filepath.Join(*dataDir, p.Name, pkg.AdminTokenFile))
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), *timeout)
+	defer cancel()
+	if *token != "" {
+		ctx = metadata.AppendToOutgoingContext(ctx, "authorization", "Bearer "+*token)
+	}
+	conn, err := grpc.DialContext(ctx, *addr, grpc.WithInsecure(), grpc.WithBlock())
+	if err != nil {
+		fmt.Fprintf(stderr, "coin-cli: could not reach the node at %v: %v\n", *addr, err)
+		return 1
+	}
+	defer conn.Close()
+
+	res, err := cmd.run(ctx, pro.NewAdminClient(conn), fs.Args()[1:])
+	if err == errUsage {
+		fmt.Fprintf(stderr, "usage: coin-cli %v %v\n", name, cmd.args)
+		return 2
+	}
+	if err != nil {
+		if s, ok := status.FromError(err); ok {
+			err = errors.New(s.Message())
+		}
+		fmt.Fprintf(stderr, "coin-cli: %v: %v\n", name, err)
+		return 1
+	}
+	if *asJSON {
+		b, err := protojson.MarshalOptions{Multiline: true, EmitUnpopulated: true}.Marshal(res)
+		if err != nil {
+			fmt.Fprintf(stderr, "coin-cli: %v\n", err)
+			return 1
+		}
+		fmt.Fprintln(stdout, string(b))
+	} else if cmd.text != nil {
+		cmd.text(stdout, res)
+	}
+	return 0
+}
+
+// adminToken returns the admin token from the environment or,
+// failing that, from the token file at path. It returns an
+// empty token if there is neither.
+func adminToken(path string) string {
+	if token := os.Getenv("COIN_ADMIN_TOKEN"); token != "" {
+		return token
+	}
+	b, err := ioutil.ReadFile(path)
+	if err != nil {
+		return ""
+	}
+	return strings.TrimSpace(string(b))
+}
@@ -0,0 +1,206 @@
+// Command coind runs a Coin node.
+//
+// Settings come from, in increasing order of precedence, the
+// defaults of the chosen network, a JSON config file given by
+// -config, COIN_* environment variables and flags; run
+// "coind -h" for the full list. Relative database paths are
+// kept under -datadir, per network, along with the node's
+// identity and its PID file, which is locked while the node
+// runs. Unless -admin_token is given, a
+// fresh token for the Admin service is written to admin.token
+// there on every start, for coin-cli to read. Logs go to
+// stderr, as text or as JSON, at levels set per component
+// with -log_level and -log_levels. The node shuts down
+// cleanly on SIGINT or SIGTERM. The blockchain is synced from
+// peers on every start, since it cannot be reloaded from disk
+// yet, so coind refuses to start over the chain databases of
+// an earlier run unless -reset-chain is given, which clears
+// them first. It only clears databases inside -datadir.
+package main
+
+import (
+	"Coin/pkg"
+	"Coin/pkg/id"
+	"Coin/pkg/utils"
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"flag"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"strings"
+	"syscall"
+)
+
+func main() {
+	os.Exit(run(os.Args[1:]))
+}
+
+// run runs the node until it is signaled to stop, and returns
+// the exit code.
+func run(args []string) int {
+	fs := flag.NewFlagSet("coind", flag.ContinueOnError)
+	dataDir := fs.String("datadir", ".coin", "directory to keep the node's data in")
+	pidFile := fs.String("pidfile", "coind.pid", "PID file that keeps a second node off the data directory, relative to the network's data directory")
+	connect := fs.String("connect", "", "comma separated addresses to connect to on start")
+	debug := fs.Bool("debug", false, "log at debug level, the same as -log_level debug")
+	resetChain := fs.Bool("reset-chain", false, "clear the chain databases of an earlier run before starting")
+	conf, err := pkg.LoadConfig(fs, args)
+	if err == flag.ErrHelp {
+		return 0
+	}
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "coind: %v\n", err)
+		return 2
+	}
+	if *debug {
+		conf.LogConfig.Level = utils.LevelDebug
+	}
+	utils.ConfigureLogging(conf.LogConfig)
+	log := utils.NewLogger(utils.ComponentNode)
+
+	dir := filepath.Join(*dataDir, conf.Network.Name)
+	if err := os.MkdirAll(dir, 0700); err != nil {
+		log.Error("could not create data directory", "dir", dir, "err", err)
+		return 1
+	}
+	inDataDir(dir, &conf.ChainConfig.BlockInfoDBPath, &conf.ChainConfig.ChainWriterDBPath,
+		&conf.ChainConfig.CoinDBPath, &conf.PeerConfig.BanListPath, &conf.AddressDBPath, pidFile)
+
+	unlock, err := lockPIDFile(*pidFile)
+	if err != nil {
+		log.Error("could not lock PID file", "err", err)
+		return 1
+	}
+	defer unlock()
+
+	// the chain cannot be reloaded from disk yet, so it is
+	// synced from peers on every start, and the databases of
+	// an earlier run are only cleared when asked to. The
+	// identity, known addresses and bans are kept. Only
+	// databases inside the data directory are cleared, so
+	// that a mistyped path cannot delete anything else.
+	for _, p := range []string{conf.ChainConfig.BlockInfoDBPath, conf.ChainConfig.ChainWriterDBPath, conf.ChainConfig.CoinDBPath} {
+		if p == "" {
+			continue
+		}
+		if !*resetChain {
+			used, err := hasData(p)
+			if err != nil {
+				log.Error("could not check database", "path", p, "err", err)
+				return 1
+			}
+			if used {
+				log.Error("chain database holds data of an earlier run, which cannot be reloaded; start with -reset-chain to clear it",
+					"path", p)
+				return 1
+			}
+			continue
+		}
+		if !within(dir, p) {
+			log.Error("chain databases are only cleared inside the data directory", "path", p, "datadir", dir)
+			return 1
+		}
+		if err := os.RemoveAll(p); err != nil {
+			log.Error("could not clear database", "path", p, "err", err)
+			return 1
+		}
+	}
+
+	if conf.AdminAddr != "" && conf.AdminToken == "" {
+		path := filepath.Join(dir, pkg.AdminTokenFile)
+		if conf.AdminToken, err = writeAdminToken(path); err != nil {
+			log.Error("could not write admin token", "path", path, "err", err)
+			return 1
+		}
+		defer os.Remove(path)
+	}
+
+	conf.CustomID, err = id.LoadOrCreate(filepath.Join(dir, "identity.pem"))
+	if err != nil {
+		log.Error("could not load identity", "err", err)
+		return 1
+	}
+	conf.HasCustomId = true
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+	n := pkg.New(conf)
+	if err := n.StartContext(ctx); err != nil {
+		log.Error("could not start node", "err", err)
+		n.Kill()
+		return 1
+	}
+	log = log.With("node", n.Address)
+	for _, addr := range strings.Split(*connect, ",") {
+		if addr = strings.TrimSpace(addr); addr != "" && !n.ConnectToPeer(addr) {
+			log.Warn("could not connect", "peer", addr)
+		}
+	}
+	if conf.MinerConfig.HasMiner {
+		n.StartMiner()
+	}
+
+	<-ctx.Done()
+	log.Info("shutting down")
+	<-n.Done()
+	log.Info("node stopped")
+	return 0
+}
+
+// inDataDir moves relative paths into the data directory.
+// Empty paths, which keep data in memory, and absolute
+// paths are left alone.
+func inDataDir(dir string, paths ...*string) {
+	for _, p := range paths {
+		if *p != "" && !filepath.IsAbs(*p) {
+			*p = filepath.Join(dir, *p)
+		}
+	}
+}
+
+// within returns whether path is inside the directory dir,
+// and not dir itself.
+func within(dir string, path string) bool {
+	dir, err := filepath.Abs(dir)
+	if err != nil {
+		return false
+	}
+	path, err = filepath.Abs(path)
+	if err != nil {
+		return false
+	}
+	rel, err := filepath.Rel(dir, path)
+	return err == nil && rel != "." && rel != ".." && !strings.HasPrefix(rel, ".."+string(filepath.Separator))
+}
+
+// hasData returns whether anything is stored at path, which
+// is either a file or a directory with entries.
+func hasData(path string) (bool, error) {
+	info, err := os.Stat(path)
+	if os.IsNotExist(err) {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+	if !info.IsDir() {
+		return true, nil
+	}
+	entries, err := os.ReadDir(path)
+	return len(entries) > 0, err
+}
+
+// writeAdminToken makes a random admin token and writes it to
+// path, readable only by its owner.
+func writeAdminToken(path string) (string, error) {
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	token := hex.EncodeToString(b)
+	return token, ioutil.WriteFile(path, []byte(token+"\n"), 0600)
+}
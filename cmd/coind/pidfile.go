@@ -0,0 +1,65 @@
+package main
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"strconv"
+	"strings"
+	"syscall"
+)
+
+// lockPIDFile takes an exclusive lock on the PID file at path
+// and writes our PID to it, unless another running process
+// holds the lock. The lock goes away with the process that
+// holds it, so a PID file left behind by a process that is
+// gone is simply taken over. The file itself is never
+// removed, so that every process locks the same file.
+// Returns:
+// func() empties the PID file and releases the lock
+// error if the file is held or could not be written
+func lockPIDFile(path string) (func(), error) {
+	f, err := os.OpenFile(path, os.O_RDWR|os.O_CREATE, 0644)
+	if err != nil {
+		return nil, err
+	}
+	if err := syscall.Flock(int(f.Fd()), syscall.LOCK_EX|syscall.LOCK_NB); err != nil {
+		f.Close()
+		if err == syscall.EWOULDBLOCK {
+			return nil, fmt.Errorf("another node (PID %v) is using %v", pidIn(path), path)
+		}
+		return nil, err
+	}
+	if err := writePID(f); err != nil {
+		f.Close()
+		return nil, err
+	}
+	return func() {
+		_ = f.Truncate(0)
+		f.Close()
+	}, nil
+}
+
+// writePID replaces the contents of a PID file with our PID.
+func writePID(f *os.File) error {
+	if err := f.Truncate(0); err != nil {
+		return err
+	}
+	if _, err := f.WriteAt([]byte(fmt.Sprintf("%d\n", os.Getpid())), 0); err != nil {
+		return err
+	}
+	return f.Sync()
+}
+
+// pidIn returns the PID in a PID file, or 0 if it holds none.
+func pidIn(path string) int {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return 0
+	}
+	pid, err := strconv.Atoi(strings.TrimSpace(string(data)))
+	if err != nil || pid <= 0 {
+		return 0
+	}
+	return pid
+}
@@ -5,16 +5,57 @@ import (
 	"time"
 )
 
+// Address is a known address of a node on the network.
+// Addr is the address itself (host:port).
+// LastSeen is when the node was last heard from, in unix
+// seconds.
+// SentVer is when we last sent the node a version request.
+// PublicKey is the hex encoded public key that the node
+// proved it holds. Once set, the address is pinned to it.
+// Attempts is how many times we tried to connect to the node.
+// Successes is how many of those attempts succeeded.
+// LastAttempt is when we last tried to connect to the node.
+// LastSuccess is when we last connected to the node.
+// Source is the address of the node that told us about
+// this one. It is empty if we learned it ourselves.
+// Conns is the connection manager used for RPCs to the
+// node. If it is nil, a shared default manager is used.
 type Address struct {
-	Addr     string
-	LastSeen uint32
-	SentVer  time.Time
+	Addr      string
+	LastSeen  uint32
+	SentVer   time.Time
+	PublicKey string
+	Conns     *ConnectionManager
+
+	Attempts    uint32
+	Successes   uint32
+	LastAttempt time.Time
+	LastSuccess time.Time
+	Source      string
 }
 
 func New(addr string, lastSeen uint32) *Address {
 	return &Address{Addr: addr, LastSeen: lastSeen, SentVer: time.Time{}}
 }
 
+// Copy returns a copy of the address, which the caller may
+// change without affecting the original.
+func (a *Address) Copy() *Address {
+	c := *a
+	return &c
+}
+
+// RecordAttempt records an attempt to connect to the node.
+func (a *Address) RecordAttempt(success bool) {
+	now := time.Now()
+	a.Attempts++
+	a.LastAttempt = now
+	if success {
+		a.Successes++
+		a.LastSuccess = now
+	}
+}
+
 func (a *Address) Serialize() *pro.Address {
 	return &pro.Address{Addr: a.Addr, LastSeen: a.LastSeen}
 }
@@ -3,16 +3,38 @@ package addressdb
 import (
 	"Coin/pkg/address"
 	"Coin/pkg/pro"
+	"Coin/pkg/utils"
 )
 
+// logger logs for address databases.
+var logger = utils.NewLogger(utils.ComponentP2P)
+
 type AddressDb interface {
 	Add(*address.Address) error
 	Get(string) *address.Address
 	UpdateLastSeen(string, uint32) error
+	RecordAttempt(string, bool) error
+	SetPublicKey(string, string) error
+	Remove(string) error
+	Select([]string) *address.Address
 	List() []*address.Address
 	Serialize() []*pro.Address
+	Close()
 }
 
-func New(eph bool, limit int) AddressDb {
-	return &EphemeralAddressDb{addresses: make(map[string]*address.Address), limit: limit}
+// New returns an AddrManager that can hold up to limit
+// addresses. If eph is true, the addresses are only kept in
+// memory. Otherwise, they are persisted to the database at
+// path and loaded from it again on the next start.
+func New(eph bool, limit int, path string) AddressDb {
+	var store AddressDb = NewEphemeralAddressDb(limit)
+	if !eph {
+		adb, err := NewPersistentAddressDb(path, limit)
+		if err == nil {
+			store = adb
+		} else {
+			logger.Error("unable to open address database, keeping addresses in memory", "path", path, "err", err)
+		}
+	}
+	return NewAddrManager(limit, store)
 }
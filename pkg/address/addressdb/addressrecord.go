@@ -0,0 +1,50 @@
+package addressdb
+
+import (
+	"Coin/pkg/address"
+	"Coin/pkg/pro"
+	"time"
+)
+
+// EncodeAddressRecord returns a pro.AddressRecord given an Address.
+func EncodeAddressRecord(a *address.Address) *pro.AddressRecord {
+	return &pro.AddressRecord{
+		Addr:        a.Addr,
+		LastSeen:    a.LastSeen,
+		Attempts:    a.Attempts,
+		Successes:   a.Successes,
+		LastAttempt: unixOrZero(a.LastAttempt),
+		LastSuccess: unixOrZero(a.LastSuccess),
+		PublicKey:   a.PublicKey,
+		Source:      a.Source,
+	}
+}
+
+// DecodeAddressRecord returns an Address given a pro.AddressRecord.
+func DecodeAddressRecord(par *pro.AddressRecord) *address.Address {
+	a := address.New(par.GetAddr(), par.GetLastSeen())
+	a.Attempts = par.GetAttempts()
+	a.Successes = par.GetSuccesses()
+	a.LastAttempt = timeOrZero(par.GetLastAttempt())
+	a.LastSuccess = timeOrZero(par.GetLastSuccess())
+	a.PublicKey = par.GetPublicKey()
+	a.Source = par.GetSource()
+	return a
+}
+
+// unixOrZero returns the unix time of t, or 0 if t is the
+// zero time.
+func unixOrZero(t time.Time) int64 {
+	if t.IsZero() {
+		return 0
+	}
+	return t.Unix()
+}
+
+// timeOrZero is the inverse of unixOrZero.
+func timeOrZero(sec int64) time.Time {
+	if sec == 0 {
+		return time.Time{}
+	}
+	return time.Unix(sec, 0)
+}
@@ -0,0 +1,475 @@
+package addressdb
+
+import (
+	"Coin/pkg/address"
+	"Coin/pkg/pro"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	mrand "math/rand"
+	"net"
+	"sync"
+	"time"
+)
+
+const (
+	// NewBucketCount is the number of buckets in the new table.
+	NewBucketCount = 64
+	// TriedBucketCount is the number of buckets in the tried table.
+	TriedBucketCount = 16
+	// BucketSize is the number of addresses a bucket can hold.
+	BucketSize = 16
+	// NewBucketsPerSourceGroup is the number of new buckets that
+	// addresses learned from a single network group can land in.
+	NewBucketsPerSourceGroup = 8
+	// TriedBucketsPerGroup is the number of tried buckets that
+	// addresses from a single network group can land in.
+	TriedBucketsPerGroup = 4
+	// TriedBias is the probability that Select picks from the
+	// tried table when both tables have addresses.
+	TriedBias = 0.7
+	// MaxNewFailures is the number of failed connection attempts
+	// after which an address we never connected to is stale.
+	MaxNewFailures = 3
+	// MaxFailures is the number of consecutive failed connection
+	// attempts after which any address is stale.
+	MaxFailures = 10
+	// MaxSelectTries is how many candidates Select considers
+	// before it gives up.
+	MaxSelectTries = 100
+	// StaleHorizon is how long an address may go unseen
+	// before it is stale.
+	StaleHorizon = 30 * 24 * time.Hour
+)
+
+// knownAddress is an address in the AddrManager's tables. The
+// address is the manager's own copy of the one in the store,
+// which is refreshed whenever the store changes it.
+// tried is whether the address is in the tried table.
+// bucket is the index of the bucket the address is in.
+// added is when the address was added to the manager.
+// failures is the number of connection attempts that failed
+// since the last one that succeeded.
+type knownAddress struct {
+	*address.Address
+	tried    bool
+	bucket   int
+	added    time.Time
+	failures uint32
+}
+
+// AddrManager is an AddressDb that keeps addresses in two
+// tables, in the style of Bitcoin's address manager. Addresses
+// we have only heard about go into the "new" table, and move to
+// the "tried" table once we connect to them successfully.
+// Which bucket an address lands in depends on a secret key, the
+// address's network group and, for new addresses, the network
+// group of the node that told us about it. This limits how much
+// of the tables a single attacker can fill.
+// key is the secret used to pick buckets.
+// newTable and triedTable are the buckets of the two tables.
+// index maps an address to its entry in the tables.
+// limit is the maximum number of addresses in both tables.
+// store is where the addresses are kept and, if it is
+// persistent, saved to.
+// mutex guards the tables. Get, Select and List return copies
+// of the addresses, so that they are only changed under it.
+type AddrManager struct {
+	key        []byte
+	newTable   [NewBucketCount]map[string]*knownAddress
+	triedTable [TriedBucketCount]map[string]*knownAddress
+	index      map[string]*knownAddress
+	limit      int
+	store      AddressDb
+
+	mutex sync.Mutex
+}
+
+// NewAddrManager returns an AddrManager that holds up to limit
+// addresses and keeps them in store. The addresses already in
+// store are sorted into the tables: those we connected to
+// before go into the tried table, the rest into the new table.
+func NewAddrManager(limit int, store AddressDb) *AddrManager {
+	am := &AddrManager{
+		key:   make([]byte, 32),
+		index: make(map[string]*knownAddress),
+		limit: limit,
+		store: store,
+	}
+	if _, err := rand.Read(am.key); err != nil {
+		panic(err)
+	}
+	for i := range am.newTable {
+		am.newTable[i] = make(map[string]*knownAddress)
+	}
+	for i := range am.triedTable {
+		am.triedTable[i] = make(map[string]*knownAddress)
+	}
+	for _, a := range store.List() {
+		ka := &knownAddress{Address: a, added: time.Now()}
+		if a.Successes > 0 {
+			am.placeTried(ka)
+		} else {
+			am.placeNew(ka)
+		}
+	}
+	return am
+}
+
+// Add adds an address to the new table. The bucket it goes
+// into is picked using the address's Source. If the bucket is
+// full, a stale address is evicted from it. If none is stale,
+// the address is not added, so that addresses that are still
+// good cannot be pushed out by a flood of new ones.
+func (am *AddrManager) Add(a *address.Address) error {
+	am.mutex.Lock()
+	defer am.mutex.Unlock()
+	if am.index[a.Addr] != nil {
+		return errors.New("address already exists")
+	}
+	ka := &knownAddress{Address: a.Copy(), added: time.Now()}
+	bucket := am.newBucket(a.Addr, a.Source)
+	if len(am.newTable[bucket]) >= BucketSize || len(am.index) >= am.limit {
+		if !am.evictFrom(am.newTable[bucket]) {
+			return errors.New("address list full")
+		}
+	}
+	if err := am.store.Add(a); err != nil {
+		return err
+	}
+	ka.bucket = bucket
+	am.newTable[bucket][a.Addr] = ka
+	am.index[a.Addr] = ka
+	return nil
+}
+
+func (am *AddrManager) Get(addr string) *address.Address {
+	am.mutex.Lock()
+	defer am.mutex.Unlock()
+	if ka := am.index[addr]; ka != nil {
+		return ka.Copy()
+	}
+	return nil
+}
+
+func (am *AddrManager) UpdateLastSeen(addr string, lastSeen uint32) error {
+	am.mutex.Lock()
+	defer am.mutex.Unlock()
+	if err := am.store.UpdateLastSeen(addr, lastSeen); err != nil {
+		return err
+	}
+	am.refresh(addr)
+	return nil
+}
+
+// RecordAttempt records a connection attempt. A successful
+// attempt moves the address into the tried table. If the
+// tried bucket is full, a stale address in it is evicted to
+// make room. If none is stale, the address stays in the new
+// table.
+func (am *AddrManager) RecordAttempt(addr string, success bool) error {
+	am.mutex.Lock()
+	defer am.mutex.Unlock()
+	ka := am.index[addr]
+	if ka == nil {
+		return errors.New("address not found")
+	}
+	if err := am.store.RecordAttempt(addr, success); err != nil {
+		return err
+	}
+	am.refresh(addr)
+	if !success {
+		ka.failures++
+		return nil
+	}
+	ka.failures = 0
+	if ka.tried {
+		return nil
+	}
+	delete(am.newTable[ka.bucket], addr)
+	bucket := am.triedBucket(addr)
+	if len(am.triedTable[bucket]) >= BucketSize {
+		stale := staleIn(am.triedTable[bucket])
+		if stale == nil {
+			am.newTable[ka.bucket][addr] = ka
+			return nil
+		}
+		am.remove(stale)
+	}
+	ka.tried = true
+	ka.bucket = bucket
+	am.triedTable[bucket][addr] = ka
+	return nil
+}
+
+func (am *AddrManager) SetPublicKey(addr string, pk string) error {
+	am.mutex.Lock()
+	defer am.mutex.Unlock()
+	if err := am.store.SetPublicKey(addr, pk); err != nil {
+		return err
+	}
+	am.refresh(addr)
+	return nil
+}
+
+// Remove forgets an address.
+func (am *AddrManager) Remove(addr string) error {
+	am.mutex.Lock()
+	defer am.mutex.Unlock()
+	ka := am.index[addr]
+	if ka == nil {
+		return errors.New("address not found")
+	}
+	am.remove(ka)
+	return nil
+}
+
+func (am *AddrManager) List() []*address.Address {
+	am.mutex.Lock()
+	defer am.mutex.Unlock()
+	addresses := make([]*address.Address, 0, len(am.index))
+	for _, ka := range am.index {
+		addresses = append(addresses, ka.Copy())
+	}
+	return addresses
+}
+
+// Serialize returns the addresses that are worth sharing with
+// other nodes, which leaves out stale addresses.
+func (am *AddrManager) Serialize() []*pro.Address {
+	am.mutex.Lock()
+	defer am.mutex.Unlock()
+	addresses := make([]*pro.Address, 0, len(am.index))
+	for _, ka := range am.index {
+		if !ka.isStale() {
+			addresses = append(addresses, ka.Serialize())
+		}
+	}
+	return addresses
+}
+
+// Select picks a random address to connect to, favoring the
+// tried table and addresses whose recent connection attempts
+// did not fail. It returns nil if there is no candidate.
+// Inputs:
+// exclude []string addresses that must not be picked
+func (am *AddrManager) Select(exclude []string) *address.Address {
+	am.mutex.Lock()
+	defer am.mutex.Unlock()
+	excluded := make(map[string]bool)
+	for _, addr := range exclude {
+		excluded[addr] = true
+	}
+	tried := make([]*knownAddress, 0)
+	fresh := make([]*knownAddress, 0)
+	for addr, ka := range am.index {
+		if excluded[addr] {
+			continue
+		}
+		if ka.tried {
+			tried = append(tried, ka)
+		} else {
+			fresh = append(fresh, ka)
+		}
+	}
+	for i := 0; i < MaxSelectTries && len(tried)+len(fresh) > 0; i++ {
+		candidates := fresh
+		if len(fresh) == 0 || (len(tried) > 0 && mrand.Float64() < TriedBias) {
+			candidates = tried
+		}
+		ka := candidates[mrand.Intn(len(candidates))]
+		if mrand.Float64() < ka.chance() {
+			return ka.Copy()
+		}
+	}
+	return nil
+}
+
+// EvictStale removes every stale address from the tables.
+func (am *AddrManager) EvictStale() {
+	am.mutex.Lock()
+	defer am.mutex.Unlock()
+	for _, ka := range am.index {
+		if ka.isStale() {
+			am.remove(ka)
+		}
+	}
+}
+
+func (am *AddrManager) Close() {
+	am.store.Close()
+}
+
+// NewCount returns the number of addresses in the new table.
+func (am *AddrManager) NewCount() int {
+	am.mutex.Lock()
+	defer am.mutex.Unlock()
+	count := 0
+	for _, b := range am.newTable {
+		count += len(b)
+	}
+	return count
+}
+
+// TriedCount returns the number of addresses in the tried table.
+func (am *AddrManager) TriedCount() int {
+	am.mutex.Lock()
+	defer am.mutex.Unlock()
+	count := 0
+	for _, b := range am.triedTable {
+		count += len(b)
+	}
+	return count
+}
+
+// refresh copies an address from the store into its entry in
+// the tables, after the store changed it.
+func (am *AddrManager) refresh(addr string) {
+	ka := am.index[addr]
+	if ka == nil {
+		return
+	}
+	if a := am.store.Get(addr); a != nil {
+		ka.Address = a
+	}
+}
+
+// placeNew puts an address that is already in the store into
+// its new bucket. If there is no room, the address is dropped
+// from the store.
+func (am *AddrManager) placeNew(ka *knownAddress) {
+	bucket := am.newBucket(ka.Addr, ka.Source)
+	if len(am.newTable[bucket]) >= BucketSize || len(am.index) >= am.limit {
+		_ = am.store.Remove(ka.Addr)
+		return
+	}
+	ka.tried = false
+	ka.bucket = bucket
+	am.newTable[bucket][ka.Addr] = ka
+	am.index[ka.Addr] = ka
+}
+
+// placeTried puts an address that is already in the store into
+// its tried bucket, falling back to the new table if the
+// bucket is full.
+func (am *AddrManager) placeTried(ka *knownAddress) {
+	bucket := am.triedBucket(ka.Addr)
+	if len(am.triedTable[bucket]) >= BucketSize || len(am.index) >= am.limit {
+		am.placeNew(ka)
+		return
+	}
+	ka.tried = true
+	ka.bucket = bucket
+	am.triedTable[bucket][ka.Addr] = ka
+	am.index[ka.Addr] = ka
+}
+
+// evictFrom makes room in a bucket by removing a stale address
+// from it. It returns false if no address in the bucket is
+// stale.
+func (am *AddrManager) evictFrom(bucket map[string]*knownAddress) bool {
+	stale := staleIn(bucket)
+	if stale == nil {
+		return false
+	}
+	am.remove(stale)
+	return true
+}
+
+// remove takes an address out of the tables and the store.
+func (am *AddrManager) remove(ka *knownAddress) {
+	if ka.tried {
+		delete(am.triedTable[ka.bucket], ka.Addr)
+	} else {
+		delete(am.newTable[ka.bucket], ka.Addr)
+	}
+	delete(am.index, ka.Addr)
+	_ = am.store.Remove(ka.Addr)
+}
+
+// newBucket returns the new bucket for an address learned from
+// source. Addresses from one source group can only land in
+// NewBucketsPerSourceGroup buckets. An address with no source
+// was announced by the node itself and is its own source.
+func (am *AddrManager) newBucket(addr string, source string) int {
+	if source == "" {
+		source = addr
+	}
+	sourceGroup := NetworkGroup(source)
+	h := am.hash(NetworkGroup(addr), sourceGroup) % NewBucketsPerSourceGroup
+	return int(am.hash(sourceGroup, fmt.Sprint(h)) % NewBucketCount)
+}
+
+// triedBucket returns the tried bucket for an address.
+// Addresses from one group can only land in
+// TriedBucketsPerGroup buckets.
+func (am *AddrManager) triedBucket(addr string) int {
+	h := am.hash(addr) % TriedBucketsPerGroup
+	return int(am.hash(NetworkGroup(addr), fmt.Sprint(h)) % TriedBucketCount)
+}
+
+// hash hashes the parts together with the manager's key.
+func (am *AddrManager) hash(parts ...string) uint64 {
+	h := sha256.New()
+	h.Write(am.key)
+	for _, p := range parts {
+		h.Write([]byte(p))
+		h.Write([]byte{0})
+	}
+	return binary.BigEndian.Uint64(h.Sum(nil))
+}
+
+// isStale returns whether an address is no longer worth
+// keeping or sharing: it has not been seen in a long time,
+// or too many connection attempts to it failed.
+func (ka *knownAddress) isStale() bool {
+	if ka.LastSeen != 0 && time.Since(time.Unix(int64(ka.LastSeen), 0)) > StaleHorizon {
+		return true
+	}
+	if ka.Successes == 0 {
+		return ka.failures >= MaxNewFailures
+	}
+	return ka.failures >= MaxFailures
+}
+
+// chance returns how likely Select is to accept an address,
+// which drops with each failed connection attempt.
+func (ka *knownAddress) chance() float64 {
+	c := 1.0
+	for i := uint32(0); i < ka.failures && i < 8; i++ {
+		c *= 0.66
+	}
+	return c
+}
+
+// staleIn returns the stale address in a bucket that was added
+// the longest time ago, or nil if no address in it is stale.
+func staleIn(bucket map[string]*knownAddress) *knownAddress {
+	var oldest *knownAddress
+	for _, ka := range bucket {
+		if ka.isStale() && (oldest == nil || ka.added.Before(oldest.added)) {
+			oldest = ka
+		}
+	}
+	return oldest
+}
+
+// NetworkGroup returns the network group of an address: the
+// /16 prefix of an IPv4 address, the /32 prefix of an IPv6
+// address, or the host itself if it is a name.
+func NetworkGroup(addr string) string {
+	host, _, err := net.SplitHostPort(addr)
+	if err != nil {
+		host = addr
+	}
+	ip := net.ParseIP(host)
+	if ip == nil {
+		return host
+	}
+	if v4 := ip.To4(); v4 != nil {
+		return fmt.Sprintf("%d.%d", v4[0], v4[1])
+	}
+	return ip.Mask(net.CIDRMask(32, 128)).String()
+}
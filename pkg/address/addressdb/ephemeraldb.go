@@ -4,17 +4,34 @@ import (
 	"Coin/pkg/address"
 	"Coin/pkg/pro"
 	"errors"
+	"math/rand"
 	"sync"
 )
 
+// EphemeralAddressDb keeps addresses in memory.
+// addresses are the known addresses, by address. The database
+// owns them: Add stores a copy of the address it is given, and
+// Get, Select and List return copies, so that they are only
+// changed under the mutex.
+// limit is how many addresses it can hold.
+// mutex guards addresses, since the node reaches the database
+// from many goroutines.
 type EphemeralAddressDb struct {
 	addresses map[string]*address.Address
 	limit     int
-	sync.Mutex
+	mutex     sync.RWMutex
+}
+
+// NewEphemeralAddressDb returns an EphemeralAddressDb that can
+// hold up to limit addresses.
+func NewEphemeralAddressDb(limit int) *EphemeralAddressDb {
+	return &EphemeralAddressDb{addresses: make(map[string]*address.Address), limit: limit}
 }
 
 // Add Returns true if address was added (or modified)
 func (adb *EphemeralAddressDb) Add(a *address.Address) error {
+	adb.mutex.Lock()
+	defer adb.mutex.Unlock()
 	oldA := adb.addresses[a.Addr]
 	if oldA != nil {
 		return errors.New("address already exists")
@@ -22,15 +39,22 @@ func (adb *EphemeralAddressDb) Add(a *address.Address) error {
 	if len(adb.addresses) >= adb.limit {
 		return errors.New("address list full")
 	}
-	adb.addresses[a.Addr] = a
+	adb.addresses[a.Addr] = a.Copy()
 	return nil
 }
 
 func (adb *EphemeralAddressDb) Get(addr string) *address.Address {
-	return adb.addresses[addr]
+	adb.mutex.RLock()
+	defer adb.mutex.RUnlock()
+	if a := adb.addresses[addr]; a != nil {
+		return a.Copy()
+	}
+	return nil
 }
 
 func (adb *EphemeralAddressDb) UpdateLastSeen(addr string, lastSeen uint32) error {
+	adb.mutex.Lock()
+	defer adb.mutex.Unlock()
 	a := adb.addresses[addr]
 	if a == nil {
 		return errors.New("address not found")
@@ -39,18 +63,77 @@ func (adb *EphemeralAddressDb) UpdateLastSeen(addr string, lastSeen uint32) erro
 	return nil
 }
 
+func (adb *EphemeralAddressDb) RecordAttempt(addr string, success bool) error {
+	adb.mutex.Lock()
+	defer adb.mutex.Unlock()
+	a := adb.addresses[addr]
+	if a == nil {
+		return errors.New("address not found")
+	}
+	a.RecordAttempt(success)
+	return nil
+}
+
+func (adb *EphemeralAddressDb) SetPublicKey(addr string, pk string) error {
+	adb.mutex.Lock()
+	defer adb.mutex.Unlock()
+	a := adb.addresses[addr]
+	if a == nil {
+		return errors.New("address not found")
+	}
+	a.PublicKey = pk
+	return nil
+}
+
+func (adb *EphemeralAddressDb) Remove(addr string) error {
+	adb.mutex.Lock()
+	defer adb.mutex.Unlock()
+	if adb.addresses[addr] == nil {
+		return errors.New("address not found")
+	}
+	delete(adb.addresses, addr)
+	return nil
+}
+
+// Select returns a random address that is not in exclude,
+// or nil if there is none.
+func (adb *EphemeralAddressDb) Select(exclude []string) *address.Address {
+	adb.mutex.RLock()
+	defer adb.mutex.RUnlock()
+	excluded := make(map[string]bool)
+	for _, addr := range exclude {
+		excluded[addr] = true
+	}
+	candidates := make([]*address.Address, 0, len(adb.addresses))
+	for addr, a := range adb.addresses {
+		if !excluded[addr] {
+			candidates = append(candidates, a)
+		}
+	}
+	if len(candidates) == 0 {
+		return nil
+	}
+	return candidates[rand.Intn(len(candidates))].Copy()
+}
+
 func (adb *EphemeralAddressDb) List() []*address.Address {
+	adb.mutex.RLock()
+	defer adb.mutex.RUnlock()
 	addresses := make([]*address.Address, 0, len(adb.addresses))
 	for _, addr := range adb.addresses {
-		addresses = append(addresses, addr)
+		addresses = append(addresses, addr.Copy())
 	}
 	return addresses
 }
 
 func (adb *EphemeralAddressDb) Serialize() []*pro.Address {
+	adb.mutex.RLock()
+	defer adb.mutex.RUnlock()
 	addresses := make([]*pro.Address, 0, len(adb.addresses))
 	for _, addr := range adb.addresses {
 		addresses = append(addresses, addr.Serialize())
 	}
 	return addresses
 }
+
+func (adb *EphemeralAddressDb) Close() {}
@@ -0,0 +1,121 @@
+package addressdb
+
+import (
+	"Coin/pkg/address"
+	"Coin/pkg/pro"
+	"github.com/syndtr/goleveldb/leveldb"
+	"google.golang.org/protobuf/proto"
+	"sync"
+)
+
+// PersistentAddressDb is an AddressDb that persists every
+// address, along with when it was last seen and how our
+// connection attempts to it went, to a levelDB. The addresses
+// are loaded back into memory when the database is opened.
+// mutex keeps the levelDB in step with the addresses in memory,
+// by serializing the changes to both.
+type PersistentAddressDb struct {
+	*EphemeralAddressDb
+	db    *leveldb.DB
+	mutex sync.Mutex
+}
+
+// NewPersistentAddressDb opens the database at path and loads
+// the addresses stored in it.
+func NewPersistentAddressDb(path string, limit int) (*PersistentAddressDb, error) {
+	db, err := leveldb.OpenFile(path, nil)
+	if err != nil {
+		return nil, err
+	}
+	adb := &PersistentAddressDb{
+		EphemeralAddressDb: NewEphemeralAddressDb(limit),
+		db:                 db,
+	}
+	iterator := db.NewIterator(nil, nil)
+	for iterator.Next() {
+		par := &pro.AddressRecord{}
+		if err := proto.Unmarshal(iterator.Value(), par); err != nil {
+			logger.Error("unable to unmarshal address record", "peer", string(iterator.Key()), "err", err)
+			continue
+		}
+		a := DecodeAddressRecord(par)
+		adb.addresses[a.Addr] = a
+	}
+	iterator.Release()
+	return adb, nil
+}
+
+func (adb *PersistentAddressDb) Add(a *address.Address) error {
+	adb.mutex.Lock()
+	defer adb.mutex.Unlock()
+	if err := adb.EphemeralAddressDb.Add(a); err != nil {
+		return err
+	}
+	adb.put(a)
+	return nil
+}
+
+func (adb *PersistentAddressDb) UpdateLastSeen(addr string, lastSeen uint32) error {
+	adb.mutex.Lock()
+	defer adb.mutex.Unlock()
+	if err := adb.EphemeralAddressDb.UpdateLastSeen(addr, lastSeen); err != nil {
+		return err
+	}
+	adb.put(adb.Get(addr))
+	return nil
+}
+
+func (adb *PersistentAddressDb) RecordAttempt(addr string, success bool) error {
+	adb.mutex.Lock()
+	defer adb.mutex.Unlock()
+	if err := adb.EphemeralAddressDb.RecordAttempt(addr, success); err != nil {
+		return err
+	}
+	adb.put(adb.Get(addr))
+	return nil
+}
+
+func (adb *PersistentAddressDb) SetPublicKey(addr string, pk string) error {
+	adb.mutex.Lock()
+	defer adb.mutex.Unlock()
+	if err := adb.EphemeralAddressDb.SetPublicKey(addr, pk); err != nil {
+		return err
+	}
+	adb.put(adb.Get(addr))
+	return nil
+}
+
+func (adb *PersistentAddressDb) Remove(addr string) error {
+	adb.mutex.Lock()
+	defer adb.mutex.Unlock()
+	if err := adb.EphemeralAddressDb.Remove(addr); err != nil {
+		return err
+	}
+	if err := adb.db.Delete([]byte(addr), nil); err != nil {
+		logger.Error("unable to delete address record", "peer", addr, "err", err)
+	}
+	return nil
+}
+
+// Close writes every address back to the database and closes
+// the database.
+func (adb *PersistentAddressDb) Close() {
+	adb.mutex.Lock()
+	defer adb.mutex.Unlock()
+	for _, a := range adb.List() {
+		adb.put(a)
+	}
+	adb.db.Close()
+}
+
+// put stores an address in the database.
+func (adb *PersistentAddressDb) put(a *address.Address) {
+	bytes, err := proto.Marshal(EncodeAddressRecord(a))
+	if err != nil {
+		logger.Error("unable to marshal address record", "peer", a.Addr, "err", err)
+		return
+	}
+	if err = adb.db.Put([]byte(a.Addr), bytes, nil); err != nil {
+		logger.Error("unable to store address record", "peer", a.Addr, "err", err)
+	}
+}
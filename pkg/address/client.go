@@ -2,19 +2,31 @@ package address
 
 import (
 	"Coin/pkg/pro"
-	"fmt"
 	"golang.org/x/net/context"
 	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+	"sync"
 	"time"
 )
 
-//TODO: Are we sure that we want this file?
-
 // RPCTimeout is default timeout for rpc client calls
 const RPCTimeout = 2 * time.Second
 
+// defaultConns is the connection manager used by addresses
+// that were not given one.
+var defaultConns *ConnectionManager
+var defaultConnsOnce sync.Once
+
+// SenderMetadataKey is the gRPC metadata key under which
+// a node sends its own address along with every RPC, so
+// that the receiving node knows which peer it came from.
+const SenderMetadataKey = "coin-sender"
+
 // clientUnaryInterceptor is a client unary interceptor that injects a default timeout
-func clientUnaryInterceptor(
+// and the address of the sending node, and reports the RPC to the manager's observer
+func (cm *ConnectionManager) clientUnaryInterceptor(
 	ctx context.Context,
 	method string,
 	req, reply interface{},
@@ -24,136 +36,130 @@ func clientUnaryInterceptor(
 ) error {
 	ctx, cancel := context.WithTimeout(ctx, RPCTimeout)
 	defer cancel()
-	return invoker(ctx, method, req, reply, cc, opts...)
+	if sender := cm.LocalAddr(); sender != "" {
+		ctx = metadata.AppendToOutgoingContext(ctx, SenderMetadataKey, sender)
+	}
+	cm.pinMutex.RLock()
+	observer := cm.observer
+	cm.pinMutex.RUnlock()
+	if observer == nil {
+		return invoker(ctx, method, req, reply, cc, opts...)
+	}
+	start := time.Now()
+	err := invoker(ctx, method, req, reply, cc, opts...)
+	observer(method, time.Since(start), err)
+	return err
 }
 
-func connectToServer(addr string) (*grpc.ClientConn, error) {
-	return grpc.Dial(addr, []grpc.DialOption{
-		grpc.WithInsecure(),
-		grpc.FailOnNonTempDialError(true),
-		grpc.WithUnaryInterceptor(clientUnaryInterceptor),
-	}...)
+// connections returns the connection manager that the
+// address should use for its RPCs.
+func (a *Address) connections() *ConnectionManager {
+	if a.Conns != nil {
+		return a.Conns
+	}
+	defaultConnsOnce.Do(func() {
+		defaultConns = NewConnectionManager(DefaultConfig(), nil)
+	})
+	return defaultConns
 }
 
-// TODO: cache client connections (optional feature)
-// Returns callback to close connection
-func (a *Address) GetConnection() (pro.CoinClient, *grpc.ClientConn, error) {
-	cc, err := connectToServer(a.Addr)
+// GetConnection returns a client that uses the pooled
+// connection to the address.
+func (a *Address) GetConnection() (pro.CoinClient, error) {
+	cc, err := a.connections().Get(a.Addr)
 	if err != nil {
-		return nil, nil, err
+		return nil, err
+	}
+	return pro.NewCoinClient(cc), nil
+}
+
+// report tells the connection manager how an RPC went, so
+// that unreachable peers are dropped from the pool.
+func (a *Address) report(err error) {
+	if err == nil {
+		a.connections().ReportSuccess(a.Addr)
+	} else if status.Code(err) == codes.Unavailable {
+		a.connections().ReportFailure(a.Addr)
 	}
-	return pro.NewCoinClient(cc), cc, err
 }
 
-func (a *Address) VersionRPC(request *pro.VersionRequest) (*pro.Empty, error) {
-	c, cc, err := a.GetConnection()
+func (a *Address) VersionRPC(request *pro.VersionRequest) (*pro.VersionAck, error) {
+	c, err := a.GetConnection()
 	if err != nil {
 		return nil, err
 	}
-	defer func() {
-		err := cc.Close()
-		if err != nil {
-			fmt.Printf("ERROR {Address.VersionRPC}: " +
-				"error when closing connection")
-		}
-	}()
 	reply, err := c.Version(context.Background(), request)
 	a.SentVer = time.Now()
+	a.report(err)
 	return reply, err
 }
 
 func (a *Address) GetBlocksRPC(request *pro.GetBlocksRequest) (*pro.GetBlocksResponse, error) {
-	c, cc, err := a.GetConnection()
+	c, err := a.GetConnection()
 	if err != nil {
 		return nil, err
 	}
-	defer func() {
-		err := cc.Close()
-		if err != nil {
-			fmt.Printf("ERROR {Address.GetBlocksRPC}: " +
-				"error when closing connection")
-		}
-	}()
 	reply, err := c.GetBlocks(context.Background(), request)
+	a.report(err)
 	return reply, err
 }
 
 func (a *Address) GetDataRPC(request *pro.GetDataRequest) (*pro.GetDataResponse, error) {
-	c, cc, err := a.GetConnection()
+	c, err := a.GetConnection()
 	if err != nil {
 		return nil, err
 	}
-	defer func() {
-		err := cc.Close()
-		if err != nil {
-			fmt.Printf("ERROR {Address.GetDataRPC}: " +
-				"error when closing connection")
-		}
-	}()
 	reply, err := c.GetData(context.Background(), request)
+	a.report(err)
 	return reply, err
 }
 
 func (a *Address) GetAddressesRPC(request *pro.Empty) (*pro.Addresses, error) {
-	c, cc, err := a.GetConnection()
+	c, err := a.GetConnection()
 	if err != nil {
 		return nil, err
 	}
-	defer func() {
-		err := cc.Close()
-		if err != nil {
-			fmt.Printf("ERROR {Address.GetAddressesRPC}: " +
-				"error when closing connection")
-		}
-	}()
 	reply, err := c.GetAddresses(context.Background(), request)
+	a.report(err)
 	return reply, err
 }
 
 func (a *Address) SendAddressesRPC(request *pro.Addresses) (*pro.Empty, error) {
-	c, cc, err := a.GetConnection()
+	c, err := a.GetConnection()
 	if err != nil {
 		return nil, err
 	}
-	defer func() {
-		err := cc.Close()
-		if err != nil {
-			fmt.Printf("ERROR {Address.SendAddressesRPC}: " +
-				"error when closing connection")
-		}
-	}()
 	reply, err := c.SendAddresses(context.Background(), request)
+	a.report(err)
 	return reply, err
 }
 
 func (a *Address) ForwardTransactionRPC(request *pro.Transaction) (*pro.Empty, error) {
-	c, cc, err := a.GetConnection()
+	c, err := a.GetConnection()
 	if err != nil {
 		return nil, err
 	}
-	defer func() {
-		err := cc.Close()
-		if err != nil {
-			fmt.Printf("ERROR {Address.ForwardTransactionRPC}: " +
-				"error when closing connection")
-		}
-	}()
 	reply, err := c.ForwardTransaction(context.Background(), request)
+	a.report(err)
 	return reply, err
 }
 
 func (a *Address) ForwardBlockRPC(request *pro.Block) (*pro.Empty, error) {
-	c, cc, err := a.GetConnection()
+	c, err := a.GetConnection()
 	if err != nil {
 		return nil, err
 	}
-	defer func() {
-		err := cc.Close()
-		if err != nil {
-			fmt.Printf("ERROR {Address.ForwardBlockRPC}: " +
-				"error when closing connection")
-		}
-	}()
 	reply, err := c.ForwardBlock(context.Background(), request)
+	a.report(err)
+	return reply, err
+}
+
+func (a *Address) PingRPC(request *pro.PingRequest) (*pro.PingResponse, error) {
+	c, err := a.GetConnection()
+	if err != nil {
+		return nil, err
+	}
+	reply, err := c.Ping(context.Background(), request)
+	a.report(err)
 	return reply, err
 }
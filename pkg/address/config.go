@@ -0,0 +1,44 @@
+package address
+
+import "time"
+
+// Config is the configuration for the ConnectionManager.
+// SecureTransport is whether connections to other nodes are
+// encrypted and authenticated with TLS certificates made from
+// the nodes' ids.
+// IdleTimeout is how long a connection may go unused
+// before it is closed and evicted from the pool.
+// HealthCheckInterval is how often the pool checks the
+// state of its connections. It is also used as the keepalive
+// interval for the underlying gRPC transport.
+// HealthCheckTimeout is how long a keepalive ping may go
+// unanswered before the connection is considered broken.
+// MinBackoff is the delay before the first reconnection
+// attempt to a peer that could not be reached.
+// MaxBackoff is the upper bound on the delay between
+// reconnection attempts.
+// MaxMessageSize is the largest message, in bytes, that may
+// be sent to or received from another node.
+type Config struct {
+	SecureTransport     bool
+	IdleTimeout         time.Duration
+	HealthCheckInterval time.Duration
+	HealthCheckTimeout  time.Duration
+	MinBackoff          time.Duration
+	MaxBackoff          time.Duration
+	MaxMessageSize      int
+}
+
+// DefaultConfig returns the default settings for
+// the ConnectionManager.
+func DefaultConfig() *Config {
+	return &Config{
+		SecureTransport:     true,
+		IdleTimeout:         5 * time.Minute,
+		HealthCheckInterval: 30 * time.Second,
+		HealthCheckTimeout:  10 * time.Second,
+		MinBackoff:          time.Second,
+		MaxBackoff:          time.Minute,
+		MaxMessageSize:      16 * 1024 * 1024,
+	}
+}
@@ -0,0 +1,355 @@
+package address
+
+import (
+	"Coin/pkg/id"
+	"Coin/pkg/utils"
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/backoff"
+	"google.golang.org/grpc/connectivity"
+	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/keepalive"
+	"sync"
+	"time"
+)
+
+// logger logs for connection managers.
+var logger = utils.NewLogger(utils.ComponentP2P)
+
+// ConnectionManager keeps one long-lived gRPC connection
+// per peer so that RPCs do not have to dial a new
+// connection every time they are made.
+// config is the configuration of the manager.
+// conns maps a peer's address to its pooled connection.
+// certificate is the node's own TLS certificate. If it is
+// nil, connections are made without transport security.
+// pins maps a peer's address to the public key that the
+// peer must prove it holds when we connect to it. An address
+// that is not pinned yet is pinned to the key that the node
+// there proves it holds the first time we connect to it.
+// pinObserver, if set, is told of each address that is pinned
+// that way.
+// localAddr is the address of the node that owns the manager,
+// which is sent along with every RPC.
+// observer, if set, is told the method, latency and error of
+// every RPC made through the manager.
+// quit is closed when the manager is shut down, which stops
+// the maintenance loop.
+type ConnectionManager struct {
+	config      *Config
+	conns       map[string]*pooledConn
+	certificate *tls.Certificate
+	pins        map[string]string
+	pinObserver func(addr string, pk string)
+	localAddr   string
+	observer    func(method string, took time.Duration, err error)
+	quit        chan struct{}
+	closed      bool
+
+	mutex    sync.Mutex
+	pinMutex sync.RWMutex
+}
+
+// pooledConn is a connection in the ConnectionManager's pool.
+// cc is the underlying gRPC connection. It is nil while
+// the manager is backing off from a failed peer.
+// lastUsed is the last time an RPC was made on the connection.
+// failures is the number of consecutive failures seen for
+// the peer.
+// nextAttempt is the earliest time at which the manager
+// will try to dial the peer again.
+type pooledConn struct {
+	cc          *grpc.ClientConn
+	lastUsed    time.Time
+	failures    uint32
+	nextAttempt time.Time
+}
+
+// NewConnectionManager returns a ConnectionManager given a
+// Config and starts its maintenance loop. If a certificate
+// is given, every connection is encrypted and authenticated
+// with it.
+func NewConnectionManager(c *Config, certificate *tls.Certificate) *ConnectionManager {
+	cm := &ConnectionManager{
+		config:      c,
+		conns:       make(map[string]*pooledConn),
+		certificate: certificate,
+		pins:        make(map[string]string),
+		quit:        make(chan struct{}),
+	}
+	go cm.maintain()
+	return cm
+}
+
+// SetLocalAddr sets the address of the node that owns the
+// manager.
+func (cm *ConnectionManager) SetLocalAddr(addr string) {
+	cm.pinMutex.Lock()
+	cm.localAddr = addr
+	cm.pinMutex.Unlock()
+}
+
+// LocalAddr returns the address of the node that owns the
+// manager.
+func (cm *ConnectionManager) LocalAddr() string {
+	cm.pinMutex.RLock()
+	defer cm.pinMutex.RUnlock()
+	return cm.localAddr
+}
+
+// ObserveRPCs has the manager tell f the method, latency and
+// error of every RPC made through it.
+func (cm *ConnectionManager) ObserveRPCs(f func(method string, took time.Duration, err error)) {
+	cm.pinMutex.Lock()
+	cm.observer = f
+	cm.pinMutex.Unlock()
+}
+
+// Pin requires the peer at addr to prove that it holds the
+// private key behind pk on every future connection. If the
+// peer was pinned to another key, its connection is dropped.
+func (cm *ConnectionManager) Pin(addr string, pk string) {
+	cm.pinMutex.Lock()
+	old := cm.pins[addr]
+	cm.pins[addr] = pk
+	cm.pinMutex.Unlock()
+	if old != "" && old != pk {
+		cm.Remove(addr)
+	}
+}
+
+// ObservePins has the manager tell f of each address that it
+// pins because connecting to it proved the key of the node there.
+func (cm *ConnectionManager) ObservePins(f func(addr string, pk string)) {
+	cm.pinMutex.Lock()
+	cm.pinObserver = f
+	cm.pinMutex.Unlock()
+}
+
+// prove checks the public key that the peer at addr proved it
+// holds when we connected to it against the key that addr is
+// pinned to, and pins addr to it if addr is not pinned yet.
+func (cm *ConnectionManager) prove(addr string, pk string) error {
+	cm.pinMutex.Lock()
+	pinned := cm.pins[addr]
+	if pinned == "" {
+		cm.pins[addr] = pk
+	}
+	observer := cm.pinObserver
+	cm.pinMutex.Unlock()
+	if pinned != "" && pinned != pk {
+		return errors.New("peer does not hold its pinned public key")
+	}
+	if pinned == "" && observer != nil {
+		observer(addr, pk)
+	}
+	return nil
+}
+
+// PinnedKey returns the public key that the peer at addr is
+// pinned to, or the empty string if it is not pinned.
+func (cm *ConnectionManager) PinnedKey(addr string) string {
+	cm.pinMutex.RLock()
+	defer cm.pinMutex.RUnlock()
+	return cm.pins[addr]
+}
+
+// transportOption returns the dial option that secures the
+// connection to the peer at addr. The peer's certificate
+// must be self-signed by its identity key, and that key must
+// match the pinned key if the peer is pinned. Otherwise the
+// peer is pinned to it.
+func (cm *ConnectionManager) transportOption(addr string) grpc.DialOption {
+	if cm.certificate == nil {
+		return grpc.WithInsecure()
+	}
+	return grpc.WithTransportCredentials(credentials.NewTLS(&tls.Config{
+		Certificates: []tls.Certificate{*cm.certificate},
+		// peers present self-signed certificates, which are
+		// checked against their identity below instead
+		InsecureSkipVerify: true,
+		VerifyPeerCertificate: func(rawCerts [][]byte, _ [][]*x509.Certificate) error {
+			pk, err := id.VerifyCertificate(rawCerts)
+			if err != nil {
+				return err
+			}
+			return cm.prove(addr, pk)
+		},
+		MinVersion: tls.VersionTLS13,
+	}))
+}
+
+// dialOptions returns the options used to dial a peer.
+func (cm *ConnectionManager) dialOptions(addr string) []grpc.DialOption {
+	return []grpc.DialOption{
+		cm.transportOption(addr),
+		grpc.WithUnaryInterceptor(cm.clientUnaryInterceptor),
+		grpc.WithConnectParams(grpc.ConnectParams{
+			Backoff: backoff.Config{
+				BaseDelay:  cm.config.MinBackoff,
+				Multiplier: backoff.DefaultConfig.Multiplier,
+				Jitter:     backoff.DefaultConfig.Jitter,
+				MaxDelay:   cm.config.MaxBackoff,
+			},
+			MinConnectTimeout: RPCTimeout,
+		}),
+		grpc.WithKeepaliveParams(keepalive.ClientParameters{
+			Time:                cm.config.HealthCheckInterval,
+			Timeout:             cm.config.HealthCheckTimeout,
+			PermitWithoutStream: true,
+		}),
+		grpc.WithDefaultCallOptions(
+			grpc.MaxCallRecvMsgSize(cm.config.MaxMessageSize),
+			grpc.MaxCallSendMsgSize(cm.config.MaxMessageSize),
+		),
+	}
+}
+
+// Get returns the pooled connection to a peer, dialing
+// one if there is none yet. If the peer recently failed,
+// Get returns an error until the backoff period is over.
+func (cm *ConnectionManager) Get(addr string) (*grpc.ClientConn, error) {
+	cm.mutex.Lock()
+	defer cm.mutex.Unlock()
+	if cm.closed {
+		return nil, errors.New("connection manager is closed")
+	}
+	pc := cm.conns[addr]
+	if pc == nil {
+		pc = &pooledConn{}
+		cm.conns[addr] = pc
+	}
+	pc.lastUsed = time.Now()
+	if pc.cc != nil && pc.cc.GetState() != connectivity.Shutdown {
+		return pc.cc, nil
+	}
+	if time.Now().Before(pc.nextAttempt) {
+		return nil, errors.New("backing off from unreachable peer")
+	}
+	cc, err := grpc.Dial(addr, cm.dialOptions(addr)...)
+	if err != nil {
+		cm.backOff(pc)
+		return nil, err
+	}
+	pc.cc = cc
+	return cc, nil
+}
+
+// ReportFailure tells the manager that an RPC to a peer
+// failed because the peer could not be reached. The
+// connection is dropped and the peer is backed off from.
+func (cm *ConnectionManager) ReportFailure(addr string) {
+	cm.mutex.Lock()
+	defer cm.mutex.Unlock()
+	pc := cm.conns[addr]
+	if pc == nil {
+		return
+	}
+	cm.closeConn(pc)
+	cm.backOff(pc)
+}
+
+// ReportSuccess tells the manager that an RPC to a peer
+// succeeded, which resets the peer's backoff.
+func (cm *ConnectionManager) ReportSuccess(addr string) {
+	cm.mutex.Lock()
+	defer cm.mutex.Unlock()
+	if pc := cm.conns[addr]; pc != nil {
+		pc.failures = 0
+		pc.nextAttempt = time.Time{}
+	}
+}
+
+// Remove closes the connection to a peer and forgets about it.
+func (cm *ConnectionManager) Remove(addr string) {
+	cm.mutex.Lock()
+	defer cm.mutex.Unlock()
+	if pc := cm.conns[addr]; pc != nil {
+		cm.closeConn(pc)
+		delete(cm.conns, addr)
+	}
+}
+
+// Close closes every pooled connection and stops the
+// maintenance loop. It is safe to call more than once.
+func (cm *ConnectionManager) Close() {
+	cm.mutex.Lock()
+	defer cm.mutex.Unlock()
+	if cm.closed {
+		return
+	}
+	cm.closed = true
+	close(cm.quit)
+	for addr, pc := range cm.conns {
+		cm.closeConn(pc)
+		delete(cm.conns, addr)
+	}
+}
+
+// backOff schedules the next dial attempt for a peer,
+// doubling the delay with each consecutive failure.
+func (cm *ConnectionManager) backOff(pc *pooledConn) {
+	pc.failures++
+	delay := cm.config.MinBackoff
+	for i := uint32(1); i < pc.failures && delay < cm.config.MaxBackoff; i++ {
+		delay *= 2
+	}
+	if delay > cm.config.MaxBackoff {
+		delay = cm.config.MaxBackoff
+	}
+	pc.nextAttempt = time.Now().Add(delay)
+}
+
+// closeConn closes a pooled connection, if it is open.
+func (cm *ConnectionManager) closeConn(pc *pooledConn) {
+	if pc.cc == nil {
+		return
+	}
+	if err := pc.cc.Close(); err != nil {
+		logger.Warn("unable to close connection", "node", cm.LocalAddr(), "err", err)
+	}
+	pc.cc = nil
+}
+
+// maintain periodically health checks the pool. Idle
+// connections are evicted, broken connections are dropped
+// so that they are redialed with backoff, and connections
+// that went idle at the transport level are woken up.
+func (cm *ConnectionManager) maintain() {
+	ticker := time.NewTicker(cm.config.HealthCheckInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-cm.quit:
+			return
+		case <-ticker.C:
+			cm.checkConnections()
+		}
+	}
+}
+
+// checkConnections does a single pass of the health check.
+func (cm *ConnectionManager) checkConnections() {
+	cm.mutex.Lock()
+	defer cm.mutex.Unlock()
+	now := time.Now()
+	for addr, pc := range cm.conns {
+		if now.Sub(pc.lastUsed) > cm.config.IdleTimeout {
+			cm.closeConn(pc)
+			delete(cm.conns, addr)
+			continue
+		}
+		if pc.cc == nil {
+			continue
+		}
+		switch pc.cc.GetState() {
+		case connectivity.TransientFailure, connectivity.Shutdown:
+			cm.closeConn(pc)
+			cm.backOff(pc)
+		case connectivity.Idle:
+			pc.cc.Connect()
+		}
+	}
+}
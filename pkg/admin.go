@@ -0,0 +1,310 @@
+package pkg
+
+import (
+	"Coin/pkg/block"
+	"Coin/pkg/id"
+	"Coin/pkg/peer"
+	"Coin/pkg/pro"
+	"Coin/pkg/utils"
+	"context"
+	"crypto/subtle"
+	"encoding/hex"
+	"fmt"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+	"net"
+	"time"
+)
+
+// adminServer serves the Admin service of a node, which lets
+// its operator query and control it. It is kept apart from
+// the node's Coin service, which other nodes use.
+type adminServer struct {
+	*pro.UnimplementedAdminServer
+	n *Node
+}
+
+// StartAdminServer opens the node's Admin service on addr.
+// Unlike the Coin service, it stays up while the node is
+// paused, so that the node can be resumed. As calls are
+// only checked when the node has an admin token, the service
+// is refused on addresses that are reachable from other
+// machines unless it has one.
+// Returns:
+// error if addr is not on this machine and the node has no
+// admin token, or if the node could not listen on addr
+func (n *Node) StartAdminServer(addr string) error {
+	host, _, err := net.SplitHostPort(addr)
+	if err != nil {
+		return err
+	}
+	if !isLoopback(host) && n.Config.AdminToken == "" {
+		return fmt.Errorf("admin address %q is not on localhost, so an admin token must be set", addr)
+	}
+	lis, err := net.Listen("tcp", addr)
+	if err != nil {
+		return err
+	}
+	n.AdminServer = grpc.NewServer(grpc.UnaryInterceptor(n.adminUnaryInterceptor))
+	pro.RegisterAdminServer(n.AdminServer, &adminServer{n: n})
+	go func() {
+		if err := n.AdminServer.Serve(lis); err != nil {
+			n.logger(utils.ComponentRPC).Error("unable to serve admin server", "addr", addr, "err", err)
+		}
+	}()
+	return nil
+}
+
+// adminUnaryInterceptor refuses Admin calls that do not carry
+// the node's admin token, if it has one.
+func (n *Node) adminUnaryInterceptor(
+	ctx context.Context,
+	req interface{},
+	info *grpc.UnaryServerInfo,
+	handler grpc.UnaryHandler,
+) (interface{}, error) {
+	if token := n.Config.AdminToken; token != "" {
+		md, _ := metadata.FromIncomingContext(ctx)
+		given := md.Get("authorization")
+		want := "Bearer " + token
+		if len(given) != 1 || subtle.ConstantTimeCompare([]byte(given[0]), []byte(want)) != 1 {
+			return nil, status.Error(codes.Unauthenticated, "missing or wrong admin token")
+		}
+	}
+	return handler(ctx, req)
+}
+
+// publicKey decodes a hex encoded public key into its form in
+// locking scripts. An empty key is the node's own.
+func (n *Node) publicKey(pkHex string) (string, error) {
+	if pkHex == "" {
+		return n.Id.GetPublicKeyString(), nil
+	}
+	pk, err := hex.DecodeString(pkHex)
+	if err != nil || len(pk) == 0 {
+		return "", status.Errorf(codes.InvalidArgument, "public key %q is not hex encoded", pkHex)
+	}
+	return string(pk), nil
+}
+
+func (s *adminServer) GetNodeInfo(ctx context.Context, in *pro.Empty) (*pro.NodeInfo, error) {
+	n := s.n
+	return &pro.NodeInfo{
+		Address:    n.Address,
+		PublicKey:  id.PublicKeyHex(n.Id.GetPublicKey()),
+		Version:    uint32(n.Config.Version),
+		MinVersion: uint32(n.Config.MinVersion),
+		UserAgent:  n.Config.UserAgent,
+		Services:   uint64(n.Services()),
+		Paused:     n.Paused.Load(),
+		Inbound:    uint32(n.InboundCount()),
+		Outbound:   uint32(n.OutboundCount()),
+	}, nil
+}
+
+func (s *adminServer) GetChainInfo(ctx context.Context, in *pro.Empty) (*pro.ChainInfo, error) {
+	n := s.n
+	return &pro.ChainInfo{
+		Network:        n.Config.Network.Name,
+		Height:         n.BlockChain.Length,
+		BestHash:       n.BlockChain.LastHash,
+		GenesisHash:    n.genesisHash,
+		BestPeerHeight: n.bestPeerHeight(),
+	}, nil
+}
+
+func (s *adminServer) GetBlockCount(ctx context.Context, in *pro.Empty) (*pro.BlockCount, error) {
+	return &pro.BlockCount{Count: s.n.BlockChain.Length}, nil
+}
+
+func (s *adminServer) GetBlock(ctx context.Context, in *pro.GetBlockRequest) (*pro.BlockInfo, error) {
+	bc := s.n.BlockChain
+	hash := in.Hash
+	if hash == "" {
+		if in.Height < 1 || in.Height > bc.Length {
+			return nil, status.Errorf(codes.NotFound, "no block at height %v, the chain has %v", in.Height, bc.Length)
+		}
+		hash = bc.GetHashes(in.Height, in.Height)[0]
+	}
+	if !bc.BlockInfoDB.HasBlockRecord(hash) {
+		return nil, status.Errorf(codes.NotFound, "no block with hash %v", hash)
+	}
+	br := bc.BlockInfoDB.GetBlockRecord(hash)
+	return &pro.BlockInfo{Hash: hash, Height: br.Height, Block: block.EncodeBlock(bc.GetBlock(hash))}, nil
+}
+
+func (s *adminServer) GetBalance(ctx context.Context, in *pro.BalanceRequest) (*pro.Balance, error) {
+	pk, err := s.n.publicKey(in.PublicKey)
+	if err != nil {
+		return nil, err
+	}
+	return &pro.Balance{PublicKey: hex.EncodeToString([]byte(pk)), Amount: s.n.GetBalance(pk)}, nil
+}
+
+func (s *adminServer) GetWalletInfo(ctx context.Context, in *pro.Empty) (*pro.WalletInfo, error) {
+	if !s.n.Config.WalletConfig.HasWallet {
+		return nil, status.Error(codes.FailedPrecondition, "node has no wallet")
+	}
+	w := s.n.Wallet
+	return &pro.WalletInfo{
+		PublicKey:    hex.EncodeToString(w.Id.GetPublicKeyBytes()),
+		Balance:      w.Balance,
+		ChainBalance: s.n.GetBalance(w.Id.GetPublicKeyString()),
+	}, nil
+}
+
+func (s *adminServer) Send(ctx context.Context, in *pro.SendRequest) (*pro.SendResponse, error) {
+	if !s.n.Config.WalletConfig.HasWallet {
+		return nil, status.Error(codes.FailedPrecondition, "node has no wallet")
+	}
+	if in.PublicKey == "" || in.Amount == 0 {
+		return nil, status.Error(codes.InvalidArgument, "a recipient and a positive amount are needed")
+	}
+	pk, err := s.n.publicKey(in.PublicKey)
+	if err != nil {
+		return nil, err
+	}
+	tx := s.n.Wallet.RequestTransaction(in.Amount, in.Fee, []byte(pk))
+	if tx == nil {
+		return nil, status.Errorf(codes.FailedPrecondition,
+			"wallet could not pay %v with a fee of %v", in.Amount, in.Fee)
+	}
+	return &pro.SendResponse{TransactionHash: tx.Hash()}, nil
+}
+
+func (s *adminServer) ListPeers(ctx context.Context, in *pro.Empty) (*pro.PeerList, error) {
+	list := &pro.PeerList{}
+	for _, st := range s.n.PeerStats() {
+		list.Peers = append(list.Peers, &pro.PeerInfo{
+			Addr:        st.Addr,
+			Inbound:     st.Inbound,
+			Version:     st.Version,
+			Services:    uint64(st.Services),
+			UserAgent:   st.UserAgent,
+			BestHeight:  st.BestHeight,
+			BestHash:    st.BestHash,
+			RttNanos:    int64(st.RTT),
+			Misbehavior: st.Misbehavior,
+		})
+	}
+	return list, nil
+}
+
+func (s *adminServer) AddPeer(ctx context.Context, in *pro.AddPeerRequest) (*pro.Empty, error) {
+	if !utils.ValidAddr(in.Addr) {
+		return nil, status.Errorf(codes.InvalidArgument, "invalid address %q", in.Addr)
+	}
+	if s.n.Paused.Load() {
+		return nil, status.Error(codes.FailedPrecondition, "node is paused")
+	}
+	if !s.n.ConnectToPeer(in.Addr) {
+		return nil, status.Errorf(codes.Unavailable, "could not peer with %v", in.Addr)
+	}
+	return &pro.Empty{}, nil
+}
+
+func (s *adminServer) DisconnectPeer(ctx context.Context, in *pro.PeerRequest) (*pro.Empty, error) {
+	if s.n.PeerDb.Get(in.Addr) == nil {
+		return nil, status.Errorf(codes.NotFound, "%v is not a peer", in.Addr)
+	}
+	s.n.Disconnect(in.Addr)
+	return &pro.Empty{}, nil
+}
+
+func (s *adminServer) BanPeer(ctx context.Context, in *pro.BanRequest) (*pro.Empty, error) {
+	if in.Addr == "" || in.DurationSeconds < 0 {
+		return nil, status.Error(codes.InvalidArgument, "an address and a duration of 0 or more are needed")
+	}
+	reason := in.Reason
+	if reason == "" {
+		reason = "banned by the operator"
+	}
+	s.n.Ban(in.Addr, time.Duration(in.DurationSeconds)*time.Second, reason)
+	return &pro.Empty{}, nil
+}
+
+func (s *adminServer) UnbanPeer(ctx context.Context, in *pro.PeerRequest) (*pro.UnbanResponse, error) {
+	return &pro.UnbanResponse{WasBanned: s.n.ClearBan(in.Addr)}, nil
+}
+
+func (s *adminServer) ListBans(ctx context.Context, in *pro.Empty) (*pro.BanList, error) {
+	list := &pro.BanList{}
+	for _, b := range s.n.ListBans() {
+		list.Bans = append(list.Bans, peer.EncodeBan(b))
+	}
+	return list, nil
+}
+
+func (s *adminServer) GetMempool(ctx context.Context, in *pro.Empty) (*pro.Mempool, error) {
+	if !s.n.Config.MinerConfig.HasMiner {
+		return nil, status.Error(codes.FailedPrecondition, "node has no miner, so it keeps no mempool")
+	}
+	tp := s.n.Miner.TxPool
+	pool := &pro.Mempool{
+		Count:         tp.Length(),
+		Capacity:      tp.Capacity,
+		TotalPriority: tp.CurrentPriority.Load(),
+		PriorityLimit: tp.PriorityLimit,
+	}
+	for _, node := range tp.List() {
+		tx := node.Transaction
+		pool.Entries = append(pool.Entries, &pro.MempoolEntry{
+			Hash:         tx.Hash(),
+			Priority:     node.Priority,
+			Size:         tx.Size(),
+			Inputs:       uint32(len(tx.Inputs)),
+			Outputs:      uint32(len(tx.Outputs)),
+			OutputAmount: tx.SumOutputs(),
+		})
+	}
+	return pool, nil
+}
+
+func (s *adminServer) GetMiningInfo(ctx context.Context, in *pro.Empty) (*pro.MiningInfo, error) {
+	m := s.n.Miner
+	if !s.n.Config.MinerConfig.HasMiner {
+		return &pro.MiningInfo{}, nil
+	}
+	return &pro.MiningInfo{
+		HasMiner:         true,
+		Active:           m.Active.Load(),
+		Mining:           m.Mining.Load(),
+		ChainLength:      m.ChainLength.Load(),
+		Subsidy:          m.CalculateMintingReward(),
+		DifficultyTarget: string(m.DifficultyTarget),
+		PoolSize:         m.TxPool.Length(),
+	}, nil
+}
+
+func (s *adminServer) StartMining(ctx context.Context, in *pro.Empty) (*pro.Empty, error) {
+	if !s.n.Config.MinerConfig.HasMiner {
+		return nil, status.Error(codes.FailedPrecondition, "node has no miner")
+	}
+	s.n.StartMiner()
+	return &pro.Empty{}, nil
+}
+
+func (s *adminServer) StopMining(ctx context.Context, in *pro.Empty) (*pro.Empty, error) {
+	if !s.n.Config.MinerConfig.HasMiner {
+		return nil, status.Error(codes.FailedPrecondition, "node has no miner")
+	}
+	s.n.StopMiner()
+	return &pro.Empty{}, nil
+}
+
+func (s *adminServer) Pause(ctx context.Context, in *pro.Empty) (*pro.Empty, error) {
+	s.n.PauseNetwork()
+	return &pro.Empty{}, nil
+}
+
+func (s *adminServer) Resume(ctx context.Context, in *pro.Empty) (*pro.Empty, error) {
+	if err := s.n.ResumeNetwork(); err != nil {
+		if s.n.Paused.Load() {
+			return nil, status.Errorf(codes.Unavailable, "could not resume: %v", err)
+		}
+		return nil, status.Errorf(codes.Unavailable, "resumed, but could not catch up: %v", err)
+	}
+	return &pro.Empty{}, nil
+}
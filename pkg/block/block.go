@@ -11,6 +11,9 @@ import (
 	"strings"
 )
 
+// logger logs for the blocks and transactions of the chain.
+var logger = utils.NewLogger(utils.ComponentChain)
+
 // Header provides information about the Block.
 // Version is the Block's version.
 // PreviousHash is the hash of the previous Block.
@@ -33,12 +36,11 @@ type Block struct {
 	Transactions []*Transaction
 }
 
-// EncodeHeader returns a pro.Header given a Header.
+// EncodeHeader returns a pro.Header given a Header, or nil given nil.
 func EncodeHeader(header *Header) *pro.Header {
 	if header == nil {
-		fmt.Printf("header was nil")
+		return nil
 	}
-
 	return &pro.Header{
 		Version:          header.Version,
 		PreviousHash:     header.PreviousHash,
@@ -91,7 +93,7 @@ func (b *Block) Hash() string {
 	pb := EncodeHeader(b.Header)
 	bytes, err := proto.Marshal(pb)
 	if err != nil {
-		utils.Debug.Printf("[block.Hash()] Unable to marshal block")
+		logger.Error("unable to marshal block", "func", "Block.Hash", "err", err)
 	}
 	h.Write(bytes)
 	return fmt.Sprintf("%x", h.Sum(nil))
@@ -153,8 +155,7 @@ func CalculateMerkleRoot(txs []*Transaction) string {
 		hashes = newHashes
 	}
 	if hashes == nil || len(hashes) < 1 {
-		fmt.Printf("ERROR {block.CaclMrkRt}: function" +
-			"ended up not being able to calculate a root.\n")
+		logger.Error("unable to calculate a merkle root", "func", "block.CalculateMerkleRoot")
 		return ""
 	}
 	return hashes[0]
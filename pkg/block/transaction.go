@@ -5,6 +5,7 @@ import (
 	"Coin/pkg/pro"
 	"Coin/pkg/utils"
 	"crypto/sha256"
+	"encoding/binary"
 	"fmt"
 	"google.golang.org/protobuf/proto"
 	"strconv"
@@ -15,7 +16,9 @@ import (
 // generate new TransactionInputs and so forth.
 // ReferenceTransactionHash is the hash of the parent TransactionOutput's Transaction.
 // OutputIndex is the index of the parent TransactionOutput's Transaction.
-// UnlockingScript is the signature that verifies that the payer can spend the referenced TransactionOutput.
+// UnlockingScript is the signature that verifies that the payer can spend the referenced TransactionOutput:
+// the hex signature made by SignInput if the TransactionOutput is locked to a bare public key, or
+// an unlocking script if it is locked by a script.
 type TransactionInput struct {
 	ReferenceTransactionHash string
 	OutputIndex              uint32
@@ -26,7 +29,8 @@ type TransactionInput struct {
 // Recall that TransactionOutputs generate TransactionInputs which in turn
 // generate new TransactionOutputs and so forth.
 // Amount is how much this TransactionOutput is worth.
-// LockingScript is the publicKey of the payee which can be verified by the payee's signature.
+// LockingScript is the publicKey of the payee which can be verified by the payee's signature,
+// or a script that says what it takes to spend the TransactionOutput.
 type TransactionOutput struct {
 	Amount        uint32
 	LockingScript string
@@ -36,7 +40,7 @@ type TransactionOutput struct {
 // Version is the version of this transaction.
 // Inputs is a slice of TransactionInputs.
 // Outputs is a slice of TransactionOutputs.
-// LockTime is the future time after which the Transaction is valid.
+// LockTime is the block height from which the Transaction is valid.
 type Transaction struct {
 	Version  uint32
 	Inputs   []*TransactionInput
@@ -124,12 +128,46 @@ func (tx *Transaction) Hash() string {
 	pt := EncodeTransaction(tx)
 	bytes, err := proto.Marshal(pt)
 	if err != nil {
-		fmt.Errorf("[tx.Hash()] Unable to marshal transaction")
+		logger.Error("unable to marshal transaction", "func", "Transaction.Hash", "err", err)
 	}
 	h.Write(bytes)
 	return fmt.Sprintf("%x", h.Sum(nil))
 }
 
+// SigHash returns what the unlocking script of the input at
+// index signs: the hash of the transaction with every unlocking
+// script left out, and of the index.
+func (tx *Transaction) SigHash(index int) []byte {
+	pt := EncodeTransaction(tx)
+	for _, ptxi := range pt.Inputs {
+		ptxi.UnlockingScript = ""
+	}
+	bytes, err := proto.Marshal(pt)
+	if err != nil {
+		logger.Error("unable to marshal transaction", "func", "Transaction.SigHash", "err", err)
+	}
+	h := sha256.New()
+	h.Write(bytes)
+	binary.Write(h, binary.BigEndian, uint32(index))
+	return h.Sum(nil)
+}
+
+// SignInput generates the unlocking script of the input
+// at index, for a TransactionOutput that is locked to a
+// bare public key. The rest of the transaction must be
+// complete, since the signature covers all of it.
+// Inputs:
+// index	int	the index of the input
+// id	id.ID	the id of the person wanting to
+// unlock the input's TransactionOutput.
+// Returns:
+// string	The signature represented as a hex string.
+// error	Errors if the signature could not be
+// produced.
+func (tx *Transaction) SignInput(index int, id id.ID) (string, error) {
+	return utils.Sign(id.GetPrivateKey(), tx.SigHash(index))
+}
+
 // IsCoinbase returns whether the
 // transaction is a coinbase transaction.
 // Returns:
@@ -164,8 +202,10 @@ func (tx *Transaction) NameTag() string {
 }
 
 // MakeSignature generates
-// an unlocking script (a.k.a. signature) for the
-// transaction output based on a private key.
+// a signature of the transaction output based on a
+// private key. It does not cover the transaction that
+// spends the output, so it does not unlock the output;
+// use Transaction.SignInput for that.
 // Inputs:
 // id	id.ID	the id of the person wanting to
 // unlock the particular transaction output.
@@ -179,12 +219,11 @@ func (txo *TransactionOutput) MakeSignature(id id.ID) (string, error) {
 	ptxo := EncodeTransactionOutput(txo)
 	bytes, err := proto.Marshal(ptxo)
 	if err != nil {
-		fmt.Errorf("[tx.MakeSignature()] Unable to marshal transaction")
+		logger.Error("unable to marshal transaction output", "func", "TransactionOutput.MakeSignature", "err", err)
 	}
 	sig, err := utils.Sign(sk, bytes)
 	if err != nil {
-		fmt.Printf("ERROR {TransactionOutput.MakeSignature}: " +
-			"The signature could not be formed.\n")
+		logger.Error("unable to form signature", "func", "TransactionOutput.MakeSignature", "err", err)
 		return "", nil
 	}
 	return sig, nil
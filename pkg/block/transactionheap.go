@@ -2,7 +2,6 @@ package block
 
 import (
 	"container/heap"
-	"fmt"
 )
 
 // HeapNode (TransactionHeapNode) represents
@@ -38,8 +37,7 @@ func NewTransactionHeap() *Heap {
 // t	*Transaction the new transaction.
 func (h *Heap) Add(p uint32, t *Transaction) {
 	if t == nil {
-		fmt.Printf("ERROR {TxHeap.Add}: " +
-			"received a nil transaction.\n")
+		logger.Error("received a nil transaction", "func", "Heap.Add")
 		return
 	}
 	n := &HeapNode{Priority: p, Transaction: t}
@@ -136,8 +134,7 @@ func (h *Heap) Remove(ts []*Transaction) ([]*Transaction, uint32) {
 	totalPriority := uint32(0)
 	for _, t := range ts {
 		if t == nil {
-			fmt.Printf("ERROR {TxHeap.Rmv}: " +
-				"received a nil transaction.\n")
+			logger.Error("received a nil transaction", "func", "Heap.Rmv")
 			return nil, totalPriority
 		}
 		i, isIn := h.GetIndex(t)
@@ -164,8 +161,7 @@ func (h *Heap) Remove(ts []*Transaction) ([]*Transaction, uint32) {
 // otherwise.
 func (h *Heap) GetIndex(t *Transaction) (int, bool) {
 	if t == nil {
-		fmt.Printf("ERROR {TxHeap.GetIndex}: " +
-			"received a nil transaction.\n")
+		logger.Error("received a nil transaction", "func", "Heap.GetIndex")
 		return 0, false
 	}
 	for i, v := range *h {
@@ -186,8 +182,7 @@ func (h *Heap) GetIndex(t *Transaction) (int, bool) {
 // heap, false otherwise.
 func (h *Heap) Has(t *Transaction) bool {
 	if t == nil {
-		fmt.Printf("ERROR {TxHeap.Has}: " +
-			"received a nil transaction.\n")
+		logger.Error("received a nil transaction", "func", "Heap.Has")
 		return false
 	}
 	for _, tx := range *h {
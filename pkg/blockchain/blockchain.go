@@ -6,7 +6,9 @@ import (
 	"Coin/pkg/blockchain/chainwriter"
 	"Coin/pkg/blockchain/coindatabase"
 	"Coin/pkg/utils"
+	"go.uber.org/atomic"
 	"math"
+	"time"
 )
 
 // BlockChain is the main type of this project.
@@ -20,6 +22,14 @@ import (
 // BlockInfoDB is a pointer to a block info database
 // ChainWriter is a pointer to a chain writer.
 // CoinDB is a pointer to a coin database.
+// TipTime is when the active chain last got a new last block,
+// in Unix nanoseconds.
+// Reorgs is how many times a fork replaced the active chain.
+// ReorgedBlocks is how many blocks those forks reverted.
+// MaxReorgDepth is the most blocks that a single fork reverted.
+// log is the chain's logger, which tags entries with Address.
+// tipObserver, if set, is told how the active chain changed
+// whenever it gets a new last block.
 //TODO: blockchain has to confirm block and also has to listen
 // for when the miner needs to sum inputs
 type BlockChain struct {
@@ -34,6 +44,41 @@ type BlockChain struct {
 	BlockInfoDB *blockinfodatabase.BlockInfoDatabase
 	ChainWriter *chainwriter.ChainWriter
 	CoinDB      *coindatabase.CoinDatabase
+
+	TipTime       *atomic.Int64
+	Reorgs        *atomic.Uint64
+	ReorgedBlocks *atomic.Uint64
+	MaxReorgDepth *atomic.Uint64
+
+	log         *utils.Logger
+	tipObserver func(*TipChange)
+}
+
+// TipChange is how the active chain changed when it got a new
+// last Block.
+// Hash and Height are the hash and height of the new last Block.
+// Connected are the Blocks that joined the active chain, oldest
+// first, and ConnectedUndo are their UndoBlocks. The UndoBlock
+// of a Block that was stored while on a fork may be empty.
+// Disconnected are the Blocks that a fork took off the active
+// chain, newest first, and DisconnectedUndo are their
+// UndoBlocks. They are empty unless the chain reorganized.
+type TipChange struct {
+	Hash   string
+	Height uint32
+
+	Connected     []*block.Block
+	ConnectedUndo []*chainwriter.UndoBlock
+
+	Disconnected     []*block.Block
+	DisconnectedUndo []*chainwriter.UndoBlock
+}
+
+// ObserveTip has the chain tell f how the active chain changed
+// whenever it gets a new last Block. It must be called before
+// the chain handles any Blocks.
+func (bc *BlockChain) ObserveTip(f func(*TipChange)) {
+	bc.tipObserver = f
 }
 
 // New returns a blockchain given a Config.
@@ -59,6 +104,13 @@ func New(config *Config) *BlockChain {
 		BlockInfoDB:  blockinfodatabase.New(blockInfoDBConfig),
 		ChainWriter:  chainwriter.New(chainWriterConfig),
 		CoinDB:       coindatabase.New(coinDBConfig),
+
+		TipTime:       atomic.NewInt64(time.Now().UnixNano()),
+		Reorgs:        atomic.NewUint64(0),
+		ReorgedBlocks: atomic.NewUint64(0),
+		MaxReorgDepth: atomic.NewUint64(0),
+
+		log: utils.NewLogger(utils.ComponentChain),
 	}
 	// have to store the genesis block
 	bc.CoinDB.StoreBlock(genBlock.Transactions)
@@ -69,7 +121,7 @@ func New(config *Config) *BlockChain {
 }
 
 // GenesisBlock creates the genesis Block, using the Config's
-// InitialSubsidy and GenesisPublicKey.
+// InitialSubsidy, GenesisPublicKey and GenesisTimestamp.
 func GenesisBlock(config *Config) *block.Block {
 	txo := &block.TransactionOutput{
 		Amount:        config.InitialSubsidy,
@@ -88,7 +140,7 @@ func GenesisBlock(config *Config) *block.Block {
 			MerkleRoot:       "",
 			DifficultyTarget: "",
 			Nonce:            0,
-			Timestamp:        0,
+			Timestamp:        config.GenesisTimestamp,
 		},
 		Transactions: []*block.Transaction{genTx},
 	}
@@ -132,6 +184,15 @@ func (bc *BlockChain) HandleBlock(b *block.Block) {
 			bc.UnsafeHashes = bc.UnsafeHashes[1:]
 		}
 		bc.UnsafeHashes = append(bc.UnsafeHashes, blockHash)
+		bc.TipTime.Store(time.Now().UnixNano())
+		if bc.tipObserver != nil {
+			bc.tipObserver(&TipChange{
+				Hash:          blockHash,
+				Height:        bc.Length,
+				Connected:     []*block.Block{b},
+				ConnectedUndo: []*chainwriter.UndoBlock{ub},
+			})
+		}
 	} else if height > bc.Length {
 		// 8. Handle fork
 		bc.handleFork(b, height)
@@ -146,10 +207,17 @@ func (bc *BlockChain) handleFork(b *block.Block, height uint32) {
 	// (1) Make sure that this is a valid fork
 	forkLength, ancestorHash := bc.getForkLengthAndAncestor(b.Hash())
 	if forkLength < 0 {
-		utils.Debug.Printf("[blockchain.handleFork] fork was invalid")
+		bc.log.Warn("fork was invalid", "block", b.Hash())
 		return
 	}
 
+	// the observer is told about the whole of both branches,
+	// which is read before the chain changes
+	var change *TipChange
+	if bc.tipObserver != nil {
+		change = bc.reorgChange(b, height, forkLength, ancestorHash)
+	}
+
 	// (2) retrieve the blocks on the existing main chain
 	blocks, undoBlocks := bc.getBlocksAndUndoBlocks(forkLength, bc.LastHash)
 
@@ -175,7 +243,7 @@ func (bc *BlockChain) handleFork(b *block.Block, height uint32) {
 	// (5) Store our new blocks in the coinDB!
 	for _, bl := range blocks {
 		if !bc.CoinDB.ValidateBlock(bl.Transactions) {
-			utils.Debug.Printf("Validation failed for forked block {%v}", b.Hash())
+			bc.log.Warn("validation failed for forked block", "block", bl.Hash(), "fork", b.Hash())
 		}
 		bc.CoinDB.StoreBlock(bl.Transactions)
 	}
@@ -184,6 +252,38 @@ func (bc *BlockChain) handleFork(b *block.Block, height uint32) {
 	bc.LastBlock = b
 	bc.LastHash = b.Hash()
 	bc.Length = height
+	bc.TipTime.Store(time.Now().UnixNano())
+	depth := uint64(len(blocks))
+	bc.Reorgs.Inc()
+	bc.ReorgedBlocks.Add(depth)
+	for max := bc.MaxReorgDepth.Load(); depth > max; max = bc.MaxReorgDepth.Load() {
+		if bc.MaxReorgDepth.CAS(max, depth) {
+			break
+		}
+	}
+	if change != nil {
+		bc.tipObserver(change)
+	}
+}
+
+// reorgChange returns the TipChange of a fork, ending in b at
+// height, replacing the active chain back to its common
+// ancestor.
+func (bc *BlockChain) reorgChange(b *block.Block, height uint32, forkLength int, ancestorHash string) *TipChange {
+	ancestorHeight := bc.BlockInfoDB.GetBlockRecord(ancestorHash).Height
+	disconnected, disconnectedUndo := bc.getBlocksAndUndoBlocks(int(bc.Length-ancestorHeight), bc.LastHash)
+	connected, connectedUndo := bc.getBlocksAndUndoBlocks(forkLength, b.Hash())
+	for i, j := 0, len(connectedUndo)-1; i < j; i, j = i+1, j-1 {
+		connectedUndo[i], connectedUndo[j] = connectedUndo[j], connectedUndo[i]
+	}
+	return &TipChange{
+		Hash:             b.Hash(),
+		Height:           height,
+		Connected:        reverseBlocks(connected),
+		ConnectedUndo:    connectedUndo,
+		Disconnected:     disconnected,
+		DisconnectedUndo: disconnectedUndo,
+	}
 }
 
 // makeUndoBlock returns an UndoBlock given a slice of Transactions.
@@ -235,9 +335,13 @@ func (bc *BlockChain) GetBlock(blockHash string) *block.Block {
 }
 
 // getUndoBlock uses the ChainWriter to retrieve an UndoBlock
-// from Disk given the corresponding Block's hash
+// from Disk given the corresponding Block's hash. Blocks that
+// spend no coins have no UndoBlock on Disk, so theirs is empty.
 func (bc *BlockChain) getUndoBlock(blockHash string) *chainwriter.UndoBlock {
 	br := bc.BlockInfoDB.GetBlockRecord(blockHash)
+	if br.UndoFile == "" {
+		return &chainwriter.UndoBlock{}
+	}
 	fi := &chainwriter.FileInfo{
 		FileName:    br.UndoFile,
 		StartOffset: br.UndoStartOffset,
@@ -251,7 +355,7 @@ func (bc *BlockChain) getUndoBlock(blockHash string) *chainwriter.UndoBlock {
 // GetBlocks(10, 20) returns blocks 10 through 20.
 func (bc *BlockChain) GetBlocks(start, end uint32) []*block.Block {
 	if start >= end || end <= 0 || start <= 0 || end > bc.Length {
-		utils.Debug.Printf("cannot get chain blocks with values start: %v end: %v", start, end)
+		bc.log.Debug("cannot get chain blocks", "start", start, "end", end)
 	}
 
 	var blocks []*block.Block
@@ -280,7 +384,7 @@ func (bc *BlockChain) GetBlocks(start, end uint32) []*block.Block {
 // 50, GetHashes(10, 20) returns the hashes of Blocks 10 through 20.
 func (bc *BlockChain) GetHashes(start, end uint32) []string {
 	if start >= end || end <= 0 || start <= 0 || end > bc.Length {
-		utils.Debug.Printf("cannot get chain blocks with values start: %v end: %v", start, end)
+		bc.log.Debug("cannot get chain hashes", "start", start, "end", end)
 	}
 
 	var hashes []string
@@ -365,6 +469,8 @@ func reverseHashes(s []string) []string {
 
 func (bc *BlockChain) SetAddress(address string) {
 	bc.Address = address
+	bc.log = utils.NewLogger(utils.ComponentChain).With("node", address)
+	bc.CoinDB.SetAddress(address)
 }
 
 func (bc *BlockChain) GetBalance(pk string) uint32 {
@@ -375,6 +481,26 @@ func (bc *BlockChain) List() []*block.Block {
 	return bc.GetBlocks(1, bc.Length)
 }
 
+// FindTransaction looks for a Transaction on the active chain,
+// from the last Block back to the genesis Block. There is no
+// index of transactions, so this reads every Block on the way.
+// Returns:
+// *block.Transaction the transaction, or nil if it is not on
+// the active chain
+// string the hash of the Block that holds it
+// uint32 the height of that Block
+func (bc *BlockChain) FindTransaction(txHash string) (*block.Transaction, string, uint32) {
+	hashes := bc.GetHashes(1, bc.Length)
+	for i := len(hashes) - 1; i >= 0; i-- {
+		for _, tx := range bc.GetBlock(hashes[i]).Transactions {
+			if tx.Hash() == txHash {
+				return tx, hashes[i], uint32(i + 1)
+			}
+		}
+	}
+	return nil, "", 0
+}
+
 // GetInputSums returns a slice of summed transaction input totals, given a slice of transactions.
 // The indexes of the slice of totals correspond to the indexes of the transactions.
 // In other words, the sum of the inputs for txs[3] is sums[3]
@@ -389,7 +515,7 @@ func (bc *BlockChain) GetInputSums(txs []*block.Transaction) []uint32 {
 			}
 			coin := bc.CoinDB.GetCoin(cl)
 			if coin == nil {
-				utils.Debug.Printf("[blockchain.GetCoins] Error: could not find coin")
+				bc.log.Warn("could not find coin", "transaction", cl.ReferenceTransactionHash, "output", cl.OutputIndex)
 			} else {
 				sum += coin.TransactionOutput.Amount
 			}
@@ -398,3 +524,11 @@ func (bc *BlockChain) GetInputSums(txs []*block.Transaction) []uint32 {
 	}
 	return sums
 }
+
+// Close flushes the coin database's cache to disk and closes
+// the chain's databases.
+func (bc *BlockChain) Close() {
+	bc.CoinDB.FlushMainCache()
+	bc.CoinDB.Close()
+	bc.BlockInfoDB.Close()
+}
@@ -7,6 +7,10 @@ import (
 	"google.golang.org/protobuf/proto"
 )
 
+// logger logs for the block info database, which is part of
+// the chain.
+var logger = utils.NewLogger(utils.ComponentChain)
+
 // BlockInfoDatabase is a wrapper for a levelDB
 type BlockInfoDatabase struct {
 	db *leveldb.DB
@@ -16,7 +20,7 @@ type BlockInfoDatabase struct {
 func New(config *Config) *BlockInfoDatabase {
 	db, err := leveldb.OpenFile(config.DatabasePath, nil)
 	if err != nil {
-		utils.Debug.Printf("Unable to initialize BlockInfoDatabase with path {%v}", config.DatabasePath)
+		logger.Error("unable to open block info database", "path", config.DatabasePath, "err", err)
 	}
 	return &BlockInfoDatabase{db: db}
 }
@@ -36,13 +40,13 @@ func (blockInfoDB *BlockInfoDatabase) StoreBlockRecord(hash string, blockRecord
 	bytes, err := proto.Marshal(protoRecord)
 	// checking that the marshalling process didn't throw an error
 	if err != nil {
-		utils.Debug.Printf("Failed to marshal protoRecord:", err)
+		logger.Error("unable to marshal block record", "block", hash, "err", err)
 	}
 	// attempting to store the bytes in our database AND checking to make
 	// sure that the storing process doesn't fail. The Put(key, value, writeOptions)
 	// function is levelDB's.
 	if err = blockInfoDB.db.Put([]byte(hash), bytes, nil); err != nil {
-		utils.Debug.Printf("Unable to store block protoRecord for hash {%v}", hash)
+		logger.Error("unable to store block record", "block", hash, "err", err)
 	}
 }
 
@@ -60,7 +64,7 @@ func (blockInfoDB *BlockInfoDatabase) GetBlockRecord(hash string) *BlockRecord {
 	// The Get(key, writeOptions) function is levelDB's.
 	data, err := blockInfoDB.db.Get([]byte(hash), nil)
 	if err != nil {
-		utils.Debug.Printf("Unable to get block record for hash {%v}", hash)
+		logger.Debug("block record not in database", "block", hash)
 	}
 	// creating a protobuf blockRecord object to fill
 	protoRecord := &pro.BlockRecord{}
@@ -68,12 +72,19 @@ func (blockInfoDB *BlockInfoDatabase) GetBlockRecord(hash string) *BlockRecord {
 	// protobuf object created on line 66. Checking that the conversion process
 	// from bytes to protobuf object succeeds.
 	if err = proto.Unmarshal(data, protoRecord); err != nil {
-		utils.Debug.Printf("Failed to unmarshal record from hash {%v}:", hash, err)
+		logger.Error("unable to unmarshal block record", "block", hash, "err", err)
 	}
 	// convert the protobuf record to a normal blockRecord and returning that.
 	return DecodeBlockRecord(protoRecord)
 }
 
+// HasBlockRecord returns whether the database holds
+// a record for a block.
+func (blockInfoDB *BlockInfoDatabase) HasBlockRecord(hash string) bool {
+	ok, err := blockInfoDB.db.Has([]byte(hash), nil)
+	return err == nil && ok
+}
+
 // Close is used to actually shut down the db (for testing purposes)
 func (blockInfoDB *BlockInfoDatabase) Close() {
 	blockInfoDB.db.Close()
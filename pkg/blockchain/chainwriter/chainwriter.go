@@ -11,6 +11,9 @@ import (
 	"strconv"
 )
 
+// logger logs for the chain writer, which is part of the chain.
+var logger = utils.NewLogger(utils.ComponentChain)
+
 // ChainWriter handles all I/O for the BlockChain. It stores and retrieves
 // Blocks and UndoBlocks.
 // See config.go for more information on its fields.
@@ -64,13 +67,13 @@ func (cw *ChainWriter) StoreBlock(bl *block.Block, undoBlock *UndoBlock, height
 	b := block.EncodeBlock(bl)
 	serializedBlock, err := proto.Marshal(b)
 	if err != nil {
-		utils.Debug.Printf("Failed to marshal block")
+		logger.Error("unable to marshal block", "err", err)
 	}
 	// serialize undo block
 	ub := EncodeUndoBlock(undoBlock)
 	serializedUndoBlock, err := proto.Marshal(ub)
 	if err != nil {
-		utils.Debug.Printf("Failed to marshal undo block")
+		logger.Error("unable to marshal undo block", "err", err)
 	}
 	// write block to disk
 	bfi := cw.WriteBlock(serializedBlock)
@@ -192,7 +195,7 @@ func (cw *ChainWriter) ReadBlock(fi *FileInfo) *block.Block {
 	bytes := readFromDisk(fi)
 	pb := &pro.Block{}
 	if err := proto.Unmarshal(bytes, pb); err != nil {
-		utils.Debug.Printf("failed to unmarshal block from file info {%v}", fi)
+		logger.Error("unable to unmarshal block", "file", fi.FileName, "offset", fi.StartOffset, "err", err)
 	}
 	return block.DecodeBlock(pb)
 }
@@ -202,7 +205,7 @@ func (cw *ChainWriter) ReadUndoBlock(fi *FileInfo) *UndoBlock {
 	bytes := readFromDisk(fi)
 	pub := &pro.UndoBlock{}
 	if err := proto.Unmarshal(bytes, pub); err != nil {
-		utils.Debug.Printf("failed to unmarshal undo block from file info {%v}", fi)
+		logger.Error("unable to unmarshal undo block", "file", fi.FileName, "offset", fi.StartOffset, "err", err)
 	}
 	return DecodeUndoBlock(pub)
 }
@@ -4,10 +4,14 @@ import (
 	"Coin/pkg/block"
 	"Coin/pkg/blockchain/chainwriter"
 	"Coin/pkg/pro"
+	"Coin/pkg/script"
 	"Coin/pkg/utils"
+	"errors"
 	"fmt"
 	"github.com/syndtr/goleveldb/leveldb"
+	"go.uber.org/atomic"
 	"google.golang.org/protobuf/proto"
+	"sync"
 )
 
 // CoinDatabase keeps track of Coins.
@@ -16,62 +20,134 @@ import (
 // mainCacheSize is how many Coins are currently in the mainCache.
 // mainCacheCapacity is the maximum number of Coins that the mainCache
 // can store before it must flush.
+// cacheHits and cacheMisses count the Coins that were looked up
+// in the mainCache, and found or not.
+// flushes counts the times the mainCache was flushed.
+// log is the coin database's logger.
+// mutex guards the mainCache and the db, so that Coins can be
+// looked up while Blocks are stored or undone.
 type CoinDatabase struct {
 	db                *leveldb.DB
 	mainCache         map[CoinLocator]*Coin
 	mainCacheSize     uint32
 	mainCacheCapacity uint32
+
+	cacheHits   *atomic.Uint64
+	cacheMisses *atomic.Uint64
+	flushes     *atomic.Uint64
+
+	log *utils.Logger
+
+	mutex sync.RWMutex
 }
 
 // New returns a CoinDatabase given a Config.
 func New(config *Config) *CoinDatabase {
+	log := utils.NewLogger(utils.ComponentCoinDB)
 	db, err := leveldb.OpenFile(config.DatabasePath, nil)
 	if err != nil {
-		utils.Debug.Printf("Unable to initialize BlockInfoDatabase with path {%v}", config.DatabasePath)
+		log.Error("unable to open database", "path", config.DatabasePath, "err", err)
 	}
 	return &CoinDatabase{
 		db:                db,
 		mainCache:         make(map[CoinLocator]*Coin),
 		mainCacheSize:     0,
 		mainCacheCapacity: config.MainCacheCapacity,
+		cacheHits:         atomic.NewUint64(0),
+		cacheMisses:       atomic.NewUint64(0),
+		flushes:           atomic.NewUint64(0),
+		log:               log,
 	}
 }
 
+// SetAddress tags the coin database's log entries with the
+// address of its node.
+func (coinDB *CoinDatabase) SetAddress(address string) {
+	coinDB.log = utils.NewLogger(utils.ComponentCoinDB).With("node", address)
+}
+
 // ValidateBlock returns whether a Block's Transactions are valid.
+// No two inputs of the Block may spend the same Coin.
 func (coinDB *CoinDatabase) ValidateBlock(transactions []*block.Transaction) bool {
+	coinDB.mutex.RLock()
+	defer coinDB.mutex.RUnlock()
+	spent := make(map[CoinLocator]bool)
 	for _, tx := range transactions {
-		if err := coinDB.ValidateTransaction(tx); err != nil {
-			utils.Debug.Printf("%v", err)
+		for _, txi := range tx.Inputs {
+			key := makeCoinLocator(txi)
+			if spent[key] {
+				coinDB.log.Debug("coin spent twice in block", "transaction", tx.Hash(), "coin", key.ReferenceTransactionHash, "index", key.OutputIndex)
+				return false
+			}
+			spent[key] = true
+		}
+		if err := coinDB.validateTransaction(tx); err != nil {
+			coinDB.log.Debug("invalid transaction", "transaction", tx.Hash(), "err", err)
 			return false
 		}
 	}
 	return true
 }
 
+// ErrLocked is wrapped by the errors of ValidateTransaction for inputs
+// whose unlocking scripts do not unlock their Coins.
+var ErrLocked = errors.New("input does not unlock its coin")
+
 // ValidateTransaction checks whether a Transaction's inputs are valid Coins.
 // If the Coins have already been spent or do not exist, validateTransaction
-// returns an error.
+// returns an error. Each input's unlocking script must also unlock its Coin:
+// it must be the signature of the Transaction by the Coin's public key, or
+// satisfy the Coin's script.
 func (coinDB *CoinDatabase) ValidateTransaction(transaction *block.Transaction) error {
-	for _, txi := range transaction.Inputs {
+	coinDB.mutex.RLock()
+	defer coinDB.mutex.RUnlock()
+	return coinDB.validateTransaction(transaction)
+}
+
+// validateTransaction is ValidateTransaction, for callers that
+// hold the mutex.
+func (coinDB *CoinDatabase) validateTransaction(transaction *block.Transaction) error {
+	for i, txi := range transaction.Inputs {
 		key := makeCoinLocator(txi)
 		if coin, ok := coinDB.mainCache[key]; ok {
+			coinDB.cacheHits.Inc()
 			if coin.IsSpent {
 				return fmt.Errorf("[validateTransaction] coin already spent")
 			}
+			if err := verifyInput(transaction, i, coin.TransactionOutput.LockingScript); err != nil {
+				return err
+			}
 			continue
 		}
+		coinDB.cacheMisses.Inc()
+		var lockingScript string
 		if data, err := coinDB.db.Get([]byte(txi.ReferenceTransactionHash), nil); err != nil {
 			return fmt.Errorf("[validateTransaction] coin not in leveldb")
 		} else {
 			pcr := &pro.CoinRecord{}
 			if err2 := proto.Unmarshal(data, pcr); err2 != nil {
-				utils.Debug.Printf("Failed to unmarshal record from hash {%v}:", txi.ReferenceTransactionHash, err)
+				coinDB.log.Error("unable to unmarshal coin record", "transaction", txi.ReferenceTransactionHash, "err", err2)
 			}
 			cr := DecodeCoinRecord(pcr)
-			if !contains(cr.OutputIndexes, txi.OutputIndex) {
+			index := indexOf(cr.OutputIndexes, txi.OutputIndex)
+			if index < 0 {
 				return fmt.Errorf("[validateTransaction] coinRecord did not contain Coin")
 			}
+			lockingScript = cr.LockingScripts[index]
 		}
+		if err := verifyInput(transaction, i, lockingScript); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// verifyInput checks that the input at index of a Transaction unlocks
+// a Coin's locking script.
+func verifyInput(transaction *block.Transaction, index int, lockingScript string) error {
+	txi := transaction.Inputs[index]
+	if err := script.Verify(txi.UnlockingScript, lockingScript, script.NewTxChecker(transaction, index)); err != nil {
+		return fmt.Errorf("[validateTransaction] input %v: %w: %v", index, ErrLocked, err)
 	}
 	return nil
 }
@@ -88,6 +164,8 @@ func (coinDB *CoinDatabase) ValidateTransaction(transaction *block.Transaction)
 // (3) re-establishes the inputs as usable.
 // Note: Students must fill out this function for their project.
 func (coinDB *CoinDatabase) UndoCoins(blocks []*block.Block, undoBlocks []*chainwriter.UndoBlock) {
+	coinDB.mutex.Lock()
+	defer coinDB.mutex.Unlock()
 	// loop through all the block/undoBlock pairings || len(blocks) = len(undoBlocks)
 	for i := 0; i < len(blocks); i++ {
 		// (1) deal with Blocks: erase the coins and the coin record
@@ -103,7 +181,7 @@ func (coinDB *CoinDatabase) UndoCoins(blocks []*block.Block, undoBlocks []*chain
 			}
 			// delete the coin record
 			if err := coinDB.db.Delete([]byte(tx.Hash()), nil); err != nil {
-				utils.Debug.Printf("[coinDb.UndoCoins] Error while deleting coin record for hash: %v", tx.Hash())
+				coinDB.log.Error("unable to delete coin record", "transaction", tx.Hash(), "err", err)
 			}
 		}
 		// (2) deal with UndoBlocks: re-establish inputs as usable
@@ -151,6 +229,15 @@ func (coinDB *CoinDatabase) addCoinToRecord(cr *CoinRecord, ub *chainwriter.Undo
 
 // FlushMainCache flushes the mainCache to the db.
 func (coinDB *CoinDatabase) FlushMainCache() {
+	coinDB.mutex.Lock()
+	defer coinDB.mutex.Unlock()
+	coinDB.flushMainCache()
+}
+
+// flushMainCache is FlushMainCache, for callers that hold the
+// mutex.
+func (coinDB *CoinDatabase) flushMainCache() {
+	coinDB.flushes.Inc()
 	// update coin records
 	updatedCoinRecords := make(map[string]*CoinRecord)
 	for cl, coin := range coinDB.mainCache {
@@ -170,11 +257,11 @@ func (coinDB *CoinDatabase) FlushMainCache() {
 			// if we haven't already update this coin record, retrieve from db
 			data, err := coinDB.db.Get([]byte(cl.ReferenceTransactionHash), nil)
 			if err != nil {
-				utils.Debug.Printf("[FlushMainCache] coin record not in leveldb")
+				coinDB.log.Debug("coin record not in database", "transaction", cl.ReferenceTransactionHash)
 			}
 			pcr := &pro.CoinRecord{}
 			if err = proto.Unmarshal(data, pcr); err != nil {
-				utils.Debug.Printf("Failed to unmarshal record from hash {%v}:%v", cl.ReferenceTransactionHash, err)
+				coinDB.log.Error("unable to unmarshal coin record", "transaction", cl.ReferenceTransactionHash, "err", err)
 			}
 			cr = DecodeCoinRecord(pcr)
 		}
@@ -190,7 +277,7 @@ func (coinDB *CoinDatabase) FlushMainCache() {
 		if len(cr.OutputIndexes) == 0 {
 			err := coinDB.db.Delete([]byte(key), nil)
 			if err != nil {
-				utils.Debug.Printf("[FlushMainCache] failed to delete key {%v}", key)
+				coinDB.log.Error("unable to delete coin record", "transaction", key, "err", err)
 			}
 		} else {
 			coinDB.putRecordInDB(key, cr)
@@ -207,6 +294,8 @@ func (coinDB *CoinDatabase) FlushMainCache() {
 // make our lives easier. You should PUSH students to do the same, but they don't
 // have to.
 func (coinDB *CoinDatabase) StoreBlock(transactions []*block.Transaction) {
+	coinDB.mutex.Lock()
+	defer coinDB.mutex.Unlock()
 	coinDB.updateSpentCoins(transactions)
 	coinDB.storeTransactionsInMainCache(transactions)
 	coinDB.storeTransactionsInDB(transactions)
@@ -248,7 +337,7 @@ func (coinDB *CoinDatabase) removeCoinFromDB(txHash string, cl CoinLocator) {
 		return
 	case len(cr.Amounts) <= 1:
 		if err := coinDB.db.Delete([]byte(txHash), nil); err != nil {
-			utils.Debug.Printf("[removeCoinFromDB] failed to remove {%v} from db", txHash)
+			coinDB.log.Error("unable to delete coin record", "transaction", txHash, "err", err)
 		}
 	default:
 		cr = coinDB.removeCoinFromRecord(cr, cl.OutputIndex)
@@ -261,10 +350,10 @@ func (coinDB *CoinDatabase) putRecordInDB(txHash string, cr *CoinRecord) {
 	record := EncodeCoinRecord(cr)
 	bytes, err := proto.Marshal(record)
 	if err != nil {
-		utils.Debug.Printf("[coindatabase.putRecordInDB] Unable to marshal coin record for key {%v}", txHash)
+		coinDB.log.Error("unable to marshal coin record", "transaction", txHash, "err", err)
 	}
 	if err2 := coinDB.db.Put([]byte(txHash), bytes, nil); err2 != nil {
-		utils.Debug.Printf("Unable to store coin record for key {%v}", txHash)
+		coinDB.log.Error("unable to store coin record", "transaction", txHash, "err", err2)
 	}
 }
 
@@ -301,7 +390,7 @@ func (coinDB *CoinDatabase) storeTransactionsInMainCache(transactions []*block.T
 		for i, txo := range tx.Outputs {
 			// check whether we're approaching our capacity and flush if we are
 			if coinDB.mainCacheSize+uint32(len(tx.Outputs)) >= coinDB.mainCacheCapacity {
-				coinDB.FlushMainCache()
+				coinDB.flushMainCache()
 			}
 			// actually create the coin
 			coin := &Coin{
@@ -358,12 +447,12 @@ func (coinDB *CoinDatabase) createCoinRecord(tx *block.Transaction) *CoinRecord
 // getCoinRecordFromDB returns a CoinRecord from the db given a hash.
 func (coinDB *CoinDatabase) getCoinRecordFromDB(txHash string) *CoinRecord {
 	if data, err := coinDB.db.Get([]byte(txHash), nil); err != nil {
-		utils.Debug.Printf("[getCoinRecordFromDB] coin not in leveldb")
+		coinDB.log.Debug("coin record not in database", "transaction", txHash)
 		return nil
 	} else {
 		pcr := &pro.CoinRecord{}
 		if err = proto.Unmarshal(data, pcr); err != nil {
-			utils.Debug.Printf("Failed to unmarshal record from hash {%v}:", txHash, err)
+			coinDB.log.Error("unable to unmarshal coin record", "transaction", txHash, "err", err)
 		}
 		cr := DecodeCoinRecord(pcr)
 		return cr
@@ -374,9 +463,13 @@ func (coinDB *CoinDatabase) getCoinRecordFromDB(txHash string) *CoinRecord {
 // mainCache, then checks the db. If the Coin doesn't exist,
 // it returns nil.
 func (coinDB *CoinDatabase) GetCoin(cl CoinLocator) *Coin {
+	coinDB.mutex.RLock()
+	defer coinDB.mutex.RUnlock()
 	if coin, ok := coinDB.mainCache[cl]; ok {
+		coinDB.cacheHits.Inc()
 		return coin
 	}
+	coinDB.cacheMisses.Inc()
 	cr := coinDB.getCoinRecordFromDB(cl.ReferenceTransactionHash)
 	if cr == nil {
 		return nil
@@ -396,24 +489,60 @@ func (coinDB *CoinDatabase) GetCoin(cl CoinLocator) *Coin {
 
 //GetBalance returns the current balance of the publicKey
 func (coinDB *CoinDatabase) GetBalance(publicKey string) uint32 {
-	coinDB.FlushMainCache()
 	balance := uint32(0)
+	_, coins := coinDB.GetUnspentCoins(publicKey)
+	for _, coin := range coins {
+		balance += coin.TransactionOutput.Amount
+	}
+	return balance
+}
+
+// GetUnspentCoins returns the unspent Coins locked to a public key,
+// along with the CoinLocators that spend them. Coins that are still
+// in the db, but were spent in the mainCache, are left out, so the
+// mainCache does not have to be flushed.
+func (coinDB *CoinDatabase) GetUnspentCoins(publicKey string) ([]CoinLocator, []*Coin) {
+	coinDB.mutex.RLock()
+	defer coinDB.mutex.RUnlock()
+	var locators []CoinLocator
+	var coins []*Coin
 	iterator := coinDB.db.NewIterator(nil, nil)
 	for iterator.Next() {
-		value := iterator.Value()
 		pcr := &pro.CoinRecord{}
-		if err := proto.Unmarshal(value, pcr); err != nil {
-			utils.Debug.Printf("[GetBalance] Failed to unmarshal record from coinDB iterator:")
+		if err := proto.Unmarshal(iterator.Value(), pcr); err != nil {
+			coinDB.log.Error("unable to unmarshal coin record", "transaction", string(iterator.Key()), "err", err)
+			continue
 		}
 		cr := DecodeCoinRecord(pcr)
 		for i, pK := range cr.LockingScripts {
-			if pK == publicKey {
-				balance += cr.Amounts[i]
+			if !script.PaysTo(pK, publicKey) {
+				continue
+			}
+			cl := CoinLocator{
+				ReferenceTransactionHash: string(iterator.Key()),
+				OutputIndex:              cr.OutputIndexes[i],
+			}
+			if coin, ok := coinDB.mainCache[cl]; ok && coin.IsSpent {
+				continue
 			}
+			locators = append(locators, cl)
+			coins = append(coins, &Coin{
+				TransactionOutput: &block.TransactionOutput{
+					Amount:        cr.Amounts[i],
+					LockingScript: pK,
+				},
+			})
 		}
 	}
 	iterator.Release()
-	return balance
+	return locators, coins
+}
+
+// CacheStats returns how many Coins were looked up in the mainCache
+// and found, how many were not, and how many times the mainCache
+// was flushed.
+func (coinDB *CoinDatabase) CacheStats() (hits, misses, flushes uint64) {
+	return coinDB.cacheHits.Load(), coinDB.cacheMisses.Load(), coinDB.flushes.Load()
 }
 
 // contains returns true if an int slice s contains element e, false if it does not.
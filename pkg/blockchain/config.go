@@ -10,6 +10,7 @@ import (
 type Config struct {
 	GenesisPublicKey  string
 	InitialSubsidy    uint32
+	GenesisTimestamp  uint32
 	HasChain          bool
 	BlockInfoDBPath   string
 	ChainWriterDBPath string
@@ -31,6 +32,7 @@ func DefaultConfig() *Config {
 	return &Config{
 		GenesisPublicKey:  GENPK,
 		InitialSubsidy:    0,
+		GenesisTimestamp:  0,
 		HasChain:          true,
 		BlockInfoDBPath:   blockinfodatabase.DefaultConfig().DatabasePath,
 		ChainWriterDBPath: chainwriter.DefaultConfig().DataDirectory,
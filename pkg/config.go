@@ -1,41 +1,145 @@
 package pkg
 
 import (
+	"Coin/pkg/address"
 	"Coin/pkg/blockchain"
 	"Coin/pkg/id"
 	"Coin/pkg/miner"
+	"Coin/pkg/network"
+	"Coin/pkg/notify"
+	"Coin/pkg/peer"
+	"Coin/pkg/utils"
 	"Coin/pkg/wallet"
 	"time"
 )
 
+// UserAgent is the name and version of this software, as
+// advertised to other nodes during the handshake.
+const UserAgent = "/coin:0.1.0/"
+
+// AdminTokenFile is the name of the file, in a node's data
+// directory, that holds the token of its Admin service when
+// none is configured.
+const AdminTokenFile = "admin.token"
+
 // Config is the configuration for the node.
+// Network is the network the node is on. Use SetNetwork to
+// change it, which also sets the genesis and consensus
+// parameters of the other configs,
 // IdConf is the configuration for the id,
+// AddressConfig is the configuration for the pool of
+// connections to other nodes,
+// PeerConfig is the configuration for scoring and banning
+// misbehaving peers,
 // MinerConfig is the configuration for the miner,
 // WalletConfig is the configuration for the wallet,
 // ChainConfig is the configuration for the blockchain,
+// NotifyConfig is the configuration for the notifications
+// that the node pushes to subscribers,
+// LogConfig is the configuration for logging. Logging is
+// shared by every node in the process, so the program that
+// runs the node applies it with utils.ConfigureLogging,
 // Version is the version that the node is (used for
-// software updates),
+// software updates). It is the highest protocol version
+// the node speaks,
+// MinVersion is the lowest protocol version the node
+// speaks. Nodes whose version range does not overlap
+// are not peered with,
+// UserAgent is the name and version of the software
+// that the node advertises to its peers,
 // PeerLimit is the maximum amount of peers the node
 // is allowed to have,
+// TargetOutbound is the number of peers that the node
+// connects to on its own and keeps connected to,
+// MaxInbound is the maximum amount of peers that may
+// connect to the node,
+// Seeds are addresses the node connects to when it does
+// not know enough other nodes,
+// ConnectInterval is how often the node replaces outbound
+// peers that dropped,
+// DiscoveryInterval is how often the node asks a peer for
+// the addresses it knows,
 // AddressLimit is the maximum amount of addresses the
 // node is allowed to keep track of.
+// AddressDBPath is where known addresses are persisted. If it
+// is empty, addresses are only kept in memory.
 // Port is the port that the node should run on,
+// ListenAddr is the address the node accepts connections
+// on, such as "0.0.0.0:8000", "[::1]:8000" or
+// "localhost:8000". If it is empty, the node listens on
+// its hostname and Port,
+// AdvertiseAddr is the address the node tells other nodes
+// to reach it at. If it is empty, it is ListenAddr, or the
+// node's hostname and ListenAddr's port if ListenAddr does
+// not name a host,
+// NoListen is whether the node does not accept connections
+// at all. Such a node only connects out to other nodes,
+// AdminAddr is the address the node serves its Admin
+// service on, which lets its operator query and control it.
+// If it is empty, the Admin service is not served,
+// AdminToken is the token that every call to the Admin
+// service must carry. If it is empty, calls are not
+// checked, so the service is only served on addresses
+// that are reachable from this machine alone,
+// GatewayAddr is the address the node serves its HTTP
+// gateway on, which lets applications read the chain and
+// submit transactions as JSON. If it is empty, the gateway
+// is not served,
+// MetricsAddr is the address the node serves its metrics
+// on, at /metrics, in the Prometheus text format. If it is
+// empty, the metrics are not served,
+// ExplorerAddr is the address the node serves its block
+// explorer on, which shows its chain, mempool and peers as
+// web pages. If it is empty, the explorer is not served,
+// VersionTimeout is how long the node waits for a version
+// in response to the version it sent,
+// SeenCacheSize is how many transactions, and how many
+// blocks, the node remembers having seen, so that it does
+// not relay them again,
+// SeenCacheTTL is how long the node remembers having seen
+// a transaction or block,
 // MaxBlockSize is the maximum allowed block size,
 type Config struct {
-	IdConfig     *id.Config
-	MinerConfig  *miner.Config
-	WalletConfig *wallet.Config
-	ChainConfig  *blockchain.Config
+	Network *network.Params
+
+	IdConfig      *id.Config
+	AddressConfig *address.Config
+	PeerConfig    *peer.Config
+	MinerConfig   *miner.Config
+	WalletConfig  *wallet.Config
+	ChainConfig   *blockchain.Config
+	NotifyConfig  *notify.Config
+	LogConfig     *utils.LogConfig
 
 	HasCustomId bool
 	CustomID    id.ID
 
 	Version        int
+	MinVersion     int
+	UserAgent      string
 	PeerLimit      int
+	TargetOutbound int
+	MaxInbound     int
+	Seeds          []string
 	AddressLimit   int
+	AddressDBPath  string
 	Port           int
+	ListenAddr     string
+	AdvertiseAddr  string
+	NoListen       bool
+	AdminAddr      string
+	AdminToken     string
+	GatewayAddr    string
+	MetricsAddr    string
+	ExplorerAddr   string
 	VersionTimeout time.Duration
 
+	ConnectInterval   time.Duration
+	DiscoveryInterval time.Duration
+
+	SeenCacheSize int
+	SeenCacheTTL  time.Duration
+
 	MaxBlockSize uint32
 }
 
@@ -50,33 +154,68 @@ type Config struct {
 // on
 func DefaultConfig(port int) *Config {
 	c := &Config{
-		IdConfig:       id.DefaultConfig(),
-		MinerConfig:    miner.DefaultConfig(-1),
-		WalletConfig:   wallet.DefaultConfig(),
-		ChainConfig:    blockchain.DefaultConfig(),
-		Version:        0,
-		PeerLimit:      20,
-		AddressLimit:   1000,
-		Port:           port,
-		VersionTimeout: time.Second * 2,
-		MaxBlockSize:   10000000,
+		IdConfig:          id.DefaultConfig(),
+		AddressConfig:     address.DefaultConfig(),
+		PeerConfig:        peer.DefaultConfig(),
+		MinerConfig:       miner.DefaultConfig(-1),
+		WalletConfig:      wallet.DefaultConfig(),
+		ChainConfig:       blockchain.DefaultConfig(),
+		NotifyConfig:      notify.DefaultConfig(),
+		LogConfig:         utils.DefaultLogConfig(),
+		Version:           0,
+		MinVersion:        0,
+		UserAgent:         UserAgent,
+		PeerLimit:         20,
+		TargetOutbound:    8,
+		MaxInbound:        12,
+		AddressLimit:      1000,
+		AddressDBPath:     "addressdata",
+		Port:              port,
+		VersionTimeout:    time.Second * 2,
+		ConnectInterval:   time.Second * 30,
+		DiscoveryInterval: time.Minute * 2,
+		SeenCacheSize:     50000,
+		SeenCacheTTL:      time.Hour,
+		MaxBlockSize:      10000000,
 	}
+	c.SetNetwork(network.Mainnet)
 	return c
 }
 
+// TestingConfig is a configuration for nodes in tests. It
+// stays on the main network, so that testing nodes share the
+// genesis block of, and peer with, nodes made from
+// DefaultConfig.
+// Inputs:
+// port int the port that the node should start
+// on
 func TestingConfig(port int) *Config {
 	c := &Config{
-		IdConfig:       id.DefaultConfig(),
-		MinerConfig:    miner.DefaultConfig(-1),
-		WalletConfig:   wallet.DefaultConfig(),
-		ChainConfig:    blockchain.DefaultConfig(),
-		Version:        0,
-		PeerLimit:      20,
-		AddressLimit:   1000,
-		Port:           port,
-		VersionTimeout: time.Second * 2,
-		MaxBlockSize:   10000000,
+		IdConfig:          id.DefaultConfig(),
+		AddressConfig:     address.DefaultConfig(),
+		PeerConfig:        peer.DefaultConfig(),
+		MinerConfig:       miner.DefaultConfig(-1),
+		WalletConfig:      wallet.DefaultConfig(),
+		ChainConfig:       blockchain.DefaultConfig(),
+		NotifyConfig:      notify.DefaultConfig(),
+		LogConfig:         utils.DefaultLogConfig(),
+		Version:           0,
+		MinVersion:        0,
+		UserAgent:         UserAgent,
+		PeerLimit:         20,
+		TargetOutbound:    8,
+		MaxInbound:        12,
+		AddressLimit:      1000,
+		AddressDBPath:     "addressdata",
+		Port:              port,
+		VersionTimeout:    time.Second * 2,
+		ConnectInterval:   time.Second * 30,
+		DiscoveryInterval: time.Minute * 2,
+		SeenCacheSize:     50000,
+		SeenCacheTTL:      time.Hour,
+		MaxBlockSize:      10000000,
 	}
+	c.SetNetwork(network.Mainnet)
 	return c
 }
 
@@ -85,18 +224,49 @@ func TestingConfig(port int) *Config {
 // Inputs:
 // port int the port that the node should start
 // on
-// TODO: do we still want this?
 func NoMinerConfig(port int) *Config {
-	return &Config{
-		IdConfig:       id.DefaultConfig(),
-		MinerConfig:    nil,
-		WalletConfig:   wallet.DefaultConfig(),
-		ChainConfig:    blockchain.DefaultConfig(),
-		Version:        1,
-		PeerLimit:      20,
-		AddressLimit:   1000,
-		Port:           port,
-		VersionTimeout: time.Second * 2,
-		MaxBlockSize:   10000000,
+	c := &Config{
+		IdConfig:          id.DefaultConfig(),
+		AddressConfig:     address.DefaultConfig(),
+		PeerConfig:        peer.DefaultConfig(),
+		MinerConfig:       miner.NilConfig(),
+		WalletConfig:      wallet.DefaultConfig(),
+		ChainConfig:       blockchain.DefaultConfig(),
+		NotifyConfig:      notify.DefaultConfig(),
+		LogConfig:         utils.DefaultLogConfig(),
+		Version:           1,
+		MinVersion:        0,
+		UserAgent:         UserAgent,
+		PeerLimit:         20,
+		TargetOutbound:    8,
+		MaxInbound:        12,
+		AddressLimit:      1000,
+		AddressDBPath:     "addressdata",
+		Port:              port,
+		VersionTimeout:    time.Second * 2,
+		ConnectInterval:   time.Second * 30,
+		DiscoveryInterval: time.Minute * 2,
+		SeenCacheSize:     50000,
+		SeenCacheTTL:      time.Hour,
+		MaxBlockSize:      10000000,
+	}
+	c.SetNetwork(network.Mainnet)
+	return c
+}
+
+// SetNetwork puts the node on a network, setting the genesis
+// and consensus parameters of the chain, the miner and the
+// node to the network's.
+// Inputs:
+// p *network.Params the network to put the node on
+func (c *Config) SetNetwork(p *network.Params) {
+	c.Network = p
+	p.ApplyChain(c.ChainConfig)
+	if c.MinerConfig != nil {
+		c.MinerConfig.InitialSubsidy = p.InitialSubsidy
+		c.MinerConfig.SubsidyHalvingRate = p.SubsidyHalvingRate
+		c.MinerConfig.MaxHalvings = p.MaxHalvings
+		c.MinerConfig.InitialPOWDifficulty = utils.CalcPOWD(p.POWDifficultyZeros)
 	}
+	c.MaxBlockSize = p.MaxBlockSize
 }
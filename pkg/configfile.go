@@ -0,0 +1,505 @@
+package pkg
+
+import (
+	"Coin/pkg/network"
+	"Coin/pkg/utils"
+	"bytes"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io/ioutil"
+	"net"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// EnvPrefix is the prefix of the environment variables that
+// override settings. The variable for a setting is its name in
+// upper case, such as COIN_LISTEN_ADDR for listen_addr.
+const EnvPrefix = "COIN_"
+
+// LoadConfig builds a node configuration from, in increasing
+// order of precedence: the defaults of the chosen network, a
+// JSON config file, environment variables and command line
+// flags. The file is named by the -config flag or the
+// COIN_CONFIG environment variable. Its keys are the setting
+// names, such as "listen_addr", and the flags have the same
+// names, such as -listen_addr. Durations are given as
+// strings like "30s", and lists either as JSON arrays or as
+// comma separated strings. Unlike with DefaultConfig, the
+// Admin service is served by default, on localhost at the
+// port after the network's default port. The configuration
+// is validated before it is returned.
+// Inputs:
+// fs *flag.FlagSet the flag set to register the settings on.
+// Callers may register flags of their own before calling.
+// args []string the command line arguments, without the
+// program name
+// Returns:
+// *Config the configuration
+// error if a setting could not be read, or the settings are
+// inconsistent
+func LoadConfig(fs *flag.FlagSet, args []string) (*Config, error) {
+	path := fs.String("config", "", "path of the JSON config file")
+	netName := fs.String("network", network.Mainnet.Name,
+		fmt.Sprintf("network to join, one of %v", network.Names()))
+	// the flags write to a scratch config. They are applied to
+	// the real one last, so that they override everything else.
+	shown := DefaultConfig(network.Mainnet.DefaultPort)
+	shown.AdminAddr = DefaultAdminAddr(network.Mainnet)
+	shown.bindSettings(fs)
+	if err := fs.Parse(args); err != nil {
+		return nil, err
+	}
+	flags := make(map[string]string)
+	fs.Visit(func(f *flag.Flag) {
+		flags[f.Name] = f.Value.String()
+	})
+	if _, ok := flags["config"]; !ok {
+		*path = os.Getenv(EnvPrefix + "CONFIG")
+	}
+
+	file := make(map[string]string)
+	if *path != "" {
+		var err error
+		if file, err = readConfigFile(*path); err != nil {
+			return nil, err
+		}
+	}
+
+	// the network decides the defaults, so it is chosen first
+	if v, ok := file["network"]; ok {
+		*netName = v
+	}
+	if v, ok := os.LookupEnv(EnvPrefix + "NETWORK"); ok {
+		*netName = v
+	}
+	if v, ok := flags["network"]; ok {
+		*netName = v
+	}
+	p, err := network.Lookup(*netName)
+	if err != nil {
+		return nil, err
+	}
+	c := DefaultConfig(p.DefaultPort)
+	c.SetNetwork(p)
+	c.AdminAddr = DefaultAdminAddr(p)
+
+	settings := flag.NewFlagSet("settings", flag.ContinueOnError)
+	c.bindSettings(settings)
+	for _, name := range sortedKeys(file) {
+		if name == "network" {
+			continue
+		}
+		if err := setSetting(settings, name, file[name]); err != nil {
+			return nil, fmt.Errorf("%v: %v", *path, err)
+		}
+	}
+	var envErr error
+	settings.VisitAll(func(f *flag.Flag) {
+		env := EnvPrefix + strings.ToUpper(f.Name)
+		if v, ok := os.LookupEnv(env); ok && envErr == nil {
+			if err := f.Value.Set(v); err != nil {
+				envErr = fmt.Errorf("%v: %v", env, err)
+			}
+		}
+	})
+	if envErr != nil {
+		return nil, envErr
+	}
+	for _, name := range sortedKeys(flags) {
+		if settings.Lookup(name) != nil {
+			_ = settings.Set(name, flags[name])
+		}
+	}
+	if err := c.Validate(); err != nil {
+		return nil, err
+	}
+	return c, nil
+}
+
+// DefaultAdminAddr returns the address the Admin service is
+// served on by default on a network.
+func DefaultAdminAddr(p *network.Params) string {
+	return net.JoinHostPort("127.0.0.1", strconv.Itoa(p.DefaultPort+1))
+}
+
+// bindSettings registers the settings that can be loaded on
+// a flag set, bound to the fields of the config.
+func (c *Config) bindSettings(fs *flag.FlagSet) {
+	fs.IntVar(&c.Port, "port", c.Port, "port to listen on, if listen_addr is not set")
+	fs.StringVar(&c.ListenAddr, "listen_addr", c.ListenAddr, "address to accept connections on")
+	fs.StringVar(&c.AdvertiseAddr, "advertise_addr", c.AdvertiseAddr, "address other nodes should reach this node at")
+	fs.BoolVar(&c.NoListen, "no_listen", c.NoListen, "do not accept connections, only connect out")
+	fs.StringVar(&c.AdminAddr, "admin_addr", c.AdminAddr, "address to serve the Admin service on, empty for none")
+	fs.StringVar(&c.AdminToken, "admin_token", c.AdminToken, "token that Admin calls must carry, empty for none")
+	fs.StringVar(&c.GatewayAddr, "gateway_addr", c.GatewayAddr, "address to serve the HTTP gateway on, empty for none")
+	fs.StringVar(&c.MetricsAddr, "metrics_addr", c.MetricsAddr, "address to serve metrics on, empty for none")
+	fs.StringVar(&c.ExplorerAddr, "explorer_addr", c.ExplorerAddr, "address to serve the block explorer on, empty for none")
+	fs.Var((*listValue)(&c.Seeds), "seeds", "comma separated addresses to connect to when no others are known")
+	fs.IntVar(&c.MinVersion, "min_version", c.MinVersion, "lowest protocol version to peer with")
+	fs.IntVar(&c.PeerLimit, "peer_limit", c.PeerLimit, "maximum number of peers")
+	fs.IntVar(&c.TargetOutbound, "target_outbound", c.TargetOutbound, "number of peers to connect to")
+	fs.IntVar(&c.MaxInbound, "max_inbound", c.MaxInbound, "maximum number of peers that connect to this node")
+	fs.IntVar(&c.AddressLimit, "address_limit", c.AddressLimit, "maximum number of known addresses")
+	fs.StringVar(&c.AddressDBPath, "address_db_path", c.AddressDBPath, "where to keep known addresses, empty for memory only")
+	fs.DurationVar(&c.VersionTimeout, "version_timeout", c.VersionTimeout, "how long to wait for a version in response")
+	fs.DurationVar(&c.ConnectInterval, "connect_interval", c.ConnectInterval, "how often to replace dropped peers")
+	fs.DurationVar(&c.DiscoveryInterval, "discovery_interval", c.DiscoveryInterval, "how often to ask peers for addresses")
+	fs.IntVar(&c.SeenCacheSize, "seen_cache_size", c.SeenCacheSize, "number of transactions and blocks remembered as seen")
+	fs.DurationVar(&c.SeenCacheTTL, "seen_cache_ttl", c.SeenCacheTTL, "how long transactions and blocks are remembered as seen")
+
+	fs.BoolVar(&c.AddressConfig.SecureTransport, "secure_transport", c.AddressConfig.SecureTransport, "use TLS between nodes")
+	fs.DurationVar(&c.AddressConfig.IdleTimeout, "idle_timeout", c.AddressConfig.IdleTimeout, "how long unused connections are kept open")
+	fs.IntVar(&c.AddressConfig.MaxMessageSize, "max_message_size", c.AddressConfig.MaxMessageSize, "maximum size of a message in bytes")
+
+	fs.Var((*uint32Value)(&c.PeerConfig.BanThreshold), "ban_threshold", "misbehavior score at which peers are banned")
+	fs.DurationVar(&c.PeerConfig.BanDuration, "ban_duration", c.PeerConfig.BanDuration, "how long peers are banned for")
+	fs.StringVar(&c.PeerConfig.BanListPath, "ban_list_path", c.PeerConfig.BanListPath, "where to keep bans")
+	fs.DurationVar(&c.PeerConfig.PingInterval, "ping_interval", c.PeerConfig.PingInterval, "how often peers are pinged")
+	fs.Var((*uint32Value)(&c.PeerConfig.MaxMissedPings), "max_missed_pings", "missed pings after which a peer is dropped")
+
+	fs.BoolVar(&c.MinerConfig.HasMiner, "miner", c.MinerConfig.HasMiner, "run a miner")
+	fs.Var((*uint32Value)(&c.MinerConfig.TransactionPoolCapacity), "tx_pool_capacity", "maximum number of transactions waiting to be mined")
+	fs.BoolVar(&c.WalletConfig.HasWallet, "wallet", c.WalletConfig.HasWallet, "run a wallet")
+
+	fs.StringVar(&c.ChainConfig.BlockInfoDBPath, "block_info_db_path", c.ChainConfig.BlockInfoDBPath, "where to keep block records")
+	fs.StringVar(&c.ChainConfig.ChainWriterDBPath, "chain_writer_db_path", c.ChainConfig.ChainWriterDBPath, "where to keep blocks")
+	fs.StringVar(&c.ChainConfig.CoinDBPath, "coin_db_path", c.ChainConfig.CoinDBPath, "where to keep coins")
+
+	fs.IntVar(&c.NotifyConfig.BufferSize, "notify_buffer_size", c.NotifyConfig.BufferSize, "events a subscriber may fall behind by before it is dropped")
+	fs.IntVar(&c.NotifyConfig.MaxSubscribers, "notify_max_subscribers", c.NotifyConfig.MaxSubscribers, "maximum number of event subscribers")
+
+	fs.Var((*levelValue)(&c.LogConfig.Level), "log_level", "lowest level to log: debug, info, warn, error or off")
+	fs.Var((*levelsValue)(&c.LogConfig.Levels), "log_levels", "comma separated component=level pairs that override log_level, such as p2p=debug")
+	fs.StringVar(&c.LogConfig.Format, "log_format", c.LogConfig.Format, "format of log entries: text or json")
+	fs.BoolVar(&c.LogConfig.Color, "log_color", c.LogConfig.Color, "color the levels of text log entries")
+}
+
+// setSetting sets a setting on a flag set of bound settings.
+func setSetting(fs *flag.FlagSet, name string, value string) error {
+	if fs.Lookup(name) == nil {
+		return fmt.Errorf("unknown setting %q", name)
+	}
+	if err := fs.Set(name, value); err != nil {
+		return fmt.Errorf("invalid value %q for %v: %v", value, name, err)
+	}
+	return nil
+}
+
+// readConfigFile reads a JSON config file into the string
+// form of each of its settings.
+func readConfigFile(path string) (map[string]string, error) {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	raw := make(map[string]interface{})
+	dec := json.NewDecoder(bytes.NewReader(data))
+	dec.UseNumber()
+	if err := dec.Decode(&raw); err != nil {
+		return nil, fmt.Errorf("%v: %v", path, err)
+	}
+	values := make(map[string]string, len(raw))
+	for name, v := range raw {
+		s, err := settingString(v)
+		if err != nil {
+			return nil, fmt.Errorf("%v: %v: %v", path, name, err)
+		}
+		values[name] = s
+	}
+	return values, nil
+}
+
+// settingString returns the string form of a JSON value, as
+// it would be given on the command line.
+func settingString(v interface{}) (string, error) {
+	switch v := v.(type) {
+	case string:
+		return v, nil
+	case json.Number:
+		return v.String(), nil
+	case bool:
+		return strconv.FormatBool(v), nil
+	case []interface{}:
+		items := make([]string, 0, len(v))
+		for _, item := range v {
+			s, ok := item.(string)
+			if !ok {
+				return "", fmt.Errorf("lists may only hold strings")
+			}
+			items = append(items, s)
+		}
+		return strings.Join(items, ","), nil
+	default:
+		return "", fmt.Errorf("unsupported value %v", v)
+	}
+}
+
+// sortedKeys returns the keys of a map, sorted, so that
+// settings are applied and reported in a stable order.
+func sortedKeys(m map[string]string) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// listValue is a flag.Value for a comma separated list.
+type listValue []string
+
+func (l *listValue) String() string {
+	if l == nil {
+		return ""
+	}
+	return strings.Join(*l, ",")
+}
+
+func (l *listValue) Set(s string) error {
+	*l = nil
+	for _, item := range strings.Split(s, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			*l = append(*l, item)
+		}
+	}
+	return nil
+}
+
+// uint32Value is a flag.Value for a uint32.
+type uint32Value uint32
+
+func (u *uint32Value) String() string {
+	if u == nil {
+		return "0"
+	}
+	return strconv.FormatUint(uint64(*u), 10)
+}
+
+func (u *uint32Value) Set(s string) error {
+	v, err := strconv.ParseUint(s, 10, 32)
+	if err != nil {
+		return err
+	}
+	*u = uint32Value(v)
+	return nil
+}
+
+// levelValue is a flag.Value for a log level.
+type levelValue utils.Level
+
+func (l *levelValue) String() string {
+	if l == nil {
+		return utils.LevelInfo.String()
+	}
+	return utils.Level(*l).String()
+}
+
+func (l *levelValue) Set(s string) error {
+	level, err := utils.ParseLevel(s)
+	if err != nil {
+		return err
+	}
+	*l = levelValue(level)
+	return nil
+}
+
+// levelsValue is a flag.Value for per-component log levels.
+type levelsValue map[string]utils.Level
+
+func (l *levelsValue) String() string {
+	if l == nil {
+		return ""
+	}
+	return utils.FormatLevels(*l)
+}
+
+func (l *levelsValue) Set(s string) error {
+	levels, err := utils.ParseLevels(s)
+	if err != nil {
+		return err
+	}
+	*l = levels
+	return nil
+}
+
+// ValidationError lists the problems found with a
+// configuration.
+// Problems are descriptions of each problem.
+type ValidationError struct {
+	Problems []string
+}
+
+func (e *ValidationError) Error() string {
+	return "invalid config: " + strings.Join(e.Problems, "; ")
+}
+
+// Validate checks that the settings of a configuration are
+// usable and consistent with each other.
+// Returns:
+// error a *ValidationError listing every problem found, or
+// nil if there are none
+func (c *Config) Validate() error {
+	var problems []string
+	problem := func(format string, a ...interface{}) {
+		problems = append(problems, fmt.Sprintf(format, a...))
+	}
+	if c.Network == nil {
+		problem("no network is set")
+	}
+	if c.IdConfig == nil || c.AddressConfig == nil || c.PeerConfig == nil ||
+		c.MinerConfig == nil || c.WalletConfig == nil || c.ChainConfig == nil || c.NotifyConfig == nil || c.LogConfig == nil {
+		return &ValidationError{Problems: append(problems, "every sub-config must be set")}
+	}
+
+	if c.NoListen {
+		if c.ListenAddr != "" {
+			problem("listen_addr is set, but no_listen is too")
+		}
+	} else if c.ListenAddr != "" {
+		if _, _, err := net.SplitHostPort(c.ListenAddr); err != nil {
+			problem("listen_addr %q is not a host and port", c.ListenAddr)
+		}
+	} else if c.Port <= 0 || c.Port > 65535 {
+		problem("port %v is out of range", c.Port)
+	}
+	if c.AdminAddr != "" {
+		if host, _, err := net.SplitHostPort(c.AdminAddr); err != nil {
+			problem("admin_addr %q is not a host and port", c.AdminAddr)
+		} else if !isLoopback(host) && c.AdminToken == "" {
+			problem("admin_addr %q is not on localhost, so admin_token must be set", c.AdminAddr)
+		}
+	}
+	if c.GatewayAddr != "" {
+		if _, _, err := net.SplitHostPort(c.GatewayAddr); err != nil {
+			problem("gateway_addr %q is not a host and port", c.GatewayAddr)
+		} else if c.GatewayAddr == c.AdminAddr {
+			problem("gateway_addr and admin_addr are both %q", c.GatewayAddr)
+		}
+	}
+	if c.MetricsAddr != "" {
+		if _, _, err := net.SplitHostPort(c.MetricsAddr); err != nil {
+			problem("metrics_addr %q is not a host and port", c.MetricsAddr)
+		} else if c.MetricsAddr == c.AdminAddr || c.MetricsAddr == c.GatewayAddr {
+			problem("metrics_addr %q is already used by another service", c.MetricsAddr)
+		}
+	}
+	if c.ExplorerAddr != "" {
+		if _, _, err := net.SplitHostPort(c.ExplorerAddr); err != nil {
+			problem("explorer_addr %q is not a host and port", c.ExplorerAddr)
+		} else if c.ExplorerAddr == c.AdminAddr || c.ExplorerAddr == c.GatewayAddr || c.ExplorerAddr == c.MetricsAddr {
+			problem("explorer_addr %q is already used by another service", c.ExplorerAddr)
+		}
+	}
+	if c.AdvertiseAddr != "" && !utils.ValidAddr(c.AdvertiseAddr) {
+		problem("advertise_addr %q is not an address other nodes can reach", c.AdvertiseAddr)
+	}
+	for _, seed := range c.Seeds {
+		if !utils.ValidAddr(seed) {
+			problem("seed %q is not a valid address", seed)
+		}
+	}
+
+	if c.MinVersion > c.Version {
+		problem("min_version %v is above the node's version %v", c.MinVersion, c.Version)
+	}
+	if c.PeerLimit <= 0 {
+		problem("peer_limit must be positive")
+	}
+	if c.TargetOutbound < 0 || c.TargetOutbound > c.PeerLimit {
+		problem("target_outbound %v must be between 0 and peer_limit %v", c.TargetOutbound, c.PeerLimit)
+	}
+	if c.MaxInbound < 0 || c.MaxInbound > c.PeerLimit {
+		problem("max_inbound %v must be between 0 and peer_limit %v", c.MaxInbound, c.PeerLimit)
+	}
+	if c.AddressLimit <= 0 {
+		problem("address_limit must be positive")
+	}
+	if c.SeenCacheSize <= 0 {
+		problem("seen_cache_size must be positive")
+	}
+	if c.NotifyConfig.BufferSize <= 0 || c.NotifyConfig.MaxSubscribers < 0 {
+		problem("notify_buffer_size must be positive and notify_max_subscribers may not be negative")
+	}
+	durations := []struct {
+		name string
+		d    time.Duration
+	}{
+		{"version_timeout", c.VersionTimeout},
+		{"connect_interval", c.ConnectInterval},
+		{"discovery_interval", c.DiscoveryInterval},
+		{"ping_interval", c.PeerConfig.PingInterval},
+	}
+	for _, d := range durations {
+		if d.d <= 0 {
+			problem("%v must be positive", d.name)
+		}
+	}
+	if c.SeenCacheTTL < 0 || c.PeerConfig.BanDuration < 0 || c.AddressConfig.IdleTimeout < 0 {
+		problem("seen_cache_ttl, ban_duration and idle_timeout may not be negative")
+	}
+	if c.AddressConfig.MaxMessageSize <= 0 {
+		problem("max_message_size must be positive")
+	}
+	if c.AddressConfig.MinBackoff > c.AddressConfig.MaxBackoff {
+		problem("the minimum reconnect backoff is above the maximum")
+	}
+	if c.PeerConfig.BanThreshold == 0 {
+		problem("ban_threshold must be positive")
+	}
+	if c.PeerConfig.MaxMissedPings == 0 {
+		problem("max_missed_pings must be positive")
+	}
+	if c.LogConfig.Format != utils.LogFormatText && c.LogConfig.Format != utils.LogFormatJSON {
+		problem("log_format %q must be %v or %v", c.LogConfig.Format, utils.LogFormatText, utils.LogFormatJSON)
+	}
+
+	if c.MinerConfig.HasMiner && !c.ChainConfig.HasChain {
+		problem("a miner needs a blockchain")
+	}
+	if c.WalletConfig.HasWallet && !c.ChainConfig.HasChain {
+		problem("a wallet needs a blockchain")
+	}
+	if c.ChainConfig.HasChain {
+		paths := map[string]string{
+			"block_info_db_path":   c.ChainConfig.BlockInfoDBPath,
+			"chain_writer_db_path": c.ChainConfig.ChainWriterDBPath,
+			"coin_db_path":         c.ChainConfig.CoinDBPath,
+			"ban_list_path":        c.PeerConfig.BanListPath,
+			"address_db_path":      c.AddressDBPath,
+		}
+		used := make(map[string]string)
+		for _, name := range sortedKeys(paths) {
+			path := paths[name]
+			if path == "" {
+				if name != "address_db_path" && name != "ban_list_path" {
+					problem("%v must be set", name)
+				}
+				continue
+			}
+			if other, ok := used[path]; ok {
+				problem("%v and %v are both %q", other, name, path)
+			}
+			used[path] = name
+		}
+	}
+
+	if len(problems) > 0 {
+		return &ValidationError{Problems: problems}
+	}
+	return nil
+}
+
+// isLoopback returns whether a host is only reachable from
+// this machine.
+func isLoopback(host string) bool {
+	if host == "localhost" {
+		return true
+	}
+	ip := net.ParseIP(host)
+	return ip != nil && ip.IsLoopback()
+}
@@ -0,0 +1,201 @@
+package pkg
+
+import (
+	"Coin/pkg/address/addressdb"
+	"Coin/pkg/peer"
+	"Coin/pkg/pro"
+	"Coin/pkg/utils"
+	"time"
+)
+
+// maintainConnections keeps the node connected to
+// TargetOutbound outbound peers and discovers new addresses,
+// until the node is killed. It tops up the outbound peers
+// right away, so that a restarted node rejoins the network
+// on its own, and then every ConnectInterval. Every
+// DiscoveryInterval, it asks a random peer for the addresses
+// it knows and forgets the addresses that went stale. Nothing
+// is done while the node is paused.
+func (n *Node) maintainConnections() {
+	n.fillOutbound()
+	connect := time.NewTicker(n.Config.ConnectInterval)
+	defer connect.Stop()
+	discover := time.NewTicker(n.Config.DiscoveryInterval)
+	defer discover.Stop()
+	for {
+		select {
+		case <-n.ctx.Done():
+			return
+		case <-connect.C:
+			if !n.Paused.Load() {
+				n.fillOutbound()
+			}
+		case <-discover.C:
+			if !n.Paused.Load() {
+				n.discoverAddresses()
+				if am, ok := n.AddressDB.(*addressdb.AddrManager); ok {
+					am.EvictStale()
+				}
+			}
+		}
+	}
+}
+
+// fillOutbound connects to new outbound peers until the node
+// has TargetOutbound of them. Candidates are drawn from the
+// AddressDb, favoring addresses we connected to before. If
+// that is not enough, the seed addresses are tried.
+func (n *Node) fillOutbound() {
+	need := n.Config.TargetOutbound - n.OutboundCount()
+	if need <= 0 {
+		return
+	}
+	exclude := []string{n.Address}
+	for _, p := range n.PeerDb.List() {
+		exclude = append(exclude, p.Addr.Addr)
+	}
+	for tries := 0; need > 0 && tries < 2*n.Config.TargetOutbound; tries++ {
+		a := n.AddressDB.Select(exclude)
+		if a == nil {
+			break
+		}
+		exclude = append(exclude, a.Addr)
+		if n.BanList.IsBanned(a.Addr, a.PublicKey) {
+			continue
+		}
+		if n.ConnectToPeer(a.Addr) {
+			need--
+		}
+	}
+	for _, seed := range n.Config.Seeds {
+		if need <= 0 {
+			return
+		}
+		if seed == n.Address || n.PeerDb.Get(seed) != nil || n.BanList.IsBanned(seed, "") {
+			continue
+		}
+		if n.ConnectToPeer(seed) {
+			need--
+		}
+	}
+}
+
+// discoverAddresses asks a random peer for the addresses it
+// knows, and adds the ones we did not know to the AddressDb.
+func (n *Node) discoverAddresses() {
+	peers := n.PeerDb.GetRandom(1, append([]string{n.Address}, n.unreachablePeers()...))
+	if len(peers) == 0 {
+		return
+	}
+	p := peers[0]
+	res, err := p.Addr.GetAddressesRPC(&pro.Empty{})
+	if err != nil {
+		n.log.Debug("no response to get addresses", "peer", p.Addr.Addr, "err", err)
+		return
+	}
+	for _, pa := range res.Addrs {
+		if pa.Addr == n.Address || !utils.ValidAddr(pa.Addr) || n.AddressDB.Get(pa.Addr) != nil {
+			continue
+		}
+		a := n.newAddress(pa.Addr, pa.LastSeen)
+		a.Source = p.Addr.Addr
+		_ = n.AddressDB.Add(a)
+	}
+}
+
+// markOutbound records that the node is connecting to addr,
+// so that the peering is counted as outbound.
+func (n *Node) markOutbound(addr string) {
+	n.outboundMutex.Lock()
+	defer n.outboundMutex.Unlock()
+	n.outbound[addr] = true
+}
+
+// unmarkOutbound forgets that the node connected to addr.
+func (n *Node) unmarkOutbound(addr string) {
+	n.outboundMutex.Lock()
+	defer n.outboundMutex.Unlock()
+	delete(n.outbound, addr)
+}
+
+// isOutbound returns whether the node connected to addr,
+// rather than addr connecting to the node.
+func (n *Node) isOutbound(addr string) bool {
+	n.outboundMutex.Lock()
+	defer n.outboundMutex.Unlock()
+	return n.outbound[addr]
+}
+
+// OutboundCount returns the number of peers that the node
+// connected to.
+func (n *Node) OutboundCount() int {
+	return len(n.peersByDirection(false))
+}
+
+// InboundCount returns the number of peers that connected
+// to the node.
+func (n *Node) InboundCount() int {
+	return len(n.peersByDirection(true))
+}
+
+// admitPeer adds a peer that sent the node a version. A peer
+// that connected to the node is refused if the node already has
+// MaxInbound inbound peers. The count and the add are made under
+// one lock, so that versions that arrive together cannot take
+// the node past the limit.
+// Returns:
+// bool whether the peer was added
+// bool whether the peer was refused for the inbound limit
+func (n *Node) admitPeer(p *peer.Peer) (bool, bool) {
+	n.admitMutex.Lock()
+	defer n.admitMutex.Unlock()
+	if p.Inbound && n.PeerDb.Get(p.Addr.Addr) == nil && n.InboundCount() >= n.Config.MaxInbound {
+		return false, true
+	}
+	return n.PeerDb.Add(p), false
+}
+
+// PeerStats returns a snapshot of what the node knows about
+// each of its peers, including the state of their rate limits.
+func (n *Node) PeerStats() []*peer.Stats {
+	stats := make([]*peer.Stats, 0)
+	for _, p := range n.PeerDb.List() {
+		stats = append(stats, p.Stats())
+	}
+	return stats
+}
+
+// reachablePeers returns the peers that accept connections,
+// which are the ones that can be sent relayed objects.
+func (n *Node) reachablePeers() []*peer.Peer {
+	peers := make([]*peer.Peer, 0)
+	for _, p := range n.PeerDb.List() {
+		if !p.NoListen {
+			peers = append(peers, p)
+		}
+	}
+	return peers
+}
+
+// unreachablePeers returns the addresses of the peers that
+// do not accept connections, so that callers can exclude them.
+func (n *Node) unreachablePeers() []string {
+	addrs := make([]string, 0)
+	for _, p := range n.PeerDb.List() {
+		if p.NoListen {
+			addrs = append(addrs, p.Addr.Addr)
+		}
+	}
+	return addrs
+}
+
+// peersByDirection returns the inbound or outbound peers.
+func (n *Node) peersByDirection(inbound bool) []*peer.Peer {
+	peers := make([]*peer.Peer, 0)
+	for _, p := range n.PeerDb.List() {
+		if p.Inbound == inbound {
+			peers = append(peers, p)
+		}
+	}
+	return peers
+}
@@ -0,0 +1,36 @@
+package pkg
+
+import (
+	"Coin/pkg/explorer"
+	"Coin/pkg/peer"
+	"Coin/pkg/utils"
+	"net"
+	"net/http"
+)
+
+// explorerBackend is the explorer.Backend of a node.
+type explorerBackend struct {
+	gatewayBackend
+}
+
+func (b explorerBackend) Peers() []*peer.Stats {
+	return b.n.PeerStats()
+}
+
+// StartExplorerServer serves the node's block explorer on addr,
+// which shows its chain, mempool and peers as web pages.
+// Returns:
+// error if the node could not listen on addr
+func (n *Node) StartExplorerServer(addr string) error {
+	lis, err := net.Listen("tcp", addr)
+	if err != nil {
+		return err
+	}
+	n.ExplorerServer = &http.Server{Handler: explorer.NewHandler(explorerBackend{gatewayBackend{n}})}
+	go func() {
+		if err := n.ExplorerServer.Serve(lis); err != nil && err != http.ErrServerClosed {
+			n.logger(utils.ComponentRPC).Error("unable to serve explorer server", "addr", addr, "err", err)
+		}
+	}()
+	return nil
+}
@@ -0,0 +1,314 @@
+// Package explorer serves a node's chain, coins, mempool and
+// peers as web pages, for looking into a node while debugging,
+// such as when following a fork. It only shows what the node
+// already stores.
+//
+// Pages:
+//
+//	/                          the most recent blocks of the main chain
+//	/blocks/<hash|height>      a block, with its transactions
+//	/transactions/<hash>       a transaction, from the main chain or the mempool
+//	/addresses/<public key>    the balance and unspent coins of a public key
+//	/mempool                   the transactions waiting to be mined
+//	/peers                     the node's peers
+//	/search?q=<query>          a height, hash or public key
+//
+// Public keys, and scripts, are hex encoded, as in the gateway.
+package explorer
+
+import (
+	"Coin/pkg/block"
+	"Coin/pkg/blockchain"
+	"Coin/pkg/gateway"
+	"Coin/pkg/peer"
+	"encoding/hex"
+	"fmt"
+	"html/template"
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+// RecentBlocks is how many blocks the front page lists.
+const RecentBlocks = 20
+
+// Backend is the node that an explorer shows.
+type Backend interface {
+	// Chain returns the node's chain.
+	Chain() *blockchain.BlockChain
+	// Mempool returns the transactions waiting to be mined,
+	// highest priority first, or an error if the node keeps
+	// no mempool.
+	Mempool() ([]*block.HeapNode, error)
+	// Peers returns what the node knows about its peers.
+	Peers() []*peer.Stats
+}
+
+// explorer is the http.Handler of a Backend.
+type explorer struct {
+	b   Backend
+	mux *http.ServeMux
+}
+
+// NewHandler returns an http.Handler that serves the explorer
+// of a Backend.
+func NewHandler(b Backend) http.Handler {
+	e := &explorer{b: b, mux: http.NewServeMux()}
+	e.mux.HandleFunc("/", e.page("index", e.index))
+	e.mux.HandleFunc("/blocks/", e.page("block", e.block))
+	e.mux.HandleFunc("/transactions/", e.page("transaction", e.transaction))
+	e.mux.HandleFunc("/addresses/", e.page("address", e.address))
+	e.mux.HandleFunc("/mempool", e.page("mempool", e.mempool))
+	e.mux.HandleFunc("/peers", e.page("peers", e.peers))
+	e.mux.HandleFunc("/search", e.search)
+	return e
+}
+
+func (e *explorer) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	e.mux.ServeHTTP(w, r)
+}
+
+// notFound is an error for a page that does not exist.
+type notFound string
+
+func (e notFound) Error() string {
+	return string(e)
+}
+
+// page adapts a page, which returns the data to render its
+// template with or an error, to an http.HandlerFunc.
+func (e *explorer) page(name string, f func(r *http.Request) (interface{}, error)) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet && r.Method != http.MethodHead {
+			w.Header().Set("Allow", "GET, HEAD")
+			render(w, http.StatusMethodNotAllowed, "error", "method not allowed")
+			return
+		}
+		data, err := f(r)
+		if err != nil {
+			code := http.StatusInternalServerError
+			if _, ok := err.(notFound); ok {
+				code = http.StatusNotFound
+			}
+			render(w, code, "error", err.Error())
+			return
+		}
+		render(w, http.StatusOK, name, data)
+	}
+}
+
+// render writes a page. Pages are rendered to memory first, so
+// that a template error does not leave half a page behind.
+func render(w http.ResponseWriter, code int, name string, data interface{}) {
+	var b strings.Builder
+	if err := pages[name].ExecuteTemplate(&b, "layout", data); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	w.WriteHeader(code)
+	_, _ = fmt.Fprint(w, b.String())
+}
+
+// blockRow is a block in a list of blocks.
+type blockRow struct {
+	Height       uint32
+	Hash         string
+	Transactions uint32
+	Timestamp    uint32
+}
+
+// indexPage is the data of the front page.
+type indexPage struct {
+	Height   uint32
+	BestHash string
+	Blocks   []*blockRow
+}
+
+func (e *explorer) index(r *http.Request) (interface{}, error) {
+	if r.URL.Path != "/" {
+		return nil, notFound(fmt.Sprintf("no page at %v", r.URL.Path))
+	}
+	bc := e.b.Chain()
+	p := &indexPage{Height: bc.Length, BestHash: bc.LastHash}
+	start := uint32(1)
+	if bc.Length > RecentBlocks {
+		start = bc.Length - RecentBlocks + 1
+	}
+	hashes := bc.GetHashes(start, bc.Length)
+	for i := len(hashes) - 1; i >= 0; i-- {
+		br := bc.BlockInfoDB.GetBlockRecord(hashes[i])
+		p.Blocks = append(p.Blocks, &blockRow{
+			Height:       br.Height,
+			Hash:         hashes[i],
+			Transactions: br.NumberOfTransactions,
+			Timestamp:    br.Header.Timestamp,
+		})
+	}
+	return p, nil
+}
+
+// blockPage is the data of a block's page.
+// MainChain is whether the block is on the main chain. Blocks
+// of forks that lost are still stored, so they can be shown.
+// Next is the hash of the block after it on the main chain,
+// if there is one.
+type blockPage struct {
+	Block     *gateway.Block
+	Size      uint32
+	MainChain bool
+	Next      string
+}
+
+// block shows a block by its hash or, if the id is a number, by
+// its height on the main chain.
+func (e *explorer) block(r *http.Request) (interface{}, error) {
+	bc := e.b.Chain()
+	id := strings.TrimPrefix(r.URL.Path, "/blocks/")
+	hash := id
+	if height, err := strconv.ParseUint(id, 10, 32); err == nil {
+		if height < 1 || uint32(height) > bc.Length {
+			return nil, notFound(fmt.Sprintf("no block at height %v, the chain has %v", height, bc.Length))
+		}
+		hash = bc.GetHashes(uint32(height), uint32(height))[0]
+	}
+	if hash == "" || !bc.BlockInfoDB.HasBlockRecord(hash) {
+		return nil, notFound(fmt.Sprintf("no block with hash %q", hash))
+	}
+	br := bc.BlockInfoDB.GetBlockRecord(hash)
+	b := bc.GetBlock(hash)
+	p := &blockPage{Block: gateway.EncodeBlock(b, br.Height), Size: b.Size()}
+	if br.Height <= bc.Length {
+		p.MainChain = bc.GetHashes(br.Height, br.Height)[0] == hash
+	}
+	if p.MainChain && br.Height < bc.Length {
+		p.Next = bc.GetHashes(br.Height+1, br.Height+1)[0]
+	}
+	return p, nil
+}
+
+// transactionPage is the data of a transaction's page.
+type transactionPage struct {
+	*gateway.TransactionInfo
+	Size     uint32
+	Coinbase bool
+	Total    uint32
+}
+
+// transaction shows a transaction from the mempool or, failing
+// that, the main chain.
+func (e *explorer) transaction(r *http.Request) (interface{}, error) {
+	hash := strings.TrimPrefix(r.URL.Path, "/transactions/")
+	if hash == "" {
+		return nil, notFound("no transaction hash given")
+	}
+	newPage := func(tx *block.Transaction, info *gateway.TransactionInfo) *transactionPage {
+		info.Transaction = gateway.EncodeTransaction(tx)
+		return &transactionPage{TransactionInfo: info, Size: tx.Size(), Coinbase: tx.IsCoinbase(), Total: tx.SumOutputs()}
+	}
+	if pool, err := e.b.Mempool(); err == nil {
+		for _, node := range pool {
+			if node.Transaction.Hash() == hash {
+				return newPage(node.Transaction, &gateway.TransactionInfo{}), nil
+			}
+		}
+	}
+	tx, blockHash, height := e.b.Chain().FindTransaction(hash)
+	if tx == nil {
+		return nil, notFound(fmt.Sprintf("no transaction with hash %q", hash))
+	}
+	return newPage(tx, &gateway.TransactionInfo{Confirmed: true, BlockHash: blockHash, Height: height}), nil
+}
+
+// addressPage is the data of a public key's page.
+type addressPage struct {
+	PublicKey string
+	Balance   uint32
+	UTXOs     []*gateway.UTXO
+}
+
+func (e *explorer) address(r *http.Request) (interface{}, error) {
+	id := strings.TrimPrefix(r.URL.Path, "/addresses/")
+	pk, err := hex.DecodeString(id)
+	if err != nil || len(pk) == 0 {
+		return nil, notFound(fmt.Sprintf("public key %q is not hex encoded", id))
+	}
+	bc := e.b.Chain()
+	p := &addressPage{PublicKey: id, Balance: bc.GetBalance(string(pk))}
+	locators, coins := bc.CoinDB.GetUnspentCoins(string(pk))
+	for i, c := range coins {
+		p.UTXOs = append(p.UTXOs, gateway.EncodeUTXO(locators[i], c))
+	}
+	return p, nil
+}
+
+// mempoolEntry is a transaction in the mempool.
+type mempoolEntry struct {
+	Hash     string
+	Priority uint32
+	Size     uint32
+	Inputs   int
+	Total    uint32
+}
+
+// mempoolPage is the data of the mempool's page.
+// Error is why the node keeps no mempool, if it does not.
+type mempoolPage struct {
+	Error   string
+	Entries []*mempoolEntry
+}
+
+func (e *explorer) mempool(r *http.Request) (interface{}, error) {
+	pool, err := e.b.Mempool()
+	if err != nil {
+		return &mempoolPage{Error: err.Error()}, nil
+	}
+	p := &mempoolPage{}
+	for _, node := range pool {
+		tx := node.Transaction
+		p.Entries = append(p.Entries, &mempoolEntry{
+			Hash:     tx.Hash(),
+			Priority: node.Priority,
+			Size:     tx.Size(),
+			Inputs:   len(tx.Inputs),
+			Total:    tx.SumOutputs(),
+		})
+	}
+	return p, nil
+}
+
+func (e *explorer) peers(r *http.Request) (interface{}, error) {
+	return e.b.Peers(), nil
+}
+
+// search sends a query to the page it names: a number is a
+// height, a hash is a block or a transaction, and any other
+// hex is a public key.
+func (e *explorer) search(w http.ResponseWriter, r *http.Request) {
+	q := strings.TrimSpace(r.URL.Query().Get("q"))
+	bc := e.b.Chain()
+	target := ""
+	if _, err := strconv.ParseUint(q, 10, 32); err == nil {
+		target = "/blocks/" + q
+	} else if _, err := hex.DecodeString(q); err != nil || q == "" {
+		render(w, http.StatusNotFound, "error", fmt.Sprintf("%q is not a height, hash or public key", q))
+		return
+	} else if len(q) == 64 && bc.BlockInfoDB.HasBlockRecord(q) {
+		target = "/blocks/" + q
+	} else if len(q) == 64 {
+		target = "/transactions/" + q
+	} else {
+		target = "/addresses/" + q
+	}
+	http.Redirect(w, r, target, http.StatusFound)
+}
+
+// funcs are the functions that the templates can use.
+var funcs = template.FuncMap{
+	"short": func(s string) string {
+		if len(s) <= 16 {
+			return s
+		}
+		return s[:16] + "…"
+	},
+}
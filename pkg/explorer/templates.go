@@ -0,0 +1,166 @@
+package explorer
+
+import "html/template"
+
+// layout is the frame that every page is drawn in. Each page
+// defines its title and content.
+const layout = `{{define "layout"}}<!DOCTYPE html>
+<html>
+<head>
+<meta charset="utf-8">
+<title>{{template "title" .}} - Coin explorer</title>
+<style>
+body { font-family: sans-serif; margin: 2em; }
+nav a { margin-right: 1em; }
+table { border-collapse: collapse; margin-bottom: 1em; }
+th, td { border: 1px solid #ccc; padding: 0.25em 0.5em; text-align: left; }
+.hash { font-family: monospace; }
+.fork { color: #b00; }
+</style>
+</head>
+<body>
+<nav>
+<a href="/">Blocks</a><a href="/mempool">Mempool</a><a href="/peers">Peers</a>
+<form action="/search" style="display: inline"><input name="q" size="40" placeholder="height, hash or public key"></form>
+</nav>
+<h1>{{template "title" .}}</h1>
+{{template "content" .}}
+</body>
+</html>
+{{end}}`
+
+// transactionTables draws the inputs and outputs of a
+// gateway.Transaction.
+const transactionTables = `{{define "transaction"}}
+<h3>Inputs</h3>
+{{if .Inputs}}<table>
+<tr><th>Transaction</th><th>Output</th><th>Unlocking script</th></tr>
+{{range .Inputs}}<tr>
+<td class="hash"><a href="/transactions/{{.ReferenceTransactionHash}}">{{short .ReferenceTransactionHash}}</a></td>
+<td>{{.OutputIndex}}</td>
+<td class="hash">{{short .UnlockingScript}}</td>
+</tr>{{end}}
+</table>{{else}}<p>None, this transaction makes new coins.</p>{{end}}
+<h3>Outputs</h3>
+<table>
+<tr><th>Index</th><th>Amount</th><th>Locking script</th></tr>
+{{range $i, $o := .Outputs}}<tr>
+<td>{{$i}}</td>
+<td>{{$o.Amount}}</td>
+<td class="hash"><a href="/addresses/{{$o.LockingScript}}">{{short $o.LockingScript}}</a></td>
+</tr>{{end}}
+</table>
+{{end}}`
+
+// pageSources are the templates of the pages, by name.
+var pageSources = map[string]string{
+	"index": `{{define "title"}}Blocks{{end}}{{define "content"}}
+<p>The main chain has {{.Height}} blocks. Its last block is
+<a class="hash" href="/blocks/{{.BestHash}}">{{.BestHash}}</a>.</p>
+<table>
+<tr><th>Height</th><th>Hash</th><th>Transactions</th><th>Timestamp</th></tr>
+{{range .Blocks}}<tr>
+<td><a href="/blocks/{{.Height}}">{{.Height}}</a></td>
+<td class="hash"><a href="/blocks/{{.Hash}}">{{.Hash}}</a></td>
+<td>{{.Transactions}}</td>
+<td>{{.Timestamp}}</td>
+</tr>{{end}}
+</table>
+{{end}}`,
+
+	"block": `{{define "title"}}Block {{.Block.Height}}{{end}}{{define "content"}}
+{{with .Block}}<table>
+<tr><th>Hash</th><td class="hash">{{.Hash}}</td></tr>
+<tr><th>Height</th><td>{{.Height}}</td></tr>
+<tr><th>Main chain</th><td>{{if $.MainChain}}yes{{else}}<span class="fork">no, this block is on a fork</span>{{end}}</td></tr>
+<tr><th>Previous block</th><td class="hash"><a href="/blocks/{{.Header.PreviousHash}}">{{.Header.PreviousHash}}</a></td></tr>
+<tr><th>Next block</th><td class="hash">{{if $.Next}}<a href="/blocks/{{$.Next}}">{{$.Next}}</a>{{else}}none{{end}}</td></tr>
+<tr><th>Merkle root</th><td class="hash">{{.Header.MerkleRoot}}</td></tr>
+<tr><th>Difficulty target</th><td class="hash">{{.Header.DifficultyTarget}}</td></tr>
+<tr><th>Nonce</th><td>{{.Header.Nonce}}</td></tr>
+<tr><th>Timestamp</th><td>{{.Header.Timestamp}}</td></tr>
+<tr><th>Version</th><td>{{.Header.Version}}</td></tr>
+<tr><th>Size</th><td>{{$.Size}} bytes</td></tr>
+</table>
+<h2>Transactions</h2>
+{{range .Transactions}}
+<h3 class="hash"><a href="/transactions/{{.Hash}}">{{.Hash}}</a></h3>
+{{template "transaction" .}}
+{{end}}{{end}}
+{{end}}`,
+
+	"transaction": `{{define "title"}}Transaction {{short .Transaction.Hash}}{{end}}{{define "content"}}
+<table>
+<tr><th>Hash</th><td class="hash">{{.Transaction.Hash}}</td></tr>
+<tr><th>Status</th><td>{{if .Confirmed}}in block <a class="hash" href="/blocks/{{.BlockHash}}">{{.BlockHash}}</a> at height {{.Height}}{{else}}waiting in the mempool{{end}}</td></tr>
+<tr><th>Coinbase</th><td>{{if .Coinbase}}yes{{else}}no{{end}}</td></tr>
+<tr><th>Total output</th><td>{{.Total}}</td></tr>
+<tr><th>Size</th><td>{{.Size}} bytes</td></tr>
+<tr><th>Version</th><td>{{.Transaction.Version}}</td></tr>
+<tr><th>Lock time</th><td>{{.Transaction.LockTime}}</td></tr>
+</table>
+{{template "transaction" .Transaction}}
+{{end}}`,
+
+	"address": `{{define "title"}}Public key {{short .PublicKey}}{{end}}{{define "content"}}
+<p class="hash">{{.PublicKey}}</p>
+<p>Balance: {{.Balance}}</p>
+<h2>Unspent coins</h2>
+{{if .UTXOs}}<table>
+<tr><th>Transaction</th><th>Output</th><th>Amount</th></tr>
+{{range .UTXOs}}<tr>
+<td class="hash"><a href="/transactions/{{.TransactionHash}}">{{.TransactionHash}}</a></td>
+<td>{{.OutputIndex}}</td>
+<td>{{.Amount}}</td>
+</tr>{{end}}
+</table>{{else}}<p>None.</p>{{end}}
+{{end}}`,
+
+	"mempool": `{{define "title"}}Mempool{{end}}{{define "content"}}
+{{if .Error}}<p>{{.Error}}.</p>{{else if .Entries}}<p>{{len .Entries}} transactions are waiting to be mined, highest priority first.</p>
+<table>
+<tr><th>Hash</th><th>Priority</th><th>Size</th><th>Inputs</th><th>Total output</th></tr>
+{{range .Entries}}<tr>
+<td class="hash"><a href="/transactions/{{.Hash}}">{{.Hash}}</a></td>
+<td>{{.Priority}}</td>
+<td>{{.Size}}</td>
+<td>{{.Inputs}}</td>
+<td>{{.Total}}</td>
+</tr>{{end}}
+</table>{{else}}<p>No transactions are waiting to be mined.</p>{{end}}
+{{end}}`,
+
+	"peers": `{{define "title"}}Peers{{end}}{{define "content"}}
+{{if .}}<table>
+<tr><th>Address</th><th>Direction</th><th>Version</th><th>User agent</th><th>Services</th><th>Best height</th><th>Best block</th><th>Ping</th><th>Misbehavior</th></tr>
+{{range .}}<tr>
+<td>{{.Addr}}</td>
+<td>{{if .Inbound}}inbound{{else}}outbound{{end}}</td>
+<td>{{.Version}}</td>
+<td>{{.UserAgent}}</td>
+<td>{{.Services}}</td>
+<td>{{.BestHeight}}</td>
+<td class="hash">{{if .BestHash}}<a href="/blocks/{{.BestHash}}">{{short .BestHash}}</a>{{end}}</td>
+<td>{{.RTT}}</td>
+<td>{{.Misbehavior}}</td>
+</tr>{{end}}
+</table>{{else}}<p>The node has no peers.</p>{{end}}
+{{end}}`,
+
+	"error": `{{define "title"}}Error{{end}}{{define "content"}}
+<p>{{.}}</p>
+{{end}}`,
+}
+
+// pages are the parsed templates of the pages, by name.
+var pages = parsePages()
+
+func parsePages() map[string]*template.Template {
+	base := template.Must(template.New("layout").Funcs(funcs).Parse(layout))
+	template.Must(base.Parse(transactionTables))
+	parsed := make(map[string]*template.Template, len(pageSources))
+	for name, src := range pageSources {
+		parsed[name] = template.Must(template.Must(base.Clone()).Parse(src))
+	}
+	return parsed
+}
@@ -0,0 +1,78 @@
+package pkg
+
+import (
+	"Coin/pkg/block"
+	"Coin/pkg/blockchain"
+	"Coin/pkg/gateway"
+	"Coin/pkg/notify"
+	"Coin/pkg/utils"
+	"fmt"
+	"net"
+	"net/http"
+)
+
+// gatewayBackend is the gateway.Backend of a node.
+type gatewayBackend struct {
+	n *Node
+}
+
+func (b gatewayBackend) Chain() *blockchain.BlockChain {
+	return b.n.BlockChain
+}
+
+func (b gatewayBackend) Mempool() ([]*block.HeapNode, error) {
+	if !b.n.Config.MinerConfig.HasMiner {
+		return nil, gateway.ErrNoMempool
+	}
+	return b.n.Miner.TxPool.List(), nil
+}
+
+func (b gatewayBackend) SubmitTransaction(tx *block.Transaction) error {
+	return b.n.SubmitTransaction(tx)
+}
+
+func (b gatewayBackend) Subscribe(f *notify.Filter) (*notify.Subscription, error) {
+	return b.n.Events.Subscribe(f)
+}
+
+// StartGatewayServer serves the node's gateway on addr, which
+// lets applications that cannot speak gRPC read the chain and
+// submit transactions over HTTP.
+// Returns:
+// error if the node could not listen on addr
+func (n *Node) StartGatewayServer(addr string) error {
+	lis, err := net.Listen("tcp", addr)
+	if err != nil {
+		return err
+	}
+	n.GatewayServer = &http.Server{Handler: gateway.NewHandler(gatewayBackend{n})}
+	go func() {
+		if err := n.GatewayServer.Serve(lis); err != nil && err != http.ErrServerClosed {
+			n.logger(utils.ComponentRPC).Error("unable to serve gateway server", "addr", addr, "err", err)
+		}
+	}()
+	return nil
+}
+
+// SubmitTransaction validates a transaction from outside the
+// network and, if it is valid, hands it to the miner and relays
+// it to the node's peers. A transaction that the node has
+// already seen is valid, but is not relayed again.
+// Inputs:
+// tx *block.Transaction the transaction to submit
+// Returns:
+// error why the transaction is invalid, or, wrapping
+// gateway.ErrUnavailable, why the node cannot take it
+func (n *Node) SubmitTransaction(tx *block.Transaction) error {
+	if n.Paused.Load() {
+		return fmt.Errorf("%w: node is paused", gateway.ErrUnavailable)
+	}
+	if err := n.ValidateTransaction(tx); err != nil {
+		n.stats.txRejected.With(rejectReason(err)).Inc()
+		return err
+	}
+	if n.SeenTransactions.Add(tx.Hash()) {
+		n.relayTransaction(tx)
+	}
+	return nil
+}
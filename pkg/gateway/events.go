@@ -0,0 +1,122 @@
+package gateway
+
+import (
+	"Coin/pkg/notify"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// KeepAliveInterval is how often an idle event stream is sent
+// a comment, so that proxies do not close it.
+const KeepAliveInterval = 15 * time.Second
+
+// LaggedEvent is the name of the event that ends the stream of
+// a client that fell too far behind.
+const LaggedEvent = "lagged"
+
+// parseFilter reads the types and public_keys query parameters
+// of an event stream into a filter.
+func parseFilter(r *http.Request) (*notify.Filter, error) {
+	f := &notify.Filter{Types: make(map[notify.Type]bool), PublicKeys: make(map[string]bool)}
+	for _, t := range splitList(r.URL.Query().Get("types")) {
+		if !notify.ValidType(notify.Type(t)) {
+			return nil, fmt.Errorf("unknown event type %q, want one of %v", t, notify.Types)
+		}
+		f.Types[notify.Type(t)] = true
+	}
+	for _, pk := range splitList(r.URL.Query().Get("public_keys")) {
+		if b, err := hex.DecodeString(pk); err != nil || len(b) == 0 {
+			return nil, fmt.Errorf("public key %q is not hex encoded", pk)
+		}
+		f.PublicKeys[strings.ToLower(pk)] = true
+	}
+	return f, nil
+}
+
+// splitList splits a comma separated list, leaving out empty
+// items.
+func splitList(s string) []string {
+	var items []string
+	for _, item := range strings.Split(s, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			items = append(items, item)
+		}
+	}
+	return items
+}
+
+// events streams the node's events as Server-Sent Events until
+// the client goes away, falls behind, or the node shuts down.
+func (g *gateway) events(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		w.Header().Set("Allow", "GET")
+		writeJSON(w, http.StatusMethodNotAllowed, &Error{Error: "method not allowed"})
+		return
+	}
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		writeJSON(w, http.StatusInternalServerError, &Error{Error: "streaming is not supported"})
+		return
+	}
+	f, err := parseFilter(r)
+	if err != nil {
+		writeJSON(w, http.StatusBadRequest, &Error{Error: err.Error()})
+		return
+	}
+	sub, err := g.b.Subscribe(f)
+	if err != nil {
+		code := http.StatusInternalServerError
+		if errors.Is(err, notify.ErrTooManySubscribers) || errors.Is(err, notify.ErrClosed) {
+			code = http.StatusServiceUnavailable
+		}
+		writeJSON(w, code, &Error{Error: err.Error()})
+		return
+	}
+	defer sub.Close()
+
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.WriteHeader(http.StatusOK)
+	// the comment lets the client know that it is subscribed
+	// before any event happens
+	fmt.Fprint(w, ": subscribed\n\n")
+	flusher.Flush()
+
+	keepAlive := time.NewTicker(KeepAliveInterval)
+	defer keepAlive.Stop()
+	for {
+		select {
+		case <-r.Context().Done():
+			return
+		case <-keepAlive.C:
+			fmt.Fprint(w, ": keep-alive\n\n")
+		case e, ok := <-sub.Events():
+			if !ok {
+				if sub.Lagged() {
+					writeEvent(w, "", LaggedEvent, &Error{Error: "fell too far behind"})
+					flusher.Flush()
+				}
+				return
+			}
+			writeEvent(w, fmt.Sprint(e.Seq), string(e.Type), e)
+		}
+		flusher.Flush()
+	}
+}
+
+// writeEvent writes a Server-Sent Event with v as its JSON data.
+func writeEvent(w http.ResponseWriter, id string, name string, v interface{}) {
+	data, err := json.Marshal(v)
+	if err != nil {
+		return
+	}
+	if id != "" {
+		fmt.Fprintf(w, "id: %v\n", id)
+	}
+	fmt.Fprintf(w, "event: %v\ndata: %s\n\n", name, data)
+}
@@ -0,0 +1,267 @@
+// Package gateway serves a node's chain, coins and mempool over
+// HTTP as JSON, for applications that cannot speak gRPC.
+//
+// Endpoints:
+//
+//	GET  /v1/chain                        the height and best hash of the main chain
+//	GET  /v1/blocks/<hash|height>         a block of the main chain
+//	GET  /v1/transactions/<hash>          a transaction, from the main chain or the mempool
+//	POST /v1/transactions                 submit a transaction, and get its validation result
+//	GET  /v1/addresses/<public key>/balance  the confirmed balance of a public key
+//	GET  /v1/addresses/<public key>/utxos    the unspent coins of a public key
+//	GET  /v1/mempool                      the transactions waiting to be mined
+//	GET  /v1/events                       a stream of the node's events
+//
+// Public keys, and scripts, are hex encoded. Errors are returned
+// as an Error, with a matching status code.
+//
+// Events are streamed as Server-Sent Events, each one a
+// notify.Event named after its type. The types query parameter
+// is a comma separated list of the types to get, and the
+// public_keys query parameter a comma separated list of the
+// public keys to get events about, including coins locked by
+// scripts that pay to them; both default to all. A
+// client that falls too far behind gets a "lagged" event and
+// the stream ends, after which it should catch up by polling
+// and subscribe again.
+package gateway
+
+import (
+	"Coin/pkg/block"
+	"Coin/pkg/blockchain"
+	"Coin/pkg/notify"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+// maxBodySize is the largest request body that the gateway
+// reads.
+const maxBodySize = 1 << 20
+
+// ErrUnavailable is returned by Backend.SubmitTransaction when
+// the node cannot take transactions at the moment, as opposed to
+// the transaction being invalid.
+var ErrUnavailable = errors.New("node cannot take transactions now")
+
+// ErrNoMempool is returned by Backend.Mempool when the node does
+// not keep a mempool.
+var ErrNoMempool = errors.New("node keeps no mempool")
+
+// Backend is the node that a gateway serves.
+type Backend interface {
+	// Chain returns the node's chain.
+	Chain() *blockchain.BlockChain
+	// Mempool returns the transactions waiting to be mined,
+	// highest priority first.
+	Mempool() ([]*block.HeapNode, error)
+	// SubmitTransaction validates a transaction and, if it is
+	// valid, relays it.
+	SubmitTransaction(tx *block.Transaction) error
+	// Subscribe subscribes to the node's events that pass a
+	// filter.
+	Subscribe(f *notify.Filter) (*notify.Subscription, error)
+}
+
+// gateway is the http.Handler of a Backend.
+type gateway struct {
+	b   Backend
+	mux *http.ServeMux
+}
+
+// NewHandler returns an http.Handler that serves the gateway of
+// a Backend.
+func NewHandler(b Backend) http.Handler {
+	g := &gateway{b: b, mux: http.NewServeMux()}
+	g.mux.HandleFunc("/v1/chain", g.get(g.chain))
+	g.mux.HandleFunc("/v1/blocks/", g.get(g.block))
+	g.mux.HandleFunc("/v1/transactions/", g.get(g.transaction))
+	g.mux.HandleFunc("/v1/transactions", g.submit)
+	g.mux.HandleFunc("/v1/addresses/", g.get(g.address))
+	g.mux.HandleFunc("/v1/mempool", g.get(g.mempool))
+	g.mux.HandleFunc("/v1/events", g.events)
+	return g
+}
+
+func (g *gateway) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	g.mux.ServeHTTP(w, r)
+}
+
+// httpError is an error along with the status code to answer
+// it with.
+type httpError struct {
+	code int
+	msg  string
+}
+
+func (e *httpError) Error() string {
+	return e.msg
+}
+
+func errorf(code int, format string, a ...interface{}) error {
+	return &httpError{code: code, msg: fmt.Sprintf(format, a...)}
+}
+
+// get adapts a GET endpoint, which returns the value to encode
+// or an error, to an http.HandlerFunc.
+func (g *gateway) get(f func(r *http.Request) (interface{}, error)) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet && r.Method != http.MethodHead {
+			w.Header().Set("Allow", "GET, HEAD")
+			writeJSON(w, http.StatusMethodNotAllowed, &Error{Error: "method not allowed"})
+			return
+		}
+		v, err := f(r)
+		if err != nil {
+			code := http.StatusInternalServerError
+			var herr *httpError
+			if errors.As(err, &herr) {
+				code = herr.code
+			}
+			writeJSON(w, code, &Error{Error: err.Error()})
+			return
+		}
+		writeJSON(w, http.StatusOK, v)
+	}
+}
+
+// writeJSON writes v as the JSON body of a response.
+func writeJSON(w http.ResponseWriter, code int, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(code)
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	_ = enc.Encode(v)
+}
+
+func (g *gateway) chain(r *http.Request) (interface{}, error) {
+	bc := g.b.Chain()
+	return &ChainInfo{Height: bc.Length, BestHash: bc.LastHash}, nil
+}
+
+// block serves a block by its hash or, if the id is a number, by
+// its height.
+func (g *gateway) block(r *http.Request) (interface{}, error) {
+	bc := g.b.Chain()
+	id := strings.TrimPrefix(r.URL.Path, "/v1/blocks/")
+	hash := id
+	if height, err := strconv.ParseUint(id, 10, 32); err == nil {
+		if height < 1 || uint32(height) > bc.Length {
+			return nil, errorf(http.StatusNotFound, "no block at height %v, the chain has %v", height, bc.Length)
+		}
+		hash = bc.GetHashes(uint32(height), uint32(height))[0]
+	}
+	if hash == "" || !bc.BlockInfoDB.HasBlockRecord(hash) {
+		return nil, errorf(http.StatusNotFound, "no block with hash %q", hash)
+	}
+	br := bc.BlockInfoDB.GetBlockRecord(hash)
+	return EncodeBlock(bc.GetBlock(hash), br.Height), nil
+}
+
+// transaction serves a transaction from the mempool or, failing
+// that, the main chain.
+func (g *gateway) transaction(r *http.Request) (interface{}, error) {
+	hash := strings.TrimPrefix(r.URL.Path, "/v1/transactions/")
+	if hash == "" {
+		return nil, errorf(http.StatusNotFound, "no transaction hash given")
+	}
+	if pool, err := g.b.Mempool(); err == nil {
+		for _, node := range pool {
+			if node.Transaction.Hash() == hash {
+				return &TransactionInfo{Transaction: EncodeTransaction(node.Transaction)}, nil
+			}
+		}
+	}
+	tx, blockHash, height := g.b.Chain().FindTransaction(hash)
+	if tx == nil {
+		return nil, errorf(http.StatusNotFound, "no transaction with hash %q", hash)
+	}
+	return &TransactionInfo{
+		Transaction: EncodeTransaction(tx),
+		Confirmed:   true,
+		BlockHash:   blockHash,
+		Height:      height,
+	}, nil
+}
+
+// address serves the balance or the unspent coins of a public
+// key.
+func (g *gateway) address(r *http.Request) (interface{}, error) {
+	parts := strings.Split(strings.TrimPrefix(r.URL.Path, "/v1/addresses/"), "/")
+	if len(parts) != 2 {
+		return nil, errorf(http.StatusNotFound, "want /v1/addresses/<public key>/balance or utxos")
+	}
+	pk, err := hex.DecodeString(parts[0])
+	if err != nil || len(pk) == 0 {
+		return nil, errorf(http.StatusBadRequest, "public key %q is not hex encoded", parts[0])
+	}
+	bc := g.b.Chain()
+	switch parts[1] {
+	case "balance":
+		return &Balance{PublicKey: parts[0], Balance: bc.GetBalance(string(pk))}, nil
+	case "utxos":
+		utxos := &UTXOs{PublicKey: parts[0], UTXOs: []*UTXO{}}
+		locators, coins := bc.CoinDB.GetUnspentCoins(string(pk))
+		for i, c := range coins {
+			utxos.UTXOs = append(utxos.UTXOs, EncodeUTXO(locators[i], c))
+		}
+		return utxos, nil
+	default:
+		return nil, errorf(http.StatusNotFound, "want /v1/addresses/<public key>/balance or utxos")
+	}
+}
+
+func (g *gateway) mempool(r *http.Request) (interface{}, error) {
+	pool, err := g.b.Mempool()
+	if err != nil {
+		return nil, errorf(http.StatusNotFound, "%v", err)
+	}
+	mp := &Mempool{Count: len(pool), Entries: []*MempoolEntry{}}
+	for _, node := range pool {
+		mp.Entries = append(mp.Entries, &MempoolEntry{
+			Priority:    node.Priority,
+			Size:        node.Transaction.Size(),
+			Transaction: EncodeTransaction(node.Transaction),
+		})
+	}
+	return mp, nil
+}
+
+// submit takes a transaction and answers with its validation
+// result. Invalid transactions are answered with 422 and a
+// SubmitResult that says why they were rejected.
+func (g *gateway) submit(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		w.Header().Set("Allow", "POST")
+		writeJSON(w, http.StatusMethodNotAllowed, &Error{Error: "method not allowed"})
+		return
+	}
+	var t Transaction
+	dec := json.NewDecoder(http.MaxBytesReader(w, r.Body, maxBodySize))
+	dec.DisallowUnknownFields()
+	if err := dec.Decode(&t); err != nil {
+		writeJSON(w, http.StatusBadRequest, &Error{Error: fmt.Sprintf("transaction is not valid JSON: %v", err)})
+		return
+	}
+	tx, err := DecodeTransaction(&t)
+	if err != nil {
+		writeJSON(w, http.StatusBadRequest, &Error{Error: err.Error()})
+		return
+	}
+	result := &SubmitResult{Hash: tx.Hash()}
+	if err := g.b.SubmitTransaction(tx); err != nil {
+		if errors.Is(err, ErrUnavailable) {
+			writeJSON(w, http.StatusServiceUnavailable, &Error{Error: err.Error()})
+			return
+		}
+		result.Error = err.Error()
+		writeJSON(w, http.StatusUnprocessableEntity, result)
+		return
+	}
+	result.Accepted = true
+	writeJSON(w, http.StatusOK, result)
+}
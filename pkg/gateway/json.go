@@ -0,0 +1,227 @@
+package gateway
+
+import (
+	"Coin/pkg/block"
+	"Coin/pkg/blockchain/coindatabase"
+	"encoding/hex"
+	"fmt"
+)
+
+// The types in this file are the JSON encodings of the gateway.
+// They are part of its API, so their field names must not change.
+// Scripts and public keys are hex encoded, since they hold raw
+// bytes.
+
+// Header is the JSON encoding of a block.Header.
+type Header struct {
+	Version          uint32 `json:"version"`
+	PreviousHash     string `json:"previous_hash"`
+	MerkleRoot       string `json:"merkle_root"`
+	DifficultyTarget string `json:"difficulty_target"`
+	Nonce            uint32 `json:"nonce"`
+	Timestamp        uint32 `json:"timestamp"`
+}
+
+// Block is the JSON encoding of a block.Block on the main chain.
+// Hash is the hash of the block.
+// Height is its height on the main chain, counting the genesis
+// block as 1.
+type Block struct {
+	Hash         string         `json:"hash"`
+	Height       uint32         `json:"height"`
+	Header       *Header        `json:"header"`
+	Transactions []*Transaction `json:"transactions"`
+}
+
+// Input is the JSON encoding of a block.TransactionInput.
+type Input struct {
+	ReferenceTransactionHash string `json:"reference_transaction_hash"`
+	OutputIndex              uint32 `json:"output_index"`
+	UnlockingScript          string `json:"unlocking_script"`
+}
+
+// Output is the JSON encoding of a block.TransactionOutput.
+type Output struct {
+	Amount        uint32 `json:"amount"`
+	LockingScript string `json:"locking_script"`
+}
+
+// Transaction is the JSON encoding of a block.Transaction.
+// Hash is the hash of the transaction. It is ignored when a
+// transaction is submitted, unless it is set, in which case it
+// must match.
+type Transaction struct {
+	Hash     string    `json:"hash,omitempty"`
+	Version  uint32    `json:"version"`
+	Inputs   []*Input  `json:"inputs"`
+	Outputs  []*Output `json:"outputs"`
+	LockTime uint32    `json:"lock_time"`
+}
+
+// TransactionInfo is a transaction along with where it is.
+// Confirmed is whether it is on the main chain. If it is not, it
+// is waiting in the mempool, and BlockHash and Height are empty.
+type TransactionInfo struct {
+	Transaction *Transaction `json:"transaction"`
+	Confirmed   bool         `json:"confirmed"`
+	BlockHash   string       `json:"block_hash,omitempty"`
+	Height      uint32       `json:"height,omitempty"`
+}
+
+// ChainInfo is the state of the main chain.
+type ChainInfo struct {
+	Height   uint32 `json:"height"`
+	BestHash string `json:"best_hash"`
+}
+
+// Balance is the confirmed balance of a public key.
+type Balance struct {
+	PublicKey string `json:"public_key"`
+	Balance   uint32 `json:"balance"`
+}
+
+// UTXO is the JSON encoding of an unspent coindatabase.Coin and
+// the coindatabase.CoinLocator that spends it.
+type UTXO struct {
+	TransactionHash string `json:"transaction_hash"`
+	OutputIndex     uint32 `json:"output_index"`
+	Amount          uint32 `json:"amount"`
+}
+
+// UTXOs are the unspent coins of a public key.
+type UTXOs struct {
+	PublicKey string  `json:"public_key"`
+	UTXOs     []*UTXO `json:"utxos"`
+}
+
+// MempoolEntry is a transaction waiting to be mined, along with
+// its priority in the miner's pool.
+type MempoolEntry struct {
+	Priority    uint32       `json:"priority"`
+	Size        uint32       `json:"size"`
+	Transaction *Transaction `json:"transaction"`
+}
+
+// Mempool is the transactions waiting to be mined, highest
+// priority first.
+type Mempool struct {
+	Count   int             `json:"count"`
+	Entries []*MempoolEntry `json:"entries"`
+}
+
+// SubmitResult is the result of submitting a transaction.
+// Accepted is whether the transaction passed validation and was
+// relayed. If it was not, Error says why.
+type SubmitResult struct {
+	Hash     string `json:"hash"`
+	Accepted bool   `json:"accepted"`
+	Error    string `json:"error,omitempty"`
+}
+
+// Error is the body of every response that is not a success.
+type Error struct {
+	Error string `json:"error"`
+}
+
+// EncodeHeader returns a Header given a block.Header.
+func EncodeHeader(h *block.Header) *Header {
+	return &Header{
+		Version:          h.Version,
+		PreviousHash:     h.PreviousHash,
+		MerkleRoot:       h.MerkleRoot,
+		DifficultyTarget: h.DifficultyTarget,
+		Nonce:            h.Nonce,
+		Timestamp:        h.Timestamp,
+	}
+}
+
+// EncodeBlock returns a Block given a block.Block and its height.
+func EncodeBlock(b *block.Block, height uint32) *Block {
+	txs := make([]*Transaction, 0, len(b.Transactions))
+	for _, tx := range b.Transactions {
+		txs = append(txs, EncodeTransaction(tx))
+	}
+	return &Block{
+		Hash:         b.Hash(),
+		Height:       height,
+		Header:       EncodeHeader(b.Header),
+		Transactions: txs,
+	}
+}
+
+// EncodeTransaction returns a Transaction given a
+// block.Transaction.
+func EncodeTransaction(tx *block.Transaction) *Transaction {
+	inputs := make([]*Input, 0, len(tx.Inputs))
+	for _, txi := range tx.Inputs {
+		inputs = append(inputs, &Input{
+			ReferenceTransactionHash: txi.ReferenceTransactionHash,
+			OutputIndex:              txi.OutputIndex,
+			UnlockingScript:          hex.EncodeToString([]byte(txi.UnlockingScript)),
+		})
+	}
+	outputs := make([]*Output, 0, len(tx.Outputs))
+	for _, txo := range tx.Outputs {
+		outputs = append(outputs, &Output{
+			Amount:        txo.Amount,
+			LockingScript: hex.EncodeToString([]byte(txo.LockingScript)),
+		})
+	}
+	return &Transaction{
+		Hash:     tx.Hash(),
+		Version:  tx.Version,
+		Inputs:   inputs,
+		Outputs:  outputs,
+		LockTime: tx.LockTime,
+	}
+}
+
+// DecodeTransaction returns a block.Transaction given a
+// Transaction.
+// Returns:
+// error if a script is not hex encoded, or the transaction does
+// not have the hash it claims
+func DecodeTransaction(t *Transaction) (*block.Transaction, error) {
+	tx := &block.Transaction{Version: t.Version, LockTime: t.LockTime}
+	for i, in := range t.Inputs {
+		if in == nil {
+			return nil, fmt.Errorf("input %v is null", i)
+		}
+		script, err := hex.DecodeString(in.UnlockingScript)
+		if err != nil {
+			return nil, fmt.Errorf("unlocking script of input %v is not hex encoded", i)
+		}
+		tx.Inputs = append(tx.Inputs, &block.TransactionInput{
+			ReferenceTransactionHash: in.ReferenceTransactionHash,
+			OutputIndex:              in.OutputIndex,
+			UnlockingScript:          string(script),
+		})
+	}
+	for i, out := range t.Outputs {
+		if out == nil {
+			return nil, fmt.Errorf("output %v is null", i)
+		}
+		script, err := hex.DecodeString(out.LockingScript)
+		if err != nil {
+			return nil, fmt.Errorf("locking script of output %v is not hex encoded", i)
+		}
+		tx.Outputs = append(tx.Outputs, &block.TransactionOutput{
+			Amount:        out.Amount,
+			LockingScript: string(script),
+		})
+	}
+	if t.Hash != "" && t.Hash != tx.Hash() {
+		return nil, fmt.Errorf("transaction hashes to %v, not %v", tx.Hash(), t.Hash)
+	}
+	return tx, nil
+}
+
+// EncodeUTXO returns a UTXO given an unspent coindatabase.Coin and
+// its coindatabase.CoinLocator.
+func EncodeUTXO(cl coindatabase.CoinLocator, c *coindatabase.Coin) *UTXO {
+	return &UTXO{
+		TransactionHash: cl.ReferenceTransactionHash,
+		OutputIndex:     cl.OutputIndex,
+		Amount:          c.TransactionOutput.Amount,
+	}
+}
@@ -0,0 +1,89 @@
+package id
+
+import (
+	"crypto/ecdsa"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/hex"
+	"errors"
+	"math/big"
+	"time"
+)
+
+// certificateLifetime is how long the self-signed
+// certificates made by Certificate are valid for.
+const certificateLifetime = 10 * 365 * 24 * time.Hour
+
+// Certificate returns a self-signed TLS certificate
+// whose key pair is the key pair of the id. Presenting
+// it during a TLS handshake proves that we hold the
+// private key behind our public key.
+// Inputs:
+// i ID the id to make the certificate for
+// Returns:
+// tls.Certificate the certificate
+// error any error that happened while creating it
+func Certificate(i ID) (tls.Certificate, error) {
+	sk := i.GetPrivateKey()
+	if sk == nil {
+		return tls.Certificate{}, errors.New("id has no private key")
+	}
+	serial, err := rand.Int(rand.Reader, new(big.Int).Lsh(big.NewInt(1), 128))
+	if err != nil {
+		return tls.Certificate{}, err
+	}
+	template := &x509.Certificate{
+		SerialNumber:          serial,
+		Subject:               pkix.Name{CommonName: PublicKeyHex(&sk.PublicKey)},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(certificateLifetime),
+		KeyUsage:              x509.KeyUsageDigitalSignature,
+		ExtKeyUsage:           []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth, x509.ExtKeyUsageClientAuth},
+		BasicConstraintsValid: true,
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &sk.PublicKey, sk)
+	if err != nil {
+		return tls.Certificate{}, err
+	}
+	return tls.Certificate{Certificate: [][]byte{der}, PrivateKey: sk}, nil
+}
+
+// VerifyCertificate checks a certificate chain presented
+// by a peer during a TLS handshake. The chain must be a
+// single ECDSA certificate that is signed by its own key,
+// as made by Certificate.
+// Inputs:
+// rawCerts [][]byte the certificates presented by the peer
+// Returns:
+// string the peer's public key as a hex string
+// error if the certificate is not valid
+func VerifyCertificate(rawCerts [][]byte) (string, error) {
+	if len(rawCerts) != 1 {
+		return "", errors.New("expected exactly one certificate")
+	}
+	cert, err := x509.ParseCertificate(rawCerts[0])
+	if err != nil {
+		return "", err
+	}
+	pk, ok := cert.PublicKey.(*ecdsa.PublicKey)
+	if !ok {
+		return "", errors.New("certificate key is not an ecdsa key")
+	}
+	if err = cert.CheckSignature(cert.SignatureAlgorithm, cert.RawTBSCertificate, cert.Signature); err != nil {
+		return "", err
+	}
+	return PublicKeyHex(pk), nil
+}
+
+// PublicKeyHex returns the hex encoding of a public key's
+// PKIX bytes. This is how nodes advertise their identity
+// to each other.
+func PublicKeyHex(pk *ecdsa.PublicKey) string {
+	b, err := x509.MarshalPKIXPublicKey(pk)
+	if err != nil {
+		return ""
+	}
+	return hex.EncodeToString(b)
+}
@@ -0,0 +1,70 @@
+package id
+
+import (
+	"crypto/x509"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"os"
+)
+
+// pemType is the PEM block type of a saved private key.
+const pemType = "EC PRIVATE KEY"
+
+// LoadOrCreate loads the id saved at path, or, if there is
+// none, creates a new one and saves it there. This lets a
+// node keep its identity, and the peers that pinned it,
+// across restarts.
+// Inputs:
+// path string the file the id is saved in
+// Returns:
+// ID the id
+// error if the file could not be read, parsed or written
+func LoadOrCreate(path string) (ID, error) {
+	data, err := ioutil.ReadFile(path)
+	if os.IsNotExist(err) {
+		i, err := CreateSimpleID()
+		if err != nil {
+			return nil, err
+		}
+		return i, Save(i, path)
+	}
+	if err != nil {
+		return nil, err
+	}
+	block, _ := pem.Decode(data)
+	if block == nil || block.Type != pemType {
+		return nil, fmt.Errorf("%v does not hold a private key", path)
+	}
+	sk, err := x509.ParseECPrivateKey(block.Bytes)
+	if err != nil {
+		return nil, fmt.Errorf("%v: %v", path, err)
+	}
+	pk, err := x509.MarshalPKIXPublicKey(&sk.PublicKey)
+	if err != nil {
+		return nil, err
+	}
+	return &SimpleID{
+		PrivateKey:      sk,
+		PrivateKeyBytes: block.Bytes,
+		PublicKey:       &sk.PublicKey,
+		PublicKeyBytes:  pk,
+	}, nil
+}
+
+// Save writes the private key of an id to path, readable
+// only by its owner.
+// Inputs:
+// i ID the id to save
+// path string the file to save it in
+func Save(i ID, path string) error {
+	if i.GetPrivateKey() == nil {
+		return errors.New("id has no private key")
+	}
+	der, err := x509.MarshalECPrivateKey(i.GetPrivateKey())
+	if err != nil {
+		return err
+	}
+	return ioutil.WriteFile(path, pem.EncodeToMemory(&pem.Block{Type: pemType, Bytes: der}), 0600)
+}
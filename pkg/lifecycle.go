@@ -0,0 +1,76 @@
+package pkg
+
+import (
+	"Coin/pkg/utils"
+	"context"
+)
+
+// goroutine runs f in a background goroutine that the node
+// waits for when it shuts down. f must return once the
+// node's context is canceled. Once the node started shutting
+// down, f is not run at all.
+func (n *Node) goroutine(f func()) {
+	n.runningMutex.Lock()
+	defer n.runningMutex.Unlock()
+	if n.stopping {
+		return
+	}
+	n.wg.Add(1)
+	go func() {
+		defer n.wg.Done()
+		f()
+	}()
+}
+
+// Kill kills any threads currently managed by the Node or that
+// it previously started. It also does any necessary clean up.
+// It stops, in order: the servers, so no new requests come in;
+// the node's background goroutines; the miner; the connections
+// to other nodes. It then flushes the coin cache to disk and
+// closes the databases. Kill is safe to call more than once,
+// and returns once the node has shut down.
+func (n *Node) Kill() {
+	n.stopOnce.Do(n.shutdown)
+	<-n.done
+}
+
+// Done returns a channel that is closed once the node has
+// shut down.
+func (n *Node) Done() <-chan struct{} {
+	return n.done
+}
+
+// shutdown does the work of Kill.
+func (n *Node) shutdown() {
+	defer close(n.done)
+	if n.Server != nil {
+		n.Server.GracefulStop()
+	}
+	if n.AdminServer != nil {
+		n.AdminServer.GracefulStop()
+	}
+	// event streams never go idle, so they are ended first
+	n.Events.Close()
+	if n.GatewayServer != nil {
+		_ = n.GatewayServer.Shutdown(context.Background())
+	}
+	if n.MetricsServer != nil {
+		_ = n.MetricsServer.Shutdown(context.Background())
+	}
+	if n.ExplorerServer != nil {
+		_ = n.ExplorerServer.Shutdown(context.Background())
+	}
+	n.runningMutex.Lock()
+	n.stopping = true
+	n.runningMutex.Unlock()
+	n.cancel()
+	n.wg.Wait()
+	if n.Miner != nil {
+		n.Miner.Kill()
+	}
+	n.Conns.Close()
+	n.BlockChain.Close()
+	n.BanList.Close()
+	n.AddressDB.Close()
+	n.logger(utils.ComponentNode).Info("shut down")
+}
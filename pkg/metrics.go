@@ -0,0 +1,131 @@
+package pkg
+
+import (
+	"Coin/pkg/metrics"
+	"Coin/pkg/utils"
+	"net"
+	"net/http"
+	"path"
+	"time"
+)
+
+// nodeStats are the metrics that the node updates as it runs.
+// Metrics that can be read off the node's components are read
+// when the node is scraped instead.
+type nodeStats struct {
+	rpcClient       *metrics.HistogramVec
+	rpcClientErrors *metrics.CounterVec
+	rpcServer       *metrics.HistogramVec
+	txRelayed       *metrics.Counter
+	txRejected      *metrics.CounterVec
+	blocksRelayed   *metrics.Counter
+	blocksRejected  *metrics.CounterVec
+}
+
+// registerMetrics registers the node's metrics on n.Metrics and
+// returns the ones the node updates itself.
+func (n *Node) registerMetrics() *nodeStats {
+	r := n.Metrics
+	bc := n.BlockChain
+	r.NewGaugeFunc("coin_chain_height", "Length of the main chain.", func() float64 {
+		return float64(bc.Length)
+	})
+	r.NewGaugeFunc("coin_chain_tip_age_seconds", "Seconds since the main chain last got a new last block.", func() float64 {
+		return time.Since(time.Unix(0, bc.TipTime.Load())).Seconds()
+	})
+	r.NewCounterFunc("coin_chain_reorgs_total", "Forks that replaced the main chain.", bc.Reorgs.Load)
+	r.NewCounterFunc("coin_chain_reorged_blocks_total", "Blocks reverted by forks.", bc.ReorgedBlocks.Load)
+	r.NewGaugeFunc("coin_chain_reorg_depth_max", "Most blocks reverted by a single fork.", func() float64 {
+		return float64(bc.MaxReorgDepth.Load())
+	})
+
+	r.NewCounterFunc("coin_coindb_cache_hits_total", "Coins found in the coin database's cache.", func() uint64 {
+		hits, _, _ := bc.CoinDB.CacheStats()
+		return hits
+	})
+	r.NewCounterFunc("coin_coindb_cache_misses_total", "Coins not found in the coin database's cache.", func() uint64 {
+		_, misses, _ := bc.CoinDB.CacheStats()
+		return misses
+	})
+	r.NewGaugeFunc("coin_coindb_cache_hit_ratio", "Share of coin lookups served by the coin database's cache.", func() float64 {
+		hits, misses, _ := bc.CoinDB.CacheStats()
+		if hits+misses == 0 {
+			return 0
+		}
+		return float64(hits) / float64(hits+misses)
+	})
+	r.NewCounterFunc("coin_coindb_flushes_total", "Flushes of the coin database's cache.", func() uint64 {
+		_, _, flushes := bc.CoinDB.CacheStats()
+		return flushes
+	})
+
+	if n.Config.MinerConfig.HasMiner {
+		m := n.Miner
+		r.NewGaugeFunc("coin_txpool_transactions", "Transactions waiting to be mined.", func() float64 {
+			return float64(m.TxPool.Length())
+		})
+		r.NewGaugeFunc("coin_txpool_priority", "Total priority of the transactions waiting to be mined.", func() float64 {
+			return float64(m.TxPool.CurrentPriority.Load())
+		})
+		r.NewCounterFunc("coin_miner_hashes_total", "Nonces tried by the miner. Its rate is the hash rate.", m.Hashes.Load)
+		r.NewGaugeFunc("coin_miner_active", "Whether the miner is on.", func() float64 {
+			if m.Active.Load() {
+				return 1
+			}
+			return 0
+		})
+	}
+
+	r.NewGaugeFunc("coin_peers_inbound", "Peers that connected to the node.", func() float64 {
+		return float64(n.InboundCount())
+	})
+	r.NewGaugeFunc("coin_peers_outbound", "Peers that the node connected to.", func() float64 {
+		return float64(n.OutboundCount())
+	})
+
+	return &nodeStats{
+		rpcClient: r.NewHistogramVec("coin_rpc_client_duration_seconds",
+			"Latency of RPCs the node made to other nodes.", metrics.LatencyBuckets, "method"),
+		rpcClientErrors: r.NewCounterVec("coin_rpc_client_errors_total",
+			"RPCs the node made to other nodes that failed.", "method"),
+		rpcServer: r.NewHistogramVec("coin_rpc_server_duration_seconds",
+			"Time the node took to handle RPCs from other nodes.", metrics.LatencyBuckets, "method"),
+		txRelayed: r.NewCounter("coin_transactions_relayed_total",
+			"Valid transactions the node relayed."),
+		txRejected: r.NewCounterVec("coin_transactions_rejected_total",
+			"Invalid transactions the node rejected, by reason.", "reason"),
+		blocksRelayed: r.NewCounter("coin_blocks_relayed_total",
+			"Valid blocks the node relayed."),
+		blocksRejected: r.NewCounterVec("coin_blocks_rejected_total",
+			"Invalid blocks the node rejected, by reason.", "reason"),
+	}
+}
+
+// observeRPC records an RPC the node made to another node.
+func (n *Node) observeRPC(method string, took time.Duration, err error) {
+	method = path.Base(method)
+	n.stats.rpcClient.With(method).Observe(took.Seconds())
+	if err != nil {
+		n.stats.rpcClientErrors.With(method).Inc()
+	}
+}
+
+// StartMetricsServer serves the node's metrics on addr, at
+// /metrics, in the Prometheus text format.
+// Returns:
+// error if the node could not listen on addr
+func (n *Node) StartMetricsServer(addr string) error {
+	lis, err := net.Listen("tcp", addr)
+	if err != nil {
+		return err
+	}
+	mux := http.NewServeMux()
+	mux.Handle("/metrics", n.Metrics.Handler())
+	n.MetricsServer = &http.Server{Handler: mux}
+	go func() {
+		if err := n.MetricsServer.Serve(lis); err != nil && err != http.ErrServerClosed {
+			n.logger(utils.ComponentRPC).Error("unable to serve metrics server", "addr", addr, "err", err)
+		}
+	}()
+	return nil
+}
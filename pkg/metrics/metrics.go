@@ -0,0 +1,367 @@
+// Package metrics keeps counters, gauges and histograms and
+// writes them in the Prometheus text exposition format, so that
+// a node can be scraped without pulling in a client library.
+package metrics
+
+import (
+	"fmt"
+	"go.uber.org/atomic"
+	"io"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// ContentType is the content type of the text exposition format.
+const ContentType = "text/plain; version=0.0.4; charset=utf-8"
+
+// LatencyBuckets are histogram buckets, in seconds, suited to
+// the latency of RPCs.
+var LatencyBuckets = []float64{.001, .0025, .005, .01, .025, .05, .1, .25, .5, 1, 2.5}
+
+// sample is one line of a metric family.
+// suffix is appended to the family's name, as with the _bucket,
+// _sum and _count lines of a histogram.
+type sample struct {
+	suffix string
+	labels []string
+	values []string
+	value  float64
+}
+
+// family is a metric and its samples.
+// kind is its Prometheus type, such as "counter".
+// collect returns its samples at the time of a scrape.
+type family struct {
+	name    string
+	help    string
+	kind    string
+	collect func() []sample
+}
+
+// Registry is a set of metrics that can be written out together.
+type Registry struct {
+	families map[string]*family
+	mutex    sync.Mutex
+}
+
+// NewRegistry returns an empty Registry.
+func NewRegistry() *Registry {
+	return &Registry{families: make(map[string]*family)}
+}
+
+// register adds a metric family. Metric names are fixed in code,
+// so registering one twice is a programming error.
+func (r *Registry) register(f *family) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	if _, ok := r.families[f.name]; ok {
+		panic(fmt.Sprintf("metric %v registered twice", f.name))
+	}
+	r.families[f.name] = f
+}
+
+// Counter is a count that only goes up.
+type Counter struct {
+	v atomic.Uint64
+}
+
+// Inc adds one to the counter.
+func (c *Counter) Inc() {
+	c.v.Inc()
+}
+
+// Add adds d to the counter.
+func (c *Counter) Add(d uint64) {
+	c.v.Add(d)
+}
+
+// Value returns the count.
+func (c *Counter) Value() uint64 {
+	return c.v.Load()
+}
+
+// NewCounter registers and returns a counter.
+func (r *Registry) NewCounter(name, help string) *Counter {
+	c := &Counter{}
+	r.register(&family{name: name, help: help, kind: "counter", collect: func() []sample {
+		return []sample{{value: float64(c.Value())}}
+	}})
+	return c
+}
+
+// NewCounterFunc registers a counter whose count is read from f
+// at every scrape, for counts that are kept elsewhere.
+func (r *Registry) NewCounterFunc(name, help string, f func() uint64) {
+	r.register(&family{name: name, help: help, kind: "counter", collect: func() []sample {
+		return []sample{{value: float64(f())}}
+	}})
+}
+
+// Gauge is a value that can go up and down.
+type Gauge struct {
+	v atomic.Float64
+}
+
+// Set sets the gauge to v.
+func (g *Gauge) Set(v float64) {
+	g.v.Store(v)
+}
+
+// Add adds d to the gauge.
+func (g *Gauge) Add(d float64) {
+	g.v.Add(d)
+}
+
+// Value returns the value of the gauge.
+func (g *Gauge) Value() float64 {
+	return g.v.Load()
+}
+
+// NewGauge registers and returns a gauge.
+func (r *Registry) NewGauge(name, help string) *Gauge {
+	g := &Gauge{}
+	r.register(&family{name: name, help: help, kind: "gauge", collect: func() []sample {
+		return []sample{{value: g.Value()}}
+	}})
+	return g
+}
+
+// NewGaugeFunc registers a gauge whose value is read from f at
+// every scrape.
+func (r *Registry) NewGaugeFunc(name, help string, f func() float64) {
+	r.register(&family{name: name, help: help, kind: "gauge", collect: func() []sample {
+		return []sample{{value: f()}}
+	}})
+}
+
+// CounterVec is a set of counters told apart by the values of
+// their labels.
+type CounterVec struct {
+	labels   []string
+	children map[string]*Counter
+	values   map[string][]string
+	mutex    sync.Mutex
+}
+
+// With returns the counter with the given label values, in the
+// order the labels were registered in, creating it if needed.
+func (v *CounterVec) With(values ...string) *Counter {
+	if len(values) != len(v.labels) {
+		panic(fmt.Sprintf("want %v label values, got %v", len(v.labels), len(values)))
+	}
+	key := strings.Join(values, "\xff")
+	v.mutex.Lock()
+	defer v.mutex.Unlock()
+	c, ok := v.children[key]
+	if !ok {
+		c = &Counter{}
+		v.children[key] = c
+		v.values[key] = append([]string(nil), values...)
+	}
+	return c
+}
+
+// NewCounterVec registers and returns a set of counters with the
+// given labels.
+func (r *Registry) NewCounterVec(name, help string, labels ...string) *CounterVec {
+	v := &CounterVec{
+		labels:   labels,
+		children: make(map[string]*Counter),
+		values:   make(map[string][]string),
+	}
+	r.register(&family{name: name, help: help, kind: "counter", collect: func() []sample {
+		v.mutex.Lock()
+		defer v.mutex.Unlock()
+		var samples []sample
+		for _, key := range sortedKeys(v.children) {
+			samples = append(samples, sample{labels: v.labels, values: v.values[key], value: float64(v.children[key].Value())})
+		}
+		return samples
+	}})
+	return v
+}
+
+// Histogram counts observations in buckets.
+// buckets are the upper bounds of the buckets, in increasing
+// order. counts holds the number of observations in each bucket,
+// without the ones in lower buckets, and then the number of
+// observations above every bound.
+type Histogram struct {
+	buckets []float64
+	counts  []uint64
+	sum     float64
+	mutex   sync.Mutex
+}
+
+// Observe records an observation.
+func (h *Histogram) Observe(v float64) {
+	i := sort.SearchFloat64s(h.buckets, v)
+	h.mutex.Lock()
+	h.counts[i]++
+	h.sum += v
+	h.mutex.Unlock()
+}
+
+// samples returns the _bucket, _sum and _count lines of the
+// histogram, with the given labels before the le label.
+func (h *Histogram) samples(labels, values []string) []sample {
+	h.mutex.Lock()
+	counts := append([]uint64(nil), h.counts...)
+	sum := h.sum
+	h.mutex.Unlock()
+	bucketLabels := append(append([]string(nil), labels...), "le")
+	var samples []sample
+	var total uint64
+	for i, count := range counts {
+		total += count
+		le := "+Inf"
+		if i < len(h.buckets) {
+			le = formatFloat(h.buckets[i])
+		}
+		samples = append(samples, sample{
+			suffix: "_bucket",
+			labels: bucketLabels,
+			values: append(append([]string(nil), values...), le),
+			value:  float64(total),
+		})
+	}
+	return append(samples,
+		sample{suffix: "_sum", labels: labels, values: values, value: sum},
+		sample{suffix: "_count", labels: labels, values: values, value: float64(total)})
+}
+
+// HistogramVec is a set of histograms told apart by the values
+// of their labels.
+type HistogramVec struct {
+	buckets  []float64
+	labels   []string
+	children map[string]*Histogram
+	values   map[string][]string
+	mutex    sync.Mutex
+}
+
+// With returns the histogram with the given label values, in the
+// order the labels were registered in, creating it if needed.
+func (v *HistogramVec) With(values ...string) *Histogram {
+	if len(values) != len(v.labels) {
+		panic(fmt.Sprintf("want %v label values, got %v", len(v.labels), len(values)))
+	}
+	key := strings.Join(values, "\xff")
+	v.mutex.Lock()
+	defer v.mutex.Unlock()
+	h, ok := v.children[key]
+	if !ok {
+		h = &Histogram{buckets: v.buckets, counts: make([]uint64, len(v.buckets)+1)}
+		v.children[key] = h
+		v.values[key] = append([]string(nil), values...)
+	}
+	return h
+}
+
+// NewHistogramVec registers and returns a set of histograms with
+// the given buckets and labels.
+func (r *Registry) NewHistogramVec(name, help string, buckets []float64, labels ...string) *HistogramVec {
+	v := &HistogramVec{
+		buckets:  append([]float64(nil), buckets...),
+		labels:   labels,
+		children: make(map[string]*Histogram),
+		values:   make(map[string][]string),
+	}
+	sort.Float64s(v.buckets)
+	r.register(&family{name: name, help: help, kind: "histogram", collect: func() []sample {
+		v.mutex.Lock()
+		defer v.mutex.Unlock()
+		var samples []sample
+		for _, key := range sortedKeys(v.children) {
+			samples = append(samples, v.children[key].samples(v.labels, v.values[key])...)
+		}
+		return samples
+	}})
+	return v
+}
+
+// WriteTo writes every metric in the registry, ordered by name,
+// in the text exposition format.
+func (r *Registry) WriteTo(w io.Writer) (int64, error) {
+	r.mutex.Lock()
+	families := make([]*family, 0, len(r.families))
+	for _, f := range r.families {
+		families = append(families, f)
+	}
+	r.mutex.Unlock()
+	sort.Slice(families, func(i, j int) bool {
+		return families[i].name < families[j].name
+	})
+
+	var b strings.Builder
+	for _, f := range families {
+		name := f.name
+		samples := f.collect()
+		if len(samples) == 0 {
+			continue
+		}
+		fmt.Fprintf(&b, "# HELP %v %v\n", name, escapeHelp(f.help))
+		fmt.Fprintf(&b, "# TYPE %v %v\n", name, f.kind)
+		for _, s := range samples {
+			b.WriteString(name + s.suffix)
+			if len(s.labels) > 0 {
+				b.WriteByte('{')
+				for i, label := range s.labels {
+					if i > 0 {
+						b.WriteByte(',')
+					}
+					fmt.Fprintf(&b, "%v=\"%v\"", label, escapeLabel(s.values[i]))
+				}
+				b.WriteByte('}')
+			}
+			b.WriteString(" " + formatFloat(s.value) + "\n")
+		}
+	}
+	n, err := io.WriteString(w, b.String())
+	return int64(n), err
+}
+
+// Handler returns an http.Handler that serves the registry.
+func (r *Registry) Handler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		w.Header().Set("Content-Type", ContentType)
+		_, _ = r.WriteTo(w)
+	})
+}
+
+// formatFloat formats a sample value the way Prometheus reads it.
+func formatFloat(v float64) string {
+	return strconv.FormatFloat(v, 'g', -1, 64)
+}
+
+var helpEscaper = strings.NewReplacer(`\`, `\\`, "\n", `\n`)
+var labelEscaper = strings.NewReplacer(`\`, `\\`, "\n", `\n`, `"`, `\"`)
+
+func escapeHelp(s string) string {
+	return helpEscaper.Replace(s)
+}
+
+func escapeLabel(s string) string {
+	return labelEscaper.Replace(s)
+}
+
+// sortedKeys returns the keys of a map of children in order, so
+// that samples are written in a stable order.
+func sortedKeys(m interface{}) []string {
+	var keys []string
+	switch m := m.(type) {
+	case map[string]*Counter:
+		for k := range m {
+			keys = append(keys, k)
+		}
+	case map[string]*Histogram:
+		for k := range m {
+			keys = append(keys, k)
+		}
+	}
+	sort.Strings(keys)
+	return keys
+}
@@ -71,3 +71,21 @@ func DefaultConfig(powdNumZeros int) *Config {
 		InitialPOWDifficulty:    utils.CalcPOWD(powdNumZeros),
 	}
 }
+
+// NilConfig returns settings that say
+// the miner should not exist.
+func NilConfig() *Config {
+	return &Config{
+		HasMiner:                false,
+		Version:                 0,
+		DefineLockTime:          0,
+		TransactionPoolCapacity: 0,
+		PriorityLimit:           0,
+		BlockSize:               0,
+		NonceLimit:              0,
+		InitialSubsidy:          0,
+		SubsidyHalvingRate:      0,
+		MaxHalvings:             0,
+		InitialPOWDifficulty:    nil,
+	}
+}
@@ -17,7 +17,9 @@ func (m *Miner) Mine() *block.Block {
 
 // CalculateNonce finds a winning nonce for a block. It uses context to
 // know whether it should quit before it finds a nonce (if another block
-// was found). ASICSs are optimized for this task.
+// was found). ASICSs are optimized for this task. Every nonce
+// tried is counted in m.Hashes, which is what the hash rate is
+// measured from.
 func (m *Miner) CalculateNonce(ctx context.Context, b *block.Block) bool {
 	//TODO
 	return false
@@ -42,10 +44,18 @@ func (m *Miner) getInputSums(txs []*block.Transaction) ([]uint32, error) {
 	ctx, cancel := context.WithTimeout(context.Background(), 1*time.Second)
 	defer cancel()
 	// ask the node to sum the inputs for our transactions
-	m.GetInputSums <- txs
+	select {
+	case m.GetInputSums <- txs:
+	case <-ctx.Done():
+		return []uint32{0}, fmt.Errorf("[miner.sumInputs] Error: timed out")
+	case <-m.quit:
+		return []uint32{0}, fmt.Errorf("[miner.sumInputs] Error: miner was killed")
+	}
 	// wait until we get a response from the node in our SumInputs channel
 	for {
 		select {
+		case <-m.quit:
+			return []uint32{0}, fmt.Errorf("[miner.sumInputs] Error: miner was killed")
 		case <-ctx.Done():
 			// Oops! We ran out of time
 			return []uint32{0}, fmt.Errorf("[miner.sumInputs] Error: timed out")
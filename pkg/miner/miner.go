@@ -5,7 +5,6 @@ import (
 	"Coin/pkg/blockchain"
 	"Coin/pkg/id"
 	"Coin/pkg/utils"
-	"fmt"
 	"go.uber.org/atomic"
 	"sync"
 )
@@ -18,11 +17,15 @@ import (
 // ChainLength is the length of the main chain.
 // Active is a channel used to entirely shut down the miner's ability to mine.
 // Mining tells whether the miner is currently mining.
+// Hashes is how many nonces the miner has tried, which CalculateNonce adds to.
 // SendBlock is used to send newly mined blocks to the node in order to be broadcast on the network.
-// PoolUpdated is used to send alerts of pool updates to the miner
+// PoolUpdated is used to send alerts of pool updates to the miner. It holds at most
+// one alert, so alerts that arrive while one is pending are merged into it.
 // GetInputCoins is used by the miner to ask the node for the coins used for the inputs on
 // a block
 // InputCoins is the channel by which the node sends the requested coins back to the miner
+// quit is closed when the miner is killed, so that nothing blocks on the channels above afterwards.
+// log is the miner's logger, which tags entries with Address.
 type Miner struct {
 	Config *Config
 	Id     id.ID
@@ -37,6 +40,7 @@ type Miner struct {
 
 	Active *atomic.Bool
 	Mining *atomic.Bool
+	Hashes *atomic.Uint64
 
 	SendBlock   chan *block.Block
 	PoolUpdated chan bool
@@ -44,7 +48,10 @@ type Miner struct {
 	GetInputSums chan []*block.Transaction
 	InputSums    chan []uint32
 
-	mutex sync.Mutex
+	quit     chan struct{}
+	killOnce sync.Once
+	mutex    sync.Mutex
+	log      *utils.Logger
 }
 
 // New constructs a new Miner according to a config and the id of a node.
@@ -60,12 +67,15 @@ func New(c *Config, id id.ID) *Miner {
 		MiningPool:       []*block.Transaction{},
 		ChainLength:      atomic.NewUint32(1),
 		SendBlock:        make(chan *block.Block),
-		PoolUpdated:      make(chan bool),
+		PoolUpdated:      make(chan bool, 1),
 		GetInputSums:     make(chan []*block.Transaction),
 		InputSums:        make(chan []uint32),
 		Mining:           atomic.NewBool(false),
+		Hashes:           atomic.NewUint64(0),
 		DifficultyTarget: c.InitialPOWDifficulty,
 		Active:           atomic.NewBool(false),
+		quit:             make(chan struct{}),
+		log:              utils.NewLogger(utils.ComponentMiner),
 	}
 }
 
@@ -73,9 +83,17 @@ func New(c *Config, id id.ID) *Miner {
 func (m *Miner) SetAddress(a string) {
 	m.mutex.Lock()
 	m.Address = a
+	m.log = utils.NewLogger(utils.ComponentMiner).With("node", a)
 	m.mutex.Unlock()
 }
 
+// logger returns the miner's logger.
+func (m *Miner) logger() *utils.Logger {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+	return m.log
+}
+
 // StartMiner is a wrapper around the mine method just in case any additional work is needed to do before or after
 // mining in the future.
 func (m *Miner) StartMiner() {
@@ -103,14 +121,13 @@ func (m *Miner) HandleBlock(b *block.Block) {
 // on the new transactions in the block.
 func (m *Miner) UpdateTXPool(txs []*block.Transaction) {
 	if txs == nil {
-		fmt.Printf("ERROR {Miner.HndlChkBlk}: The " +
-			"slice of inputted transactions was nil.\n")
+		m.logger().Error("received nil transactions", "func", "Miner.UpdateTXPool")
 		return
 	}
 	//T
 	m.TxPool.CheckTransactions(txs)
 	if m.Active.Load() {
-		m.PoolUpdated <- true
+		m.notifyPoolUpdated()
 	}
 }
 
@@ -120,17 +137,16 @@ func (m *Miner) UpdateTXPool(txs []*block.Transaction) {
 // t *block.Transaction the validated transaction that was received from the network
 func (m *Miner) HandleTransaction(t *block.Transaction) {
 	if t == nil {
-		fmt.Printf("ERROR {Miner.HndlTx}: The" +
-			"inputted transaction was nil.\n")
+		m.logger().Error("received a nil transaction", "func", "Miner.HandleTransaction")
 		return
 	}
 	sums, err := m.getInputSums([]*block.Transaction{t})
 	if err != nil {
-		utils.Debug.Printf("[miner.HandleTransaction] Failed to get inputs for transaction")
+		m.logger().Warn("unable to sum transaction inputs", "transaction", t.Hash(), "err", err)
 	}
 	m.TxPool.Add(t, sums[0])
 	if m.Active.Load() {
-		m.PoolUpdated <- true
+		m.notifyPoolUpdated()
 	}
 }
 
@@ -148,19 +164,38 @@ func (m *Miner) IncrementChainLength() {
 
 func (m *Miner) Pause() {
 	m.Active.Store(false)
-	m.PoolUpdated <- true
-	utils.Debug.Printf("%v paused mining", utils.FmtAddr(m.Address))
+	m.notifyPoolUpdated()
+	m.logger().Info("paused mining")
 }
 
 func (m *Miner) Resume() {
 	m.Active.Store(true)
-	m.PoolUpdated <- true
-	utils.Debug.Printf("%v resumed mining", utils.FmtAddr(m.Address))
+	m.notifyPoolUpdated()
+	m.logger().Info("resumed mining")
 }
 
-// Kill closes the miner's channels and stops the current mining process.
+// notifyPoolUpdated tells the mining process that the pool
+// changed. It never blocks: if an alert is already pending,
+// the mining process has yet to see it and will see this
+// change too.
+func (m *Miner) notifyPoolUpdated() {
+	select {
+	case m.PoolUpdated <- true:
+	default:
+	}
+}
+
+// Quit returns a channel that is closed when the miner is killed.
+// Anything that sends or waits on the miner's channels should
+// give up once it is closed.
+func (m *Miner) Quit() <-chan struct{} {
+	return m.quit
+}
+
+// Kill stops the current mining process. It is safe to call more than once.
 func (m *Miner) Kill() {
-	m.Active.Store(false)
-	close(m.PoolUpdated)
-	close(m.SendBlock)
+	m.killOnce.Do(func() {
+		m.Active.Store(false)
+		close(m.quit)
+	})
 }
@@ -2,11 +2,15 @@ package miner
 
 import (
 	"Coin/pkg/block"
-	"fmt"
+	"Coin/pkg/utils"
 	"go.uber.org/atomic"
+	"sort"
 	"sync"
 )
 
+// logger logs for transaction pools.
+var logger = utils.NewLogger(utils.ComponentMiner)
+
 // TxPool represents all the valid transactions
 // that the miner can mine.
 // CurrentPriority is the current cumulative priority of
@@ -19,6 +23,8 @@ import (
 // in the pool.
 // Cap is the maximum amount of allowed
 // transactions to store in the pool.
+// observer, if set, is told about every transaction that
+// enters or leaves the pool.
 type TxPool struct {
 	CurrentPriority *atomic.Uint32
 	PriorityLimit   uint32
@@ -27,7 +33,15 @@ type TxPool struct {
 	Count    *atomic.Uint32
 	Capacity uint32
 
-	Mutex sync.Mutex
+	Mutex    sync.Mutex
+	observer func(t *block.Transaction, added bool)
+}
+
+// Observe has the pool tell f about every transaction that
+// enters it, with added true, or leaves it, with added false.
+// It must be called before any transactions are added.
+func (tp *TxPool) Observe(f func(t *block.Transaction, added bool)) {
+	tp.observer = f
 }
 
 // Length returns the count of transactions
@@ -38,6 +52,19 @@ func (tp *TxPool) Length() uint32 {
 	return tp.Count.Load()
 }
 
+// List returns the transactions in the pool with
+// their priorities, highest priority first.
+func (tp *TxPool) List() []*block.HeapNode {
+	tp.Mutex.Lock()
+	nodes := make([]*block.HeapNode, len(*tp.TxQ))
+	copy(nodes, *tp.TxQ)
+	tp.Mutex.Unlock()
+	sort.SliceStable(nodes, func(i, j int) bool {
+		return nodes[i].Priority > nodes[j].Priority
+	})
+	return nodes
+}
+
 // NewTxPool constructs a transaction pool.
 func NewTxPool(c *Config) *TxPool {
 	return &TxPool{
@@ -62,8 +89,7 @@ func (tp *TxPool) PriorityMet() bool {
 // transaction.
 func CalculatePriority(t *block.Transaction, sumInputs uint32) uint32 {
 	if t == nil {
-		fmt.Printf("ERROR {TransactionPool.CalcPri}: The" +
-			"inputted transaction was nil.\n")
+		logger.Error("received a nil transaction", "func", "miner.CalculatePriority")
 		return 0
 	}
 	fees := sumInputs - t.SumOutputs()
@@ -85,8 +111,7 @@ func CalculatePriority(t *block.Transaction, sumInputs uint32) uint32 {
 // heap.
 func (tp *TxPool) Add(t *block.Transaction, sumInputs uint32) {
 	if t == nil {
-		fmt.Printf("ERROR {TransactionPool.Add}: The" +
-			"inputted transaction was nil.\n")
+		logger.Error("received a nil transaction", "func", "TxPool.Add")
 		return
 	}
 	if tp.Count.Load() >= tp.Capacity {
@@ -98,6 +123,9 @@ func (tp *TxPool) Add(t *block.Transaction, sumInputs uint32) {
 	tp.TxQ.Add(pri, t)
 	tp.Mutex.Unlock()
 	tp.Count.Inc()
+	if tp.observer != nil {
+		tp.observer(t, true)
+	}
 }
 
 // CheckTransactions checks for any duplicate
@@ -108,4 +136,9 @@ func (tp *TxPool) CheckTransactions(txs []*block.Transaction) {
 	tp.Mutex.Unlock()
 	tp.Count.Sub(uint32(len(amtRem)))
 	tp.CurrentPriority.Sub(totalPriority)
+	if tp.observer != nil {
+		for _, t := range amtRem {
+			tp.observer(t, false)
+		}
+	}
 }
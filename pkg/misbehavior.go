@@ -0,0 +1,72 @@
+package pkg
+
+import (
+	"Coin/pkg/peer"
+	"fmt"
+	"time"
+)
+
+// Misbehaving penalizes a peer for an offense by adding the
+// offense's weight to the peer's misbehavior score. Once the
+// score reaches the ban threshold, the peer is disconnected
+// and banned for the configured duration.
+// Inputs:
+// addr string the address of the misbehaving peer
+// o peer.Offense what the peer did
+func (n *Node) Misbehaving(addr string, o peer.Offense) {
+	p := n.PeerDb.Get(addr)
+	if p == nil {
+		return
+	}
+	c := n.Config.PeerConfig
+	score := p.Misbehaved(c.OffenseWeights[o])
+	n.log.Info("penalized peer", "peer", addr, "offense", o, "score", score)
+	if score < c.BanThreshold {
+		return
+	}
+	n.BanList.Add(addr, p.Addr.PublicKey, c.BanDuration, fmt.Sprintf("misbehavior score %v, last offense: %v", score, o))
+	n.Disconnect(addr)
+	n.log.Warn("banned peer", "peer", addr, "offense", o, "score", score, "until", time.Now().Add(c.BanDuration))
+}
+
+// Disconnect drops a peer and closes the connection to it.
+func (n *Node) Disconnect(addr string) {
+	n.PeerDb.Remove(addr)
+	n.Conns.Remove(addr)
+	n.unmarkOutbound(addr)
+}
+
+// Ban bans an address for a duration, or for the configured
+// BanDuration if d is 0, and drops it if it is a peer. The
+// public key the node at the address proved it holds, if
+// any, is banned with it.
+func (n *Node) Ban(addr string, d time.Duration, reason string) {
+	if d <= 0 {
+		d = n.Config.PeerConfig.BanDuration
+	}
+	pk := ""
+	if p := n.PeerDb.Get(addr); p != nil {
+		pk = p.Addr.PublicKey
+	} else if a := n.AddressDB.Get(addr); a != nil {
+		pk = a.PublicKey
+	}
+	n.BanList.Add(addr, pk, d, reason)
+	n.Disconnect(addr)
+	n.log.Warn("banned peer", "peer", addr, "reason", reason, "until", time.Now().Add(d))
+}
+
+// ListBans returns the nodes that are currently banned.
+func (n *Node) ListBans() []*peer.Ban {
+	return n.BanList.List()
+}
+
+// ClearBan lifts the ban on an address. It returns whether
+// the address was banned.
+func (n *Node) ClearBan(addr string) bool {
+	return n.BanList.Remove(addr)
+}
+
+// ClearBans lifts every ban.
+func (n *Node) ClearBans() {
+	n.BanList.Clear()
+}
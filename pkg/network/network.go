@@ -0,0 +1,136 @@
+package network
+
+import (
+	"Coin/pkg/blockchain"
+	"fmt"
+	"sort"
+)
+
+// Params are the parameters that define a network. Nodes
+// only peer with nodes on the same network, and every node
+// on a network must agree on its genesis and consensus
+// parameters.
+// Name is the name the network is chosen by.
+// Magic is a number that identifies the network. It is sent
+// during the handshake, and nodes with a different magic are
+// refused.
+// DefaultPort is the port nodes on the network listen on
+// unless told otherwise.
+// GenesisPublicKey is the public key that the genesis
+// transaction pays to.
+// GenesisSubsidy is the amount that the genesis
+// transaction pays.
+// GenesisTimestamp is the timestamp of the genesis block.
+// It makes the genesis blocks of the networks differ.
+// InitialSubsidy is the reward for mining a block before
+// any halvings.
+// SubsidyHalvingRate is the number of blocks after which
+// the subsidy is halved.
+// MaxHalvings is the number of halvings after which the
+// subsidy becomes 0.
+// POWDifficultyZeros is the number of leading zeros of the
+// initial proof of work difficulty target.
+// MaxBlockSize is the maximum allowed block size.
+type Params struct {
+	Name        string
+	Magic       uint32
+	DefaultPort int
+
+	GenesisPublicKey string
+	GenesisSubsidy   uint32
+	GenesisTimestamp uint32
+
+	InitialSubsidy     uint32
+	SubsidyHalvingRate uint32
+	MaxHalvings        uint32
+	POWDifficultyZeros int
+	MaxBlockSize       uint32
+}
+
+// Mainnet is the main network.
+var Mainnet = &Params{
+	Name:               "mainnet",
+	Magic:              0xc0b1a5e1,
+	DefaultPort:        7777,
+	GenesisPublicKey:   blockchain.GENPK,
+	GenesisSubsidy:     0,
+	GenesisTimestamp:   0,
+	InitialSubsidy:     50,
+	SubsidyHalvingRate: 10,
+	MaxHalvings:        10,
+	POWDifficultyZeros: 3,
+	MaxBlockSize:       10000000,
+}
+
+// Testnet is a public network for testing, with the same
+// consensus parameters as Mainnet but a separate chain.
+var Testnet = &Params{
+	Name:               "testnet",
+	Magic:              0xc0b1a5e2,
+	DefaultPort:        17777,
+	GenesisPublicKey:   blockchain.GENPK,
+	GenesisSubsidy:     0,
+	GenesisTimestamp:   1,
+	InitialSubsidy:     50,
+	SubsidyHalvingRate: 10,
+	MaxHalvings:        10,
+	POWDifficultyZeros: 3,
+	MaxBlockSize:       10000000,
+}
+
+// Regtest is a network for local testing, where blocks are
+// easy to mine.
+var Regtest = &Params{
+	Name:               "regtest",
+	Magic:              0xc0b1a5e3,
+	DefaultPort:        27777,
+	GenesisPublicKey:   blockchain.GENPK,
+	GenesisSubsidy:     0,
+	GenesisTimestamp:   2,
+	InitialSubsidy:     50,
+	SubsidyHalvingRate: 10,
+	MaxHalvings:        10,
+	POWDifficultyZeros: 1,
+	MaxBlockSize:       10000000,
+}
+
+// networks maps the name of each network to its parameters.
+var networks = map[string]*Params{
+	Mainnet.Name: Mainnet,
+	Testnet.Name: Testnet,
+	Regtest.Name: Regtest,
+}
+
+// Lookup returns the parameters of the network with a name.
+func Lookup(name string) (*Params, error) {
+	p, ok := networks[name]
+	if !ok {
+		return nil, fmt.Errorf("unknown network %q, expected one of %v", name, Names())
+	}
+	return p, nil
+}
+
+// Names returns the names of the networks, sorted.
+func Names() []string {
+	names := make([]string, 0, len(networks))
+	for name := range networks {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// GenesisHash returns the hash of the network's genesis block.
+func (p *Params) GenesisHash() string {
+	c := blockchain.DefaultConfig()
+	p.ApplyChain(c)
+	return blockchain.GenesisBlock(c).Hash()
+}
+
+// ApplyChain sets the genesis parameters of a blockchain
+// configuration to the network's.
+func (p *Params) ApplyChain(c *blockchain.Config) {
+	c.GenesisPublicKey = p.GenesisPublicKey
+	c.InitialSubsidy = p.GenesisSubsidy
+	c.GenesisTimestamp = p.GenesisTimestamp
+}
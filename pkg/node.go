@@ -6,16 +6,26 @@ import (
 	"Coin/pkg/block"
 	"Coin/pkg/blockchain"
 	"Coin/pkg/id"
+	"Coin/pkg/metrics"
 	"Coin/pkg/miner"
+	"Coin/pkg/notify"
 	"Coin/pkg/peer"
 	"Coin/pkg/pro"
 	"Coin/pkg/utils"
 	"Coin/pkg/wallet"
+	"context"
+	"crypto/tls"
+	"crypto/x509"
 	"errors"
 	"fmt"
+	"go.uber.org/atomic"
 	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/keepalive"
 	"net"
+	"net/http"
 	"os"
+	"strconv"
 	"sync"
 	"time"
 )
@@ -33,6 +43,14 @@ import (
 // on the node object.
 // *pro.UnimplementedCoinServer
 // Server *grpc.Server
+// AdminServer *grpc.Server the server of the node's Admin
+// service, or nil if it is not open
+// GatewayServer *http.Server the server of the node's HTTP
+// gateway, or nil if it is not open
+// MetricsServer *http.Server the server of the node's
+// metrics, or nil if it is not open
+// ExplorerServer *http.Server the server of the node's block
+// explorer, or nil if it is not open
 // Config *Config the settings for the node
 // Address string the address that the node is listening
 // to traffic on
@@ -45,16 +63,53 @@ import (
 // of nodes that it knows about in the network
 // PeerDb   peer.PeerDb a database of peers the node
 // is currently connected to
-// SeenTransactions    map[string]bool a map used to keep track
+// BanList *peer.BanList the nodes that are banned from
+// peering with this node
+// Conns *address.ConnectionManager the pool of connections
+// to other nodes, shared by every RPC the node makes
+// certificate *tls.Certificate the TLS certificate made from
+// the node's id, or nil if the transport is not secured
+// genesisHash string the hash of the genesis block of the
+// node's network
+// ctx context.Context canceled when the node shuts down,
+// which stops the node's background goroutines
+// cancel context.CancelFunc cancels ctx
+// wg sync.WaitGroup the node's running background goroutines
+// stopping bool whether the node started shutting down, after
+// which no new background goroutines are started
+// done chan struct{} closed once the node has shut down
+// outbound map[string]bool the addresses the node connected
+// to, as opposed to the ones that connected to it
+// callerLimits map[string]*peer.RateLimiter the rate limits on
+// callers that are not peers, by the identity of their
+// connection
+// callerOrder []string the keys of callerLimits, from the
+// caller that was limited first
+// SeenTransactions    *utils.SeenCache used to keep track
 // of whether a transaction has been seen on the network
-// before or not
-// SeenBlocks a map used to keep track
+// recently or not
+// SeenBlocks *utils.SeenCache used to keep track
 // of whether a block has been seen on the network
-// before or not
-// Paused bool
+// recently or not
+// Metrics *metrics.Registry the node's metrics
+// Events *notify.Hub where the node publishes new tips, reorgs,
+// mempool changes and coins moving, for subscribers to follow
+// stats *nodeStats the metrics that the node updates itself
+// log *utils.Logger the node's peer-to-peer logger, which tags
+// entries with Address once the node has started
+// Paused *atomic.Bool whether the node is paused, during
+// which it does not relay, mine or broadcast transactions
+// wasMining bool whether the miner was mining when the node
+// paused, so that resuming restarts it
+// heldTransactions []*block.Transaction the transactions the
+// wallet asked to broadcast while the node was paused
 type Node struct {
 	*pro.UnimplementedCoinServer
-	Server *grpc.Server
+	Server         *grpc.Server
+	AdminServer    *grpc.Server
+	GatewayServer  *http.Server
+	MetricsServer  *http.Server
+	ExplorerServer *http.Server
 
 	Config  *Config
 	Address string
@@ -64,15 +119,45 @@ type Node struct {
 	Wallet     *wallet.Wallet
 	Miner      *miner.Miner
 
-	SeenTransactions map[string]bool
-	SeenBlocks       map[string]bool
+	SeenTransactions *utils.SeenCache
+	SeenBlocks       *utils.SeenCache
+
+	Metrics *metrics.Registry
+	Events  *notify.Hub
+	stats   *nodeStats
+	log     *utils.Logger
 
 	fGetAddr bool // starts false, set to true when we request addresses from a node, cleared when we receive less than 1000 addresses from a node
 
 	AddressDB addressdb.AddressDb
 	PeerDb    peer.PeerDb
+	BanList   *peer.BanList
+	Conns     *address.ConnectionManager
+
+	certificate *tls.Certificate
+	genesisHash string
+
+	ctx          context.Context
+	cancel       context.CancelFunc
+	wg           sync.WaitGroup
+	stopping     bool
+	done         chan struct{}
+	stopOnce     sync.Once
+	runningMutex sync.Mutex
+
+	outbound      map[string]bool
+	outboundMutex sync.Mutex
+	admitMutex    sync.Mutex
 
-	Paused bool
+	callerLimits map[string]*peer.RateLimiter
+	callerOrder  []string
+	callerMutex  sync.Mutex
+
+	Paused           *atomic.Bool
+	wasMining        bool
+	heldTransactions []*block.Transaction
+	heldMutex        sync.Mutex
+	pauseMutex       sync.Mutex
 }
 
 // New returns a new Node object based on
@@ -83,7 +168,9 @@ type Node struct {
 // Returns:
 // *Node a pointer to the new node object
 func New(conf *Config) *Node {
-	n := &Node{Config: conf}
+	n := &Node{Config: conf, done: make(chan struct{}), outbound: make(map[string]bool), callerLimits: make(map[string]*peer.RateLimiter), Paused: atomic.NewBool(false)}
+	n.log = utils.NewLogger(utils.ComponentP2P)
+	n.ctx, n.cancel = context.WithCancel(context.Background())
 	if conf.HasCustomId {
 		n.Id = conf.CustomID
 	} else {
@@ -92,13 +179,66 @@ func New(conf *Config) *Node {
 	n.BlockChain = blockchain.New(n.Config.ChainConfig)
 	n.Wallet = wallet.New(n.Config.WalletConfig, n.Id)
 	n.Miner = miner.New(n.Config.MinerConfig, n.Id)
-	n.SeenTransactions = make(map[string]bool)
-	n.SeenBlocks = make(map[string]bool)
-	n.AddressDB = addressdb.New(true, 1000)
-	n.PeerDb = peer.NewDb(true, 200, "")
+	n.genesisHash = n.BlockChain.LastHash
+	n.Events = notify.NewHub(conf.NotifyConfig)
+	n.BlockChain.ObserveTip(n.publishTip)
+	if n.Miner != nil {
+		n.Miner.PreviousHash = n.genesisHash
+		n.Miner.TxPool.Observe(n.publishMempool)
+	}
+	n.SeenTransactions = utils.NewSeenCache(conf.SeenCacheSize, conf.SeenCacheTTL)
+	n.SeenBlocks = utils.NewSeenCache(conf.SeenCacheSize, conf.SeenCacheTTL)
+	n.PeerDb = peer.NewDb(true, conf.PeerLimit, "")
+	n.BanList = peer.NewBanList(conf.PeerConfig.BanListPath)
+	if conf.AddressConfig.SecureTransport {
+		cert, err := id.Certificate(n.Id)
+		if err != nil {
+			panic(err)
+		}
+		n.certificate = &cert
+	}
+	n.Conns = address.NewConnectionManager(n.Config.AddressConfig, n.certificate)
+	n.Metrics = metrics.NewRegistry()
+	n.stats = n.registerMetrics()
+	n.Conns.ObserveRPCs(n.observeRPC)
+	n.AddressDB = addressdb.New(conf.AddressDBPath == "", conf.AddressLimit, conf.AddressDBPath)
+	// addresses loaded from disk keep their pins
+	for _, a := range n.AddressDB.List() {
+		if a.PublicKey != "" {
+			n.Conns.Pin(a.Addr, a.PublicKey)
+		}
+	}
+	n.Conns.ObservePins(n.rememberKey)
 	return n
 }
 
+// rememberKey records in the address database the public key
+// that connecting to addr proved the node there holds, so that
+// addr stays pinned to it after a restart. Addresses that are
+// not in the database yet are recorded once they are added.
+func (n *Node) rememberKey(addr string, pk string) {
+	if a := n.AddressDB.Get(addr); a == nil || a.PublicKey != "" {
+		return
+	}
+	if err := n.AddressDB.SetPublicKey(addr, pk); err != nil {
+		n.log.Warn("unable to record public key", "peer", addr, "err", err)
+	}
+}
+
+// logger returns a logger for a component of the node, which
+// tags entries with the node's address.
+func (n *Node) logger(component string) *utils.Logger {
+	return utils.NewLogger(component).With("node", n.Address)
+}
+
+// newAddress returns an address whose RPCs go through
+// the node's connection manager.
+func (n *Node) newAddress(addr string, lastSeen uint32) *address.Address {
+	a := address.New(addr, lastSeen)
+	a.Conns = n.Conns
+	return a
+}
+
 // BroadcastTransaction broadcasts transactions created by the wallet
 // to other peers in the network.
 func (n *Node) BroadcastTransaction(tx *block.Transaction) {
@@ -110,16 +250,31 @@ func (n *Node) BroadcastTransaction(tx *block.Transaction) {
 // one to connect to. So, this method opens up a listener and
 // creates a gRPC server that it can be used to make and listen to
 // requests on the network. It also starts another go routine
-// for listening to messages from the wallet, miner, and blockchain
-func (n *Node) Start() {
-	hostname, err := os.Hostname()
-	if err != nil {
-		panic(err)
-	}
-	addr := fmt.Sprintf("%v:%v", hostname, n.Config.Port)
+// for listening to messages from the wallet, miner, and blockchain.
+// The node runs until Kill is called.
+// Returns:
+// error if the node could not listen on one of its
+// addresses. The node should then be killed, to close
+// whatever it did open.
+func (n *Node) Start() error {
+	return n.StartContext(context.Background())
+}
+
+// StartContext starts the node like Start, but also shuts the
+// node down once ctx is canceled.
+// Inputs:
+// ctx context.Context the context that the node runs in
+// Returns:
+// error if the node could not listen on one of its
+// addresses, like Start
+func (n *Node) StartContext(ctx context.Context) error {
+	addr := n.advertisedAddr()
 	n.Address = addr
 	n.PeerDb.SetAddr(addr)
-	utils.Debug.Printf("%v started", utils.FmtAddr(n.Address))
+	n.Conns.SetLocalAddr(addr)
+	n.log = n.logger(utils.ComponentP2P)
+	n.logger(utils.ComponentNode).Info("started", "network", n.Config.Network.Name,
+		"public_key", id.PublicKeyHex(n.Id.GetPublicKey()))
 	if n.Config.MinerConfig.HasMiner {
 		n.Miner.SetAddress(addr)
 	}
@@ -129,31 +284,79 @@ func (n *Node) Start() {
 	if n.Config.WalletConfig.HasWallet {
 		n.Wallet.SetAddress(addr)
 	}
-	n.StartServer(addr)
+	if !n.Config.NoListen {
+		if err := n.StartServer(n.listenAddr()); err != nil {
+			return fmt.Errorf("unable to listen: %w", err)
+		}
+	}
+	if n.Config.AdminAddr != "" {
+		if err := n.StartAdminServer(n.Config.AdminAddr); err != nil {
+			return fmt.Errorf("unable to serve admin server: %w", err)
+		}
+	}
+	if n.Config.GatewayAddr != "" {
+		if err := n.StartGatewayServer(n.Config.GatewayAddr); err != nil {
+			return fmt.Errorf("unable to serve gateway server: %w", err)
+		}
+	}
+	if n.Config.MetricsAddr != "" {
+		if err := n.StartMetricsServer(n.Config.MetricsAddr); err != nil {
+			return fmt.Errorf("unable to serve metrics server: %w", err)
+		}
+	}
+	if n.Config.ExplorerAddr != "" {
+		if err := n.StartExplorerServer(n.Config.ExplorerAddr); err != nil {
+			return fmt.Errorf("unable to serve explorer server: %w", err)
+		}
+	}
+	n.goroutine(n.maintainConnections)
+	n.goroutine(n.handleEvents)
 	go func() {
-		if n.Config.MinerConfig.HasMiner {
-			for {
-				select {
-				case t := <-n.Wallet.TransactionRequests:
-					n.BroadcastTransaction(t)
-				case b := <-n.Miner.SendBlock:
-					n.HandleMinerBlock(b)
-				case b := <-n.BlockChain.ConfirmBlock:
-					n.Wallet.HandleBlock(b.Transactions)
-				case txs := <-n.Miner.GetInputSums:
-					sums := n.BlockChain.GetInputSums(txs)
-					n.Miner.InputSums <- sums
-				}
-			}
-		} else {
-			for {
-				select {
-				case t := <-n.Wallet.TransactionRequests:
-					n.BroadcastTransaction(t)
-				}
-			}
+		select {
+		case <-ctx.Done():
+			n.Kill()
+		case <-n.ctx.Done():
 		}
 	}()
+	return nil
+}
+
+// handleEvents passes messages between the wallet, miner,
+// and blockchain until the node shuts down. The channels of
+// a missing wallet or miner are left nil, so that they are
+// never selected.
+func (n *Node) handleEvents() {
+	var txRequests chan *block.Transaction
+	var confirmedBlocks chan *block.Block
+	if n.Config.WalletConfig.HasWallet {
+		txRequests = n.Wallet.TransactionRequests
+		confirmedBlocks = n.BlockChain.ConfirmBlock
+	}
+	var minedBlocks chan *block.Block
+	var inputSumRequests chan []*block.Transaction
+	if n.Config.MinerConfig.HasMiner {
+		minedBlocks = n.Miner.SendBlock
+		inputSumRequests = n.Miner.GetInputSums
+	}
+	for {
+		select {
+		case <-n.ctx.Done():
+			return
+		case t := <-txRequests:
+			n.broadcastOrHold(t)
+		case b := <-minedBlocks:
+			n.HandleMinerBlock(b)
+		case b := <-confirmedBlocks:
+			n.Wallet.HandleBlock(b.Transactions)
+		case txs := <-inputSumRequests:
+			sums := n.BlockChain.GetInputSums(txs)
+			select {
+			case n.Miner.InputSums <- sums:
+			case <-n.ctx.Done():
+				return
+			}
+		}
+	}
 }
 
 // HandleMinerBlock handles a block
@@ -179,40 +382,165 @@ func (n *Node) GetBalance(pk string) uint32 {
 
 // StartMiner starts the miner, which means the miner
 // is now actively waiting for enough transactions
-// to mine.
+// to mine. If the node is paused, the miner starts
+// once the node resumes. A node without a miner is left
+// as it is.
 func (n *Node) StartMiner() {
+	if !n.Config.MinerConfig.HasMiner {
+		return
+	}
+	n.pauseMutex.Lock()
+	defer n.pauseMutex.Unlock()
+	if n.Paused.Load() {
+		n.wasMining = true
+		return
+	}
 	n.Miner.StartMiner()
 }
 
+// StopMiner stops the miner until StartMiner is called
+// again, even if the node is paused and resumed. A node
+// without a miner is left as it is.
+func (n *Node) StopMiner() {
+	if !n.Config.MinerConfig.HasMiner {
+		return
+	}
+	n.pauseMutex.Lock()
+	defer n.pauseMutex.Unlock()
+	n.wasMining = false
+	n.Miner.Pause()
+}
+
 // ConnectToPeer connects to a certain peer in the network. This just
 // serves as an interface for the real functionality contained
 // within the Router.
 // Inputs:
 // addr string the address of the node that you want
 // to connect to.
-func (n *Node) ConnectToPeer(addr string) {
-	a := address.New(addr, 0)
-	_, err := a.VersionRPC(&pro.VersionRequest{
-		Version:    uint32(n.Config.Version),
-		AddrYou:    addr,
-		AddrMe:     n.Address,
-		BestHeight: n.BlockChain.Length,
-	})
+// Returns:
+// bool whether the node is now a peer
+func (n *Node) ConnectToPeer(addr string) bool {
+	n.markOutbound(addr)
+	a := n.newAddress(addr, 0)
+	ack, err := a.VersionRPC(n.versionRequest(addr))
 	if err != nil {
-		utils.Debug.Printf("%v received no response from VersionRPC to %v",
-			utils.FmtAddr(n.Address), utils.FmtAddr(addr))
+		n.log.Debug("no response to version", "peer", addr, "err", err)
+	} else if !ack.Accepted {
+		n.log.Info("not accepted as a peer", "peer", addr, "reason", ack.Reason)
 	}
+	// addresses that we dial are remembered whether or not they
+	// answer, so that the attempt counts towards whether they are
+	// worth dialing again
+	if n.AddressDB.Get(addr) == nil {
+		if aerr := n.AddressDB.Add(n.newAddress(addr, 0)); aerr != nil {
+			n.log.Debug("unable to remember address", "peer", addr, "err", aerr)
+		}
+	}
+	if aerr := n.AddressDB.RecordAttempt(addr, err == nil); aerr != nil {
+		n.log.Warn("unable to record connection attempt", "peer", addr, "err", aerr)
+	}
+	if pk := n.Conns.PinnedKey(addr); pk != "" {
+		n.rememberKey(addr, pk)
+	}
+	// nodes that do not listen are never sent a version back,
+	// so they take the verack as the start of the peering
+	if n.Config.NoListen && err == nil && ack.Accepted && n.PeerDb.Get(addr) == nil {
+		n.addAckedPeer(a, ack)
+	}
+	if n.PeerDb.Get(addr) == nil {
+		n.unmarkOutbound(addr)
+		return false
+	}
+	return true
+}
+
+// addAckedPeer adds the node at a as a peer from the verack
+// it replied to our version with.
+func (n *Node) addAckedPeer(a *address.Address, ack *pro.VersionAck) {
+	if known := n.AddressDB.Get(a.Addr); known != nil {
+		a = known
+		a.Conns = n.Conns
+	} else {
+		_ = n.AddressDB.Add(a)
+	}
+	if pk := n.Conns.PinnedKey(a.Addr); pk != "" {
+		a.PublicKey = pk
+		n.rememberKey(a.Addr, pk)
+	}
+	p := peer.New(a, ack.Version, ack.BestHeight)
+	p.Services = peer.Services(ack.Services)
+	p.UserAgent = ack.UserAgent
+	p.SetBest(ack.BestHeight, ack.BestHash)
+	p.Limiter = peer.NewRateLimiter(n.Config.PeerConfig.RateLimits)
+	if n.certificate == nil {
+		p.IPs = resolveHost(a.Addr)
+	}
+	if n.PeerDb.Add(p) {
+		n.goroutine(func() { n.pingLoop(p) })
+	}
+}
+
+// versionRequest returns the version request that the node
+// sends to the node at addrYou to become its peer.
+func (n *Node) versionRequest(addrYou string) *pro.VersionRequest {
+	return &pro.VersionRequest{
+		Version:     uint32(n.Config.Version),
+		AddrYou:     addrYou,
+		AddrMe:      n.Address,
+		BestHeight:  n.BlockChain.Length,
+		PublicKey:   id.PublicKeyHex(n.Id.GetPublicKey()),
+		Services:    uint64(n.Services()),
+		UserAgent:   n.Config.UserAgent,
+		MinVersion:  uint32(n.Config.MinVersion),
+		BestHash:    n.BlockChain.LastHash,
+		Magic:       n.Config.Network.Magic,
+		GenesisHash: n.genesisHash,
+		NoListen:    n.Config.NoListen,
+	}
+}
+
+// Services returns the service flags that the node
+// advertises to its peers.
+func (n *Node) Services() peer.Services {
+	var s peer.Services
+	if n.Config.ChainConfig.HasChain {
+		s |= peer.ServiceFullChain
+	}
+	if n.Config.MinerConfig != nil && n.Config.MinerConfig.HasMiner {
+		s |= peer.ServiceMiner
+	}
+	return s
+}
+
+// negotiateVersion returns the highest protocol version that
+// both the node and a node speaking versions min through max
+// speak. It returns false if there is no such version.
+func (n *Node) negotiateVersion(min uint32, max uint32) (uint32, bool) {
+	version := uint32(n.Config.Version)
+	if max < version {
+		version = max
+	}
+	if version < min || version < uint32(n.Config.MinVersion) {
+		return 0, false
+	}
+	return version, true
 }
 
 // BroadcastAddress broadcasts the node's address
 func (n *Node) BroadcastAddress() {
-	myAddr := pro.Address{Addr: n.Address, LastSeen: uint32(time.Now().UnixNano())}
-	for _, p := range n.PeerDb.List() {
+	if n.Paused.Load() {
+		return
+	}
+	// nodes that do not listen cannot be reached, so there is nothing to share
+	if n.Config.NoListen {
+		return
+	}
+	myAddr := pro.Address{Addr: n.Address, LastSeen: uint32(time.Now().Unix())}
+	for _, p := range n.reachablePeers() {
 		go func(addr *address.Address) {
 			_, err := addr.SendAddressesRPC(&pro.Addresses{Addrs: []*pro.Address{&myAddr}})
 			if err != nil {
-				utils.Debug.Printf("%v received no response from SendAddressesRPC to %v",
-					utils.FmtAddr(n.Address), utils.FmtAddr(p.Addr.Addr))
+				n.log.Debug("no response to addresses", "peer", addr.Addr, "err", err)
 			}
 		}(p.Addr)
 	}
@@ -223,15 +551,24 @@ func (n *Node) BroadcastAddress() {
 // when a node first joins the network, or if the node left
 // the network for a while (paused), then rejoined.
 func (n *Node) Bootstrap() error {
-	utils.Debug.Printf("%v bootstrapping from %v peers with top block %v", utils.FmtAddr(n.Address), len(n.PeerDb.List()), n.BlockChain.LastBlock.NameTag())
+	n.log.Info("bootstrapping", "peers", len(n.PeerDb.List()), "height", n.BlockChain.Length, "tip", n.BlockChain.LastHash)
 	topBlockHash := n.BlockChain.LastHash
 	var wg sync.WaitGroup
+	// guards longestRes and addr, which every peer's
+	// response is compared against
+	var mutex sync.Mutex
 	var longestRes *pro.GetBlocksResponse
 	var addr *address.Address
-	if len(n.PeerDb.List()) == 0 {
+	peers := make([]*peer.Peer, 0)
+	for _, p := range n.reachablePeers() {
+		if p.Services.Has(peer.ServiceFullChain) {
+			peers = append(peers, p)
+		}
+	}
+	if len(peers) == 0 {
 		return errors.New("no peers to bootstrap from")
 	}
-	for _, p := range n.PeerDb.List() {
+	for _, p := range peers {
 		wg.Add(1)
 		go func(p *peer.Peer) {
 			res, err := p.Addr.GetBlocksRPC(&pro.GetBlocksRequest{TopBlockHash: topBlockHash})
@@ -239,10 +576,12 @@ func (n *Node) Bootstrap() error {
 				wg.Done()
 				return
 			}
+			mutex.Lock()
 			if longestRes == nil || len(res.BlockHashes) > len(longestRes.BlockHashes) {
 				longestRes = res
 				addr = p.Addr
 			}
+			mutex.Unlock()
 			wg.Done()
 		}(p)
 	}
@@ -251,48 +590,101 @@ func (n *Node) Bootstrap() error {
 		return errors.New("no peers gave responses")
 	}
 	for _, h := range longestRes.BlockHashes {
-		pb, _ := addr.GetDataRPC(&pro.GetDataRequest{BlockHash: h})
+		pb, err := addr.GetDataRPC(&pro.GetDataRequest{BlockHash: h})
+		if err != nil {
+			return err
+		}
+		if pb.Block == nil {
+			return fmt.Errorf("peer %v did not send block %v", addr.Addr, h)
+		}
 		b := block.DecodeBlock(pb.Block)
-		n.SeenBlocks[b.Hash()] = true
+		if b.Hash() != h {
+			n.Misbehaving(addr.Addr, peer.UnrequestedData)
+			return fmt.Errorf("peer %v sent block %v instead of %v", addr.Addr, b.Hash(), h)
+		}
+		n.SeenBlocks.Add(b.Hash())
 		n.BlockChain.HandleBlock(b)
 	}
 	return nil
 }
 
-func (n *Node) StartServer(addr string) {
-	lis, err := net.Listen("tcp4", addr)
+// listenAddr returns the address that the node accepts
+// connections on.
+func (n *Node) listenAddr() string {
+	if n.Config.ListenAddr != "" {
+		return n.Config.ListenAddr
+	}
+	return net.JoinHostPort(hostname(), strconv.Itoa(n.Config.Port))
+}
+
+// advertisedAddr returns the address that the node tells
+// other nodes to reach it at.
+func (n *Node) advertisedAddr() string {
+	if n.Config.AdvertiseAddr != "" {
+		return n.Config.AdvertiseAddr
+	}
+	if host, port, err := net.SplitHostPort(n.Config.ListenAddr); err == nil {
+		if host == "" || net.ParseIP(host).IsUnspecified() {
+			return net.JoinHostPort(hostname(), port)
+		}
+		return n.Config.ListenAddr
+	}
+	return net.JoinHostPort(hostname(), strconv.Itoa(n.Config.Port))
+}
+
+// hostname returns the name of the machine the node runs on.
+func hostname() string {
+	h, err := os.Hostname()
 	if err != nil {
 		panic(err)
 	}
+	return h
+}
+
+// StartServer opens the node to connections on addr, which
+// may be an IPv4 or IPv6 address or a host name.
+// Returns:
+// error if the node could not listen on addr
+func (n *Node) StartServer(addr string) error {
+	lis, err := net.Listen("tcp", addr)
+	if err != nil {
+		return err
+	}
 	// Open node to connections
-	n.Server = grpc.NewServer()
+	n.Server = grpc.NewServer(n.serverOptions()...)
 	pro.RegisterCoinServer(n.Server, n)
 	go func() {
-		err = n.Server.Serve(lis)
-		if err != nil {
-			fmt.Printf("ERROR {Node.StartServer}: error" +
-				"when trying to serve server")
+		if err := n.Server.Serve(lis); err != nil {
+			n.log.Error("unable to serve", "addr", addr, "err", err)
 		}
 	}()
+	return nil
 }
 
-func (n *Node) PauseNetwork() {
-	n.Server.Stop()
-	utils.Debug.Printf("%v paused", utils.FmtAddr(n.Address))
-}
-
-func (n *Node) ResumeNetwork() {
-	hostname, err := os.Hostname()
-	if err != nil {
-		panic(err)
+// serverOptions returns the options for the node's gRPC
+// server. Messages are limited in size and requests from
+// peers are rate limited. If the transport is secured, every client must
+// present a certificate that is self-signed by its id.
+func (n *Node) serverOptions() []grpc.ServerOption {
+	opts := []grpc.ServerOption{
+		grpc.KeepaliveEnforcementPolicy(keepalive.EnforcementPolicy{
+			MinTime:             n.Config.AddressConfig.HealthCheckInterval / 2,
+			PermitWithoutStream: true,
+		}),
+		grpc.MaxRecvMsgSize(n.Config.AddressConfig.MaxMessageSize),
+		grpc.MaxSendMsgSize(n.Config.AddressConfig.MaxMessageSize),
+		grpc.UnaryInterceptor(n.serverUnaryInterceptor),
 	}
-	addr := fmt.Sprintf("%v:%v", hostname, n.Config.Port)
-	n.StartServer(addr)
-	utils.Debug.Printf("%v resumed", utils.FmtAddr(n.Address))
-}
-
-// Kill kills any threads currently managed by the Node or that
-// it previously started. It also does any necessary clean up.
-func (n *Node) Kill() {
-	n.Server.GracefulStop()
+	if n.certificate != nil {
+		opts = append(opts, grpc.Creds(credentials.NewTLS(&tls.Config{
+			Certificates: []tls.Certificate{*n.certificate},
+			ClientAuth:   tls.RequireAnyClientCert,
+			VerifyPeerCertificate: func(rawCerts [][]byte, _ [][]*x509.Certificate) error {
+				_, err := id.VerifyCertificate(rawCerts)
+				return err
+			},
+			MinVersion: tls.VersionTLS13,
+		})))
+	}
+	return opts
 }
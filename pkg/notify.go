@@ -0,0 +1,122 @@
+package pkg
+
+import (
+	"Coin/pkg/block"
+	"Coin/pkg/blockchain"
+	"Coin/pkg/blockchain/chainwriter"
+	"Coin/pkg/notify"
+	"Coin/pkg/script"
+	"encoding/hex"
+)
+
+// publishTip publishes a change of the chain's last block: a
+// Tip, or a Reorg, followed by the Receive and Spend events of
+// the blocks that left the main chain and then of the ones that
+// joined it.
+func (n *Node) publishTip(c *blockchain.TipChange) {
+	var events []*notify.Event
+	if len(c.Disconnected) == 0 {
+		events = append(events, &notify.Event{Type: notify.Tip, BlockHash: c.Hash, Height: c.Height})
+	} else {
+		reorg := &notify.Event{Type: notify.Reorg, BlockHash: c.Hash, Height: c.Height}
+		for _, b := range c.Disconnected {
+			reorg.Disconnected = append(reorg.Disconnected, b.Hash())
+		}
+		for _, b := range c.Connected {
+			reorg.Connected = append(reorg.Connected, b.Hash())
+		}
+		events = append(events, reorg)
+		// the old branch ended as far above the common ancestor
+		// as it was long
+		oldHeight := c.Height - uint32(len(c.Connected)) + uint32(len(c.Disconnected))
+		for i, b := range c.Disconnected {
+			events = append(events, coinEvents(b, c.DisconnectedUndo[i], oldHeight-uint32(i), true)...)
+		}
+	}
+	for i, b := range c.Connected {
+		height := c.Height - uint32(len(c.Connected)-1-i)
+		events = append(events, coinEvents(b, c.ConnectedUndo[i], height, false)...)
+	}
+	n.Events.Publish(events...)
+}
+
+// coinEvents returns the Spend and Receive events of a block at
+// a height. The coins that the block spent are read from its
+// UndoBlock, so if that is empty, only its Receive events are
+// returned.
+func coinEvents(b *block.Block, ub *chainwriter.UndoBlock, height uint32, reverted bool) []*notify.Event {
+	var events []*notify.Event
+	hash := b.Hash()
+	spent := 0
+	for _, tx := range b.Transactions {
+		txHash := tx.Hash()
+		for range tx.Inputs {
+			if ub == nil || spent >= len(ub.LockingScripts) {
+				break
+			}
+			if ub.LockingScripts[spent] != "" {
+				e := &notify.Event{
+					Type:            notify.Spend,
+					BlockHash:       hash,
+					Height:          height,
+					TransactionHash: txHash,
+					Coin: &notify.Coin{
+						TransactionHash: ub.TransactionInputHashes[spent],
+						OutputIndex:     ub.OutputIndexes[spent],
+						Amount:          ub.Amounts[spent],
+					},
+					Reverted: reverted,
+				}
+				concerns(e, ub.LockingScripts[spent])
+				events = append(events, e)
+			}
+			spent++
+		}
+		for i, txo := range tx.Outputs {
+			if txo.LockingScript == "" {
+				continue
+			}
+			e := &notify.Event{
+				Type:            notify.Receive,
+				BlockHash:       hash,
+				Height:          height,
+				TransactionHash: txHash,
+				Coin:            &notify.Coin{TransactionHash: txHash, OutputIndex: uint32(i), Amount: txo.Amount},
+				Reverted:        reverted,
+			}
+			concerns(e, txo.LockingScript)
+			events = append(events, e)
+		}
+	}
+	return events
+}
+
+// publishMempool publishes a transaction entering or leaving
+// the mempool. Transactions only leave it when they are mined.
+func (n *Node) publishMempool(t *block.Transaction, added bool) {
+	e := &notify.Event{Type: notify.MempoolAdd, TransactionHash: t.Hash()}
+	if !added {
+		e.Type = notify.MempoolRemove
+		e.Reason = notify.ReasonMined
+	}
+	seen := make(map[string]bool)
+	for _, txo := range t.Outputs {
+		if txo.LockingScript != "" && !seen[txo.LockingScript] {
+			seen[txo.LockingScript] = true
+			concerns(e, txo.LockingScript)
+		}
+	}
+	n.Events.Publish(e)
+}
+
+// concerns adds a coin's locking script to the public keys or
+// scripts that an event concerns. Bare keys are added as hex,
+// like subscribers filter by them, and scripts as they are, so
+// that filters can tell whom they pay.
+func concerns(e *notify.Event, lockingScript string) {
+	if script.IsScript(lockingScript) {
+		e.Scripts = append(e.Scripts, lockingScript)
+		return
+	}
+	e.PublicKeys = append(e.PublicKeys, hex.EncodeToString([]byte(lockingScript)))
+}
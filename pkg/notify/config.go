@@ -0,0 +1,21 @@
+package notify
+
+// Config is the configuration for a node's notifications.
+// BufferSize is how many events a subscriber may fall behind
+// by. A subscriber that falls further behind is dropped, so
+// that a slow client cannot hold up the node.
+// MaxSubscribers is the most subscribers that the node
+// serves at once.
+type Config struct {
+	BufferSize     int
+	MaxSubscribers int
+}
+
+// DefaultConfig returns the default settings for
+// notifications.
+func DefaultConfig() *Config {
+	return &Config{
+		BufferSize:     256,
+		MaxSubscribers: 100,
+	}
+}
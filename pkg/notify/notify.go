@@ -0,0 +1,270 @@
+// Package notify pushes what happens on a node, such as new
+// tips, reorgs, mempool changes and coins moving to and from
+// public keys, to subscribers as it happens, so that they do
+// not have to poll for it.
+package notify
+
+import (
+	"Coin/pkg/script"
+	"errors"
+	"sync"
+	"time"
+)
+
+// Type is the kind of an Event.
+type Type string
+
+const (
+	// Tip is a block that became the last block of the main
+	// chain by extending it.
+	Tip Type = "tip"
+	// Reorg is a fork that replaced blocks of the main chain.
+	Reorg Type = "reorg"
+	// MempoolAdd is a transaction that was admitted to the
+	// mempool.
+	MempoolAdd Type = "mempool_add"
+	// MempoolRemove is a transaction that left the mempool.
+	MempoolRemove Type = "mempool_remove"
+	// Receive is a coin that a block on the main chain paid
+	// to a public key.
+	Receive Type = "receive"
+	// Spend is a coin of a public key that a block on the
+	// main chain spent.
+	Spend Type = "spend"
+)
+
+// ReasonMined is the Reason of a MempoolRemove event whose
+// transaction was put on the main chain.
+const ReasonMined = "mined"
+
+// Types are every Type, in the order they are documented in.
+var Types = []Type{Tip, Reorg, MempoolAdd, MempoolRemove, Receive, Spend}
+
+// ValidType returns whether t is one of Types.
+func ValidType(t Type) bool {
+	for _, valid := range Types {
+		if t == valid {
+			return true
+		}
+	}
+	return false
+}
+
+// Coin is a coin that an Event is about.
+// TransactionHash and OutputIndex locate the coin.
+// Amount is what the coin is worth.
+type Coin struct {
+	TransactionHash string `json:"transaction_hash"`
+	OutputIndex     uint32 `json:"output_index"`
+	Amount          uint32 `json:"amount"`
+}
+
+// Event is something that happened on a node. Its fields are
+// set according to its Type, and it is sent to subscribers as
+// JSON.
+// Seq numbers the events of a Hub in the order they were
+// published, starting at 1.
+// Time is when the event was published, in Unix milliseconds.
+// BlockHash and Height are the block that a Tip, Reorg,
+// Receive or Spend event is about. For a Reorg, it is the new
+// last block of the main chain.
+// Disconnected are the blocks that a Reorg took off the main
+// chain, newest first, and Connected are the ones that it put
+// on it, oldest first.
+// TransactionHash is the transaction that a mempool event is
+// about, or that made a Receive or spent a Spend.
+// Reason is why a MempoolRemove transaction left the mempool.
+// PublicKeys are the public keys, hex encoded, that the event
+// concerns: the one that a Receive or Spend is for, or the
+// ones that a mempool transaction pays, if they are locked to
+// the bare keys.
+// Scripts are the locking scripts that the event concerns, for
+// coins that are locked by scripts rather than bare keys.
+// Coin is the coin that a Receive or Spend is about.
+// Reverted is whether a Receive or Spend was undone by a
+// Reorg, because its block left the main chain.
+type Event struct {
+	Seq  uint64 `json:"seq"`
+	Type Type   `json:"type"`
+	Time int64  `json:"time"`
+
+	BlockHash    string   `json:"block_hash,omitempty"`
+	Height       uint32   `json:"height,omitempty"`
+	Disconnected []string `json:"disconnected,omitempty"`
+	Connected    []string `json:"connected,omitempty"`
+
+	TransactionHash string `json:"transaction_hash,omitempty"`
+	Reason          string `json:"reason,omitempty"`
+
+	PublicKeys []string `json:"public_keys,omitempty"`
+	Scripts    []string `json:"scripts,omitempty"`
+	Coin       *Coin    `json:"coin,omitempty"`
+	Reverted   bool     `json:"reverted,omitempty"`
+}
+
+// Filter picks the events that a subscriber gets.
+// Types are the types of events to get. If it is empty, every
+// type is sent.
+// PublicKeys are hex encoded public keys. If it is not empty,
+// events that concern public keys are only sent if they
+// concern one of these, or one of their Scripts pays to one of
+// these. Tip and Reorg events concern no public keys, so they
+// are sent regardless.
+type Filter struct {
+	Types      map[Type]bool
+	PublicKeys map[string]bool
+}
+
+// Match returns whether an event passes the filter. A nil
+// filter passes every event.
+func (f *Filter) Match(e *Event) bool {
+	if f == nil {
+		return true
+	}
+	if len(f.Types) > 0 && !f.Types[e.Type] {
+		return false
+	}
+	if len(f.PublicKeys) == 0 || (len(e.PublicKeys) == 0 && len(e.Scripts) == 0) {
+		return true
+	}
+	for _, pk := range e.PublicKeys {
+		if f.PublicKeys[pk] {
+			return true
+		}
+	}
+	for _, s := range e.Scripts {
+		for pk := range f.PublicKeys {
+			if script.PaysTo(s, pk) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// ErrTooManySubscribers is returned by Hub.Subscribe when the
+// hub already has as many subscribers as it may.
+var ErrTooManySubscribers = errors.New("too many subscribers")
+
+// ErrClosed is returned by Hub.Subscribe once the hub is
+// closed.
+var ErrClosed = errors.New("notifications are closed")
+
+// Hub sends the events published on it to its subscribers.
+// Publishing never blocks: a subscriber whose buffer is full
+// is dropped instead.
+// config is the hub's settings.
+// subs are the current subscriptions.
+// seq is the Seq of the last event published.
+// closed is whether the hub was closed.
+type Hub struct {
+	config *Config
+	subs   map[*Subscription]bool
+	seq    uint64
+	closed bool
+	mutex  sync.Mutex
+}
+
+// NewHub returns a Hub with no subscribers.
+func NewHub(c *Config) *Hub {
+	return &Hub{config: c, subs: make(map[*Subscription]bool)}
+}
+
+// Subscription is a subscriber of a Hub.
+// events is where the subscriber's events are sent. It is
+// closed when the subscription ends.
+// lagged is whether the subscription ended because the
+// subscriber fell behind.
+type Subscription struct {
+	hub    *Hub
+	filter *Filter
+	events chan *Event
+	lagged bool
+}
+
+// Subscribe adds a subscriber that gets the events that pass
+// a filter, from now on.
+func (h *Hub) Subscribe(f *Filter) (*Subscription, error) {
+	h.mutex.Lock()
+	defer h.mutex.Unlock()
+	if h.closed {
+		return nil, ErrClosed
+	}
+	if len(h.subs) >= h.config.MaxSubscribers {
+		return nil, ErrTooManySubscribers
+	}
+	s := &Subscription{hub: h, filter: f, events: make(chan *Event, h.config.BufferSize)}
+	h.subs[s] = true
+	return s, nil
+}
+
+// Publish numbers events and sends them to the subscribers
+// whose filters they pass.
+func (h *Hub) Publish(events ...*Event) {
+	now := time.Now().UnixNano() / int64(time.Millisecond)
+	h.mutex.Lock()
+	defer h.mutex.Unlock()
+	for _, e := range events {
+		h.seq++
+		e.Seq = h.seq
+		e.Time = now
+		for s := range h.subs {
+			if !s.filter.Match(e) {
+				continue
+			}
+			select {
+			case s.events <- e:
+			default:
+				s.lagged = true
+				h.remove(s)
+			}
+		}
+	}
+}
+
+// Subscribers returns how many subscribers the hub has.
+func (h *Hub) Subscribers() int {
+	h.mutex.Lock()
+	defer h.mutex.Unlock()
+	return len(h.subs)
+}
+
+// Close ends every subscription, and refuses new ones.
+func (h *Hub) Close() {
+	h.mutex.Lock()
+	defer h.mutex.Unlock()
+	h.closed = true
+	for s := range h.subs {
+		h.remove(s)
+	}
+}
+
+// remove ends a subscription. The hub's mutex must be held.
+func (h *Hub) remove(s *Subscription) {
+	if h.subs[s] {
+		delete(h.subs, s)
+		close(s.events)
+	}
+}
+
+// Events returns the subscriber's events. It is closed when
+// the subscription ends.
+func (s *Subscription) Events() <-chan *Event {
+	return s.events
+}
+
+// Lagged returns whether the subscription ended because the
+// subscriber fell more than the hub's BufferSize events
+// behind.
+func (s *Subscription) Lagged() bool {
+	s.hub.mutex.Lock()
+	defer s.hub.mutex.Unlock()
+	return s.lagged
+}
+
+// Close ends the subscription.
+func (s *Subscription) Close() {
+	s.hub.mutex.Lock()
+	defer s.hub.mutex.Unlock()
+	s.hub.remove(s)
+}
@@ -0,0 +1,139 @@
+package pkg
+
+import (
+	"Coin/pkg/block"
+	"Coin/pkg/peer"
+	"Coin/pkg/utils"
+	"fmt"
+)
+
+// PauseNetwork takes the node off the network. While paused,
+// the node accepts no connections, does not relay objects or
+// addresses, does not look for new peers, and does not mine.
+// Transactions that the wallet asks to broadcast are held
+// until the node resumes.
+func (n *Node) PauseNetwork() {
+	n.pauseMutex.Lock()
+	defer n.pauseMutex.Unlock()
+	if n.Paused.Load() {
+		return
+	}
+	n.Paused.Store(true)
+	if n.Server != nil {
+		n.Server.Stop()
+	}
+	if n.Config.MinerConfig.HasMiner {
+		n.wasMining = n.Miner.Active.Load()
+		if n.wasMining {
+			n.Miner.Pause()
+		}
+	}
+	n.logger(utils.ComponentNode).Info("paused")
+}
+
+// ResumeNetwork puts a paused node back on the network. It
+// reopens the server, handshakes with its peers again to learn
+// their best heights, and, if the node fell behind, syncs the
+// blocks it missed. Only then does the node mine again and
+// broadcast the transactions held while it was paused.
+// Returns:
+// error if the node could not listen for connections again,
+// in which case it stays paused, or if it could not catch up
+// with its peers. The node is resumed anyway in that case, so
+// that it can catch up with the blocks it is sent later on.
+func (n *Node) ResumeNetwork() error {
+	n.pauseMutex.Lock()
+	defer n.pauseMutex.Unlock()
+	if !n.Paused.Load() {
+		return nil
+	}
+	if !n.Config.NoListen {
+		if err := n.StartServer(n.listenAddr()); err != nil {
+			return fmt.Errorf("unable to listen: %w", err)
+		}
+	}
+	n.rehandshake()
+	err := n.catchUp()
+	if err != nil {
+		n.log.Warn("could not catch up", "err", err)
+	}
+	n.Paused.Store(false)
+	if n.Config.MinerConfig.HasMiner {
+		n.Miner.SetChainLength(n.BlockChain.Length)
+		n.Miner.PreviousHash = n.BlockChain.LastHash
+		if n.wasMining {
+			n.Miner.Resume()
+		}
+	}
+	n.heldMutex.Lock()
+	held := n.heldTransactions
+	n.heldTransactions = nil
+	n.heldMutex.Unlock()
+	for _, tx := range held {
+		n.BroadcastTransaction(tx)
+	}
+	n.logger(utils.ComponentNode).Info("resumed", "held", len(held))
+	return err
+}
+
+// rehandshake sends our version to every reachable peer
+// again, which tells us their best heights and the peers
+// that dropped us while we were paused. Peers that no longer
+// accept us are disconnected. Peers that do not listen cannot
+// be reached, and connect to us again on their own.
+func (n *Node) rehandshake() {
+	for _, p := range n.reachablePeers() {
+		ack, err := p.Addr.VersionRPC(n.versionRequest(p.Addr.Addr))
+		if err != nil || !ack.Accepted {
+			n.log.Info("lost peer while paused", "peer", p.Addr.Addr)
+			n.Disconnect(p.Addr.Addr)
+			continue
+		}
+		p.SetBest(ack.BestHeight, ack.BestHash)
+	}
+}
+
+// catchUp syncs blocks from the node's peers until the node
+// has caught up with the highest best height they reported.
+// Each round of Bootstrap fetches at most one batch of blocks,
+// so it is repeated as long as the node makes progress.
+func (n *Node) catchUp() error {
+	target := n.bestPeerHeight()
+	for n.BlockChain.Length < target {
+		n.log.Info("behind peers", "blocks", target-n.BlockChain.Length, "height", n.BlockChain.Length)
+		length := n.BlockChain.Length
+		if err := n.Bootstrap(); err != nil {
+			return err
+		}
+		if n.BlockChain.Length <= length {
+			return fmt.Errorf("stuck at height %v of %v", length, target)
+		}
+	}
+	return nil
+}
+
+// bestPeerHeight returns the highest best height reported by
+// a peer that we could sync blocks from.
+func (n *Node) bestPeerHeight() uint32 {
+	var best uint32
+	for _, p := range n.reachablePeers() {
+		if p.Services.Has(peer.ServiceFullChain) && p.BestHeight() > best {
+			best = p.BestHeight()
+		}
+	}
+	return best
+}
+
+// broadcastOrHold broadcasts a transaction from the wallet,
+// unless the node is paused, in which case the transaction is
+// held until the node resumes.
+func (n *Node) broadcastOrHold(tx *block.Transaction) {
+	n.heldMutex.Lock()
+	if n.Paused.Load() {
+		n.heldTransactions = append(n.heldTransactions, tx)
+		n.heldMutex.Unlock()
+		return
+	}
+	n.heldMutex.Unlock()
+	n.BroadcastTransaction(tx)
+}
@@ -0,0 +1,194 @@
+package peer
+
+import (
+	"Coin/pkg/pro"
+	"Coin/pkg/utils"
+	"github.com/syndtr/goleveldb/leveldb"
+	"google.golang.org/protobuf/proto"
+	"sync"
+	"time"
+)
+
+// logger logs for ban lists.
+var logger = utils.NewLogger(utils.ComponentP2P)
+
+// Ban is a time-limited ban of a node.
+// Addr is the banned address.
+// PublicKey is the public key that the banned node proved
+// it holds, if it proved one. Nodes holding that key are
+// refused no matter which address they come from.
+// Until is when the ban expires.
+// Reason is why the node was banned.
+type Ban struct {
+	Addr      string
+	PublicKey string
+	Until     time.Time
+	Reason    string
+}
+
+// EncodeBan returns a pro.Ban given a Ban.
+func EncodeBan(b *Ban) *pro.Ban {
+	return &pro.Ban{
+		Addr:      b.Addr,
+		PublicKey: b.PublicKey,
+		Until:     b.Until.Unix(),
+		Reason:    b.Reason,
+	}
+}
+
+// DecodeBan returns a Ban given a pro.Ban.
+func DecodeBan(pb *pro.Ban) *Ban {
+	return &Ban{
+		Addr:      pb.GetAddr(),
+		PublicKey: pb.GetPublicKey(),
+		Until:     time.Unix(pb.GetUntil(), 0),
+		Reason:    pb.GetReason(),
+	}
+}
+
+// BanList keeps track of banned nodes. Bans are kept in
+// memory and, if the BanList has a database, persisted to it
+// so that they survive restarts.
+// db is a levelDB for persistent storage. It is nil if bans
+// are only kept in memory.
+// bans maps a banned address to its ban.
+type BanList struct {
+	db   *leveldb.DB
+	bans map[string]*Ban
+
+	mutex sync.Mutex
+}
+
+// NewBanList returns a BanList that persists its bans to the
+// database at path, loading any bans already stored there.
+// If path is empty, bans are only kept in memory.
+func NewBanList(path string) *BanList {
+	bl := &BanList{bans: make(map[string]*Ban)}
+	if path == "" {
+		return bl
+	}
+	db, err := leveldb.OpenFile(path, nil)
+	if err != nil {
+		logger.Error("unable to open ban list, keeping bans in memory", "path", path, "err", err)
+		return bl
+	}
+	bl.db = db
+	iterator := db.NewIterator(nil, nil)
+	for iterator.Next() {
+		pb := &pro.Ban{}
+		if err := proto.Unmarshal(iterator.Value(), pb); err != nil {
+			logger.Error("unable to unmarshal ban", "peer", string(iterator.Key()), "err", err)
+			continue
+		}
+		bl.bans[pb.GetAddr()] = DecodeBan(pb)
+	}
+	iterator.Release()
+	return bl
+}
+
+// Add bans a node for a duration.
+// Inputs:
+// addr string the address of the node
+// pk string the public key of the node, if known
+// d time.Duration how long the ban lasts
+// reason string why the node is banned
+func (bl *BanList) Add(addr string, pk string, d time.Duration, reason string) {
+	b := &Ban{Addr: addr, PublicKey: pk, Until: time.Now().Add(d), Reason: reason}
+	bl.mutex.Lock()
+	defer bl.mutex.Unlock()
+	bl.bans[addr] = b
+	if bl.db == nil {
+		return
+	}
+	bytes, err := proto.Marshal(EncodeBan(b))
+	if err != nil {
+		logger.Error("unable to marshal ban", "peer", addr, "err", err)
+		return
+	}
+	if err = bl.db.Put([]byte(addr), bytes, nil); err != nil {
+		logger.Error("unable to store ban", "peer", addr, "err", err)
+	}
+}
+
+// IsBanned returns whether a node is banned, either by its
+// address or by its public key. Expired bans are removed.
+func (bl *BanList) IsBanned(addr string, pk string) bool {
+	bl.mutex.Lock()
+	defer bl.mutex.Unlock()
+	bl.removeExpired()
+	if _, ok := bl.bans[addr]; ok {
+		return true
+	}
+	if pk == "" {
+		return false
+	}
+	for _, b := range bl.bans {
+		if b.PublicKey == pk {
+			return true
+		}
+	}
+	return false
+}
+
+// List returns all bans that have not expired.
+func (bl *BanList) List() []*Ban {
+	bl.mutex.Lock()
+	defer bl.mutex.Unlock()
+	bl.removeExpired()
+	bans := make([]*Ban, 0, len(bl.bans))
+	for _, b := range bl.bans {
+		bans = append(bans, b)
+	}
+	return bans
+}
+
+// Remove lifts the ban on an address. It returns whether
+// the address was banned.
+func (bl *BanList) Remove(addr string) bool {
+	bl.mutex.Lock()
+	defer bl.mutex.Unlock()
+	if _, ok := bl.bans[addr]; !ok {
+		return false
+	}
+	bl.remove(addr)
+	return true
+}
+
+// Clear lifts every ban.
+func (bl *BanList) Clear() {
+	bl.mutex.Lock()
+	defer bl.mutex.Unlock()
+	for addr := range bl.bans {
+		bl.remove(addr)
+	}
+}
+
+// removeExpired removes every ban that has expired. The
+// caller must hold the mutex.
+func (bl *BanList) removeExpired() {
+	now := time.Now()
+	for addr, b := range bl.bans {
+		if now.After(b.Until) {
+			bl.remove(addr)
+		}
+	}
+}
+
+// remove removes a ban from memory and the database. The
+// caller must hold the mutex.
+func (bl *BanList) remove(addr string) {
+	delete(bl.bans, addr)
+	if bl.db == nil {
+		return
+	}
+	if err := bl.db.Delete([]byte(addr), nil); err != nil {
+		logger.Error("unable to delete ban", "peer", addr, "err", err)
+	}
+}
+
+// Close closes the BanList's database.
+func (bl *BanList) Close() {
+	if bl.db != nil {
+		bl.db.Close()
+	}
+}
@@ -0,0 +1,70 @@
+package peer
+
+import "time"
+
+// Config is the configuration for how the node treats
+// its peers: how misbehaving peers are banned and how
+// unresponsive peers are detected.
+// BanThreshold is the misbehavior score at which a peer
+// is disconnected and banned.
+// BanDuration is how long a ban lasts.
+// OffenseWeights is how much each kind of offense adds
+// to a peer's misbehavior score. Blocks are checked against
+// the main chain, so an honest peer on a fork can send blocks
+// that fail, and no single invalid block is enough for a ban.
+// BanListPath is the path of the database that bans are
+// persisted to. If it is empty, bans are only kept in memory.
+// PingInterval is how often each peer is pinged.
+// MaxMissedPings is how many pings in a row a peer may
+// leave unanswered before it is disconnected.
+// RateLimits is how often a peer may call each RPC, by the
+// RPC's name. Requests over the limit are refused. Callers
+// that are not peers are held to the same limits, each by
+// the identity of its connection.
+// MaxUnknownCallers is how many callers that are not peers
+// are rate limited at once. Past that, the limits of the
+// callers that were limited first are forgotten.
+// MaxAddrsPerMessage is the most addresses a peer may send
+// or be sent in one message.
+type Config struct {
+	BanThreshold   uint32
+	BanDuration    time.Duration
+	OffenseWeights map[Offense]uint32
+	BanListPath    string
+	PingInterval   time.Duration
+	MaxMissedPings uint32
+
+	RateLimits         map[string]RateLimit
+	MaxUnknownCallers  int
+	MaxAddrsPerMessage int
+}
+
+// DefaultConfig returns the default settings for
+// handling peers.
+func DefaultConfig() *Config {
+	return &Config{
+		BanThreshold: 100,
+		BanDuration:  24 * time.Hour,
+		OffenseWeights: map[Offense]uint32{
+			InvalidBlock:       20,
+			InvalidTransaction: 10,
+			MalformedMessage:   20,
+			UnrequestedData:    5,
+		},
+		BanListPath:    "bandata",
+		PingInterval:   time.Minute,
+		MaxMissedPings: 3,
+		RateLimits: map[string]RateLimit{
+			"Version":            {Rate: 1, Burst: 5},
+			"Ping":               {Rate: 1, Burst: 5},
+			"ForwardTransaction": {Rate: 50, Burst: 100},
+			"ForwardBlock":       {Rate: 5, Burst: 20},
+			"GetBlocks":          {Rate: 5, Burst: 20},
+			"GetData":            {Rate: 50, Burst: 500},
+			"SendAddresses":      {Rate: 1, Burst: 10},
+			"GetAddresses":       {Rate: 0.1, Burst: 5},
+		},
+		MaxUnknownCallers:  1000,
+		MaxAddrsPerMessage: 1000,
+	}
+}
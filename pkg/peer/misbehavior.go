@@ -0,0 +1,40 @@
+package peer
+
+// Offense is a kind of misbehavior that a peer
+// can be penalized for.
+type Offense int
+
+const (
+	// InvalidBlock is sending a block that fails validation.
+	InvalidBlock Offense = iota
+	// InvalidTransaction is sending a transaction that fails
+	// validation.
+	InvalidTransaction
+	// MalformedMessage is sending a request that cannot be
+	// handled at all.
+	MalformedMessage
+	// UnrequestedData is sending data that was not asked for.
+	UnrequestedData
+)
+
+// String returns a readable name for the offense.
+func (o Offense) String() string {
+	switch o {
+	case InvalidBlock:
+		return "invalid block"
+	case InvalidTransaction:
+		return "invalid transaction"
+	case MalformedMessage:
+		return "malformed message"
+	case UnrequestedData:
+		return "unrequested data"
+	default:
+		return "unknown offense"
+	}
+}
+
+// Misbehaved adds the weight of an offense to the peer's
+// misbehavior score and returns the new score.
+func (p *Peer) Misbehaved(weight uint32) uint32 {
+	return p.Misbehavior.Add(weight)
+}
@@ -2,14 +2,93 @@ package peer
 
 import (
 	"Coin/pkg/address"
+	"go.uber.org/atomic"
+	"sync"
 )
 
+// Peer is a node that we are connected to.
+// Addr is the address of the peer.
+// Version is the protocol version agreed on with the peer
+// during the handshake.
+// Services is what the peer can do for us.
+// UserAgent is the software the peer runs.
+// Inbound is whether the peer connected to us, rather than
+// us connecting to it.
+// NoListen is whether the peer does not accept connections,
+// so we never connect back to it.
+// bestHeight and bestHash describe the top of the peer's
+// blockchain, as last reported by the peer.
+// Misbehavior is the peer's misbehavior score. Once it
+// passes the ban threshold, the peer is banned.
+// RTT is the round trip time of the last ping the peer
+// answered. It is zero until the peer answers a ping.
+// MissedPings is the number of pings in a row that the
+// peer did not answer.
+// Limiter limits how often the peer may make each kind
+// of request.
+// IPs are the IP addresses of the host in the peer's address,
+// resolved once when the peer is added, which requests that
+// come without TLS are matched against.
 type Peer struct {
-	Addr       *address.Address
-	Version    uint32
-	bestHeight uint32
+	Addr        *address.Address
+	Version     uint32
+	Services    Services
+	UserAgent   string
+	Inbound     bool
+	NoListen    bool
+	bestHeight  uint32
+	bestHash    string
+	Misbehavior *atomic.Uint32
+	RTT         *atomic.Duration
+	MissedPings *atomic.Uint32
+	Limiter     *RateLimiter
+	IPs         []string
+
+	mutex sync.RWMutex
 }
 
 func New(addr *address.Address, version uint32, bestHeight uint32) *Peer {
-	return &Peer{Addr: addr, Version: version, bestHeight: bestHeight}
+	return &Peer{
+		Addr:        addr,
+		Version:     version,
+		bestHeight:  bestHeight,
+		Misbehavior: atomic.NewUint32(0),
+		RTT:         atomic.NewDuration(0),
+		MissedPings: atomic.NewUint32(0),
+		Limiter:     NewRateLimiter(nil),
+	}
+}
+
+// BestHeight returns the height of the peer's blockchain.
+func (p *Peer) BestHeight() uint32 {
+	p.mutex.RLock()
+	defer p.mutex.RUnlock()
+	return p.bestHeight
+}
+
+// BestHash returns the hash of the top block of the
+// peer's blockchain.
+func (p *Peer) BestHash() string {
+	p.mutex.RLock()
+	defer p.mutex.RUnlock()
+	return p.bestHash
+}
+
+// SetBest records the top of the peer's blockchain.
+func (p *Peer) SetBest(height uint32, hash string) {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+	p.bestHeight = height
+	p.bestHash = hash
+}
+
+// OnHost returns whether the peer is on the host with the IP
+// address ip.
+func (p *Peer) OnHost(ip string) bool {
+	for _, h := range p.IPs {
+		if h == ip {
+			return true
+		}
+	}
+	return false
 }
@@ -3,6 +3,7 @@ package peer
 type PeerDb interface {
 	Add(*Peer) bool
 	Get(string) *Peer
+	Remove(string) bool
 	UpdateLastSeen(string, uint32) error
 	List() []*Peer
 	GetRandom(int, []string) []*Peer
@@ -0,0 +1,99 @@
+package peer
+
+import (
+	"sync"
+	"time"
+)
+
+// RateLimit is the rate limit on one kind of request.
+// Rate is how many requests per second are allowed over
+// time.
+// Burst is how many requests may be made at once.
+type RateLimit struct {
+	Rate  float64
+	Burst int
+}
+
+// LimitStats describes the state of the rate limit on
+// one kind of request.
+// Tokens is how many requests may be made right now.
+// Dropped is how many requests were refused.
+type LimitStats struct {
+	RateLimit
+	Tokens  float64
+	Dropped uint64
+}
+
+// tokenBucket is a token bucket for one kind of request.
+// tokens is how many tokens were left at last.
+// last is when tokens was last updated.
+// dropped is how many requests were refused.
+type tokenBucket struct {
+	limit   RateLimit
+	tokens  float64
+	last    time.Time
+	dropped uint64
+}
+
+// refill adds the tokens earned since the bucket was
+// last updated.
+func (b *tokenBucket) refill(now time.Time) {
+	b.tokens += now.Sub(b.last).Seconds() * b.limit.Rate
+	if b.tokens > float64(b.limit.Burst) {
+		b.tokens = float64(b.limit.Burst)
+	}
+	b.last = now
+}
+
+// RateLimiter limits how often a peer may make each kind
+// of request, using a token bucket per kind of request.
+// buckets maps the name of an RPC to its bucket. RPCs
+// without a bucket are not limited.
+type RateLimiter struct {
+	buckets map[string]*tokenBucket
+
+	mutex sync.Mutex
+}
+
+// NewRateLimiter returns a RateLimiter with full buckets.
+// Inputs:
+// limits map[string]RateLimit the limit on each RPC, by name
+func NewRateLimiter(limits map[string]RateLimit) *RateLimiter {
+	rl := &RateLimiter{buckets: make(map[string]*tokenBucket)}
+	now := time.Now()
+	for method, l := range limits {
+		rl.buckets[method] = &tokenBucket{limit: l, tokens: float64(l.Burst), last: now}
+	}
+	return rl
+}
+
+// Allow returns whether a request to an RPC may be made
+// now, and if so, takes a token for it.
+func (rl *RateLimiter) Allow(method string) bool {
+	rl.mutex.Lock()
+	defer rl.mutex.Unlock()
+	b := rl.buckets[method]
+	if b == nil {
+		return true
+	}
+	b.refill(time.Now())
+	if b.tokens < 1 {
+		b.dropped++
+		return false
+	}
+	b.tokens--
+	return true
+}
+
+// Stats returns the state of the limit on each RPC.
+func (rl *RateLimiter) Stats() map[string]LimitStats {
+	rl.mutex.Lock()
+	defer rl.mutex.Unlock()
+	stats := make(map[string]LimitStats)
+	now := time.Now()
+	for method, b := range rl.buckets {
+		b.refill(now)
+		stats[method] = LimitStats{RateLimit: b.limit, Tokens: b.tokens, Dropped: b.dropped}
+	}
+	return stats
+}
@@ -0,0 +1,45 @@
+package peer
+
+import "strings"
+
+// Services is a set of flags describing what a node can do
+// for its peers. Nodes advertise their services during the
+// version handshake.
+type Services uint64
+
+const (
+	// ServiceFullChain is set by nodes that keep the whole
+	// blockchain and can serve any block.
+	ServiceFullChain Services = 1 << iota
+	// ServicePruned is set by nodes that only keep recent
+	// blocks.
+	ServicePruned
+	// ServiceMiner is set by nodes that mine blocks.
+	ServiceMiner
+	// ServiceTxIndex is set by nodes that can look up any
+	// transaction by its hash.
+	ServiceTxIndex
+)
+
+// serviceNames are the names of the service flags, in the
+// order of their bits.
+var serviceNames = []string{"full chain", "pruned", "miner", "txindex"}
+
+// Has returns whether every flag in o is set.
+func (s Services) Has(o Services) bool {
+	return s&o == o
+}
+
+// String returns the names of the flags that are set.
+func (s Services) String() string {
+	names := make([]string, 0)
+	for i, name := range serviceNames {
+		if s.Has(1 << uint(i)) {
+			names = append(names, name)
+		}
+	}
+	if len(names) == 0 {
+		return "none"
+	}
+	return strings.Join(names, ", ")
+}
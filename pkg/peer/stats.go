@@ -0,0 +1,38 @@
+package peer
+
+import "time"
+
+// Stats is a snapshot of what we know about a peer.
+// Limits is the state of the rate limit on each RPC
+// the peer may make, by name.
+// The other fields are as in Peer.
+type Stats struct {
+	Addr        string
+	Inbound     bool
+	Version     uint32
+	Services    Services
+	UserAgent   string
+	BestHeight  uint32
+	BestHash    string
+	RTT         time.Duration
+	Misbehavior uint32
+	MissedPings uint32
+	Limits      map[string]LimitStats
+}
+
+// Stats returns a snapshot of what we know about the peer.
+func (p *Peer) Stats() *Stats {
+	return &Stats{
+		Addr:        p.Addr.Addr,
+		Inbound:     p.Inbound,
+		Version:     p.Version,
+		Services:    p.Services,
+		UserAgent:   p.UserAgent,
+		BestHeight:  p.BestHeight(),
+		BestHash:    p.BestHash(),
+		RTT:         p.RTT.Load(),
+		Misbehavior: p.Misbehavior.Load(),
+		MissedPings: p.MissedPings.Load(),
+		Limits:      p.Limiter.Stats(),
+	}
+}
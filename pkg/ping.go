@@ -0,0 +1,57 @@
+package pkg
+
+import (
+	"Coin/pkg/peer"
+	"Coin/pkg/pro"
+	"math/rand"
+	"time"
+)
+
+// pingLoop pings a peer every PingInterval until the peer is
+// replaced or removed from the PeerDb, or the node is killed.
+// A peer that misses MaxMissedPings pings in a row is
+// disconnected. Peers are not pinged while the node is paused.
+// Inputs:
+// p *peer.Peer the peer to ping
+func (n *Node) pingLoop(p *peer.Peer) {
+	ticker := time.NewTicker(n.Config.PeerConfig.PingInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-n.ctx.Done():
+			return
+		case <-ticker.C:
+		}
+		if n.PeerDb.Get(p.Addr.Addr) != p {
+			return
+		}
+		if n.Paused.Load() {
+			continue
+		}
+		if n.ping(p) {
+			continue
+		}
+		missed := p.MissedPings.Inc()
+		if missed >= n.Config.PeerConfig.MaxMissedPings {
+			n.log.Info("disconnecting unresponsive peer", "peer", p.Addr.Addr, "missed_pings", missed)
+			n.Disconnect(p.Addr.Addr)
+			return
+		}
+	}
+}
+
+// ping sends a ping to a peer and, if the peer answers,
+// records the round trip time and when the peer was last
+// seen. It returns whether the peer answered.
+func (n *Node) ping(p *peer.Peer) bool {
+	nonce := rand.Uint64()
+	start := time.Now()
+	res, err := p.Addr.PingRPC(&pro.PingRequest{Nonce: nonce})
+	if err != nil || res.GetNonce() != nonce {
+		return false
+	}
+	p.RTT.Store(time.Since(start))
+	p.MissedPings.Store(0)
+	_ = n.updateLastSeen(p.Addr.Addr, uint32(time.Now().Unix()))
+	return true
+}
@@ -25,7 +25,7 @@ type CoinClient interface {
 	ForwardTransaction(ctx context.Context, in *Transaction, opts ...grpc.CallOption) (*Empty, error)
 	ForwardBlock(ctx context.Context, in *Block, opts ...grpc.CallOption) (*Empty, error)
 	// Establishes a one way connection to a node (may be reciprocated)
-	Version(ctx context.Context, in *VersionRequest, opts ...grpc.CallOption) (*Empty, error)
+	Version(ctx context.Context, in *VersionRequest, opts ...grpc.CallOption) (*VersionAck, error)
 	// Gets maximum 500 blocks past block with top hash
 	GetBlocks(ctx context.Context, in *GetBlocksRequest, opts ...grpc.CallOption) (*GetBlocksResponse, error)
 	// Get a single block
@@ -34,6 +34,8 @@ type CoinClient interface {
 	SendAddresses(ctx context.Context, in *Addresses, opts ...grpc.CallOption) (*Empty, error)
 	// Gets neighbor addresses from node (can be multicast with static addr_me)
 	GetAddresses(ctx context.Context, in *Empty, opts ...grpc.CallOption) (*Addresses, error)
+	// Checks that a peer is still alive and measures the round trip time to it
+	Ping(ctx context.Context, in *PingRequest, opts ...grpc.CallOption) (*PingResponse, error)
 }
 
 type coinClient struct {
@@ -62,8 +64,8 @@ func (c *coinClient) ForwardBlock(ctx context.Context, in *Block, opts ...grpc.C
 	return out, nil
 }
 
-func (c *coinClient) Version(ctx context.Context, in *VersionRequest, opts ...grpc.CallOption) (*Empty, error) {
-	out := new(Empty)
+func (c *coinClient) Version(ctx context.Context, in *VersionRequest, opts ...grpc.CallOption) (*VersionAck, error) {
+	out := new(VersionAck)
 	err := c.cc.Invoke(ctx, "/Coin/Version", in, out, opts...)
 	if err != nil {
 		return nil, err
@@ -107,6 +109,15 @@ func (c *coinClient) GetAddresses(ctx context.Context, in *Empty, opts ...grpc.C
 	return out, nil
 }
 
+func (c *coinClient) Ping(ctx context.Context, in *PingRequest, opts ...grpc.CallOption) (*PingResponse, error) {
+	out := new(PingResponse)
+	err := c.cc.Invoke(ctx, "/Coin/Ping", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
 // CoinServer is the server API for Coin service.
 // All implementations must embed UnimplementedCoinServer
 // for forward compatibility
@@ -114,7 +125,7 @@ type CoinServer interface {
 	ForwardTransaction(context.Context, *Transaction) (*Empty, error)
 	ForwardBlock(context.Context, *Block) (*Empty, error)
 	// Establishes a one way connection to a node (may be reciprocated)
-	Version(context.Context, *VersionRequest) (*Empty, error)
+	Version(context.Context, *VersionRequest) (*VersionAck, error)
 	// Gets maximum 500 blocks past block with top hash
 	GetBlocks(context.Context, *GetBlocksRequest) (*GetBlocksResponse, error)
 	// Get a single block
@@ -123,6 +134,8 @@ type CoinServer interface {
 	SendAddresses(context.Context, *Addresses) (*Empty, error)
 	// Gets neighbor addresses from node (can be multicast with static addr_me)
 	GetAddresses(context.Context, *Empty) (*Addresses, error)
+	// Checks that a peer is still alive and measures the round trip time to it
+	Ping(context.Context, *PingRequest) (*PingResponse, error)
 	mustEmbedUnimplementedCoinServer()
 }
 
@@ -136,7 +149,7 @@ func (UnimplementedCoinServer) ForwardTransaction(context.Context, *Transaction)
 func (UnimplementedCoinServer) ForwardBlock(context.Context, *Block) (*Empty, error) {
 	return nil, status.Errorf(codes.Unimplemented, "method ForwardBlock not implemented")
 }
-func (UnimplementedCoinServer) Version(context.Context, *VersionRequest) (*Empty, error) {
+func (UnimplementedCoinServer) Version(context.Context, *VersionRequest) (*VersionAck, error) {
 	return nil, status.Errorf(codes.Unimplemented, "method Version not implemented")
 }
 func (UnimplementedCoinServer) GetBlocks(context.Context, *GetBlocksRequest) (*GetBlocksResponse, error) {
@@ -151,6 +164,9 @@ func (UnimplementedCoinServer) SendAddresses(context.Context, *Addresses) (*Empt
 func (UnimplementedCoinServer) GetAddresses(context.Context, *Empty) (*Addresses, error) {
 	return nil, status.Errorf(codes.Unimplemented, "method GetAddresses not implemented")
 }
+func (UnimplementedCoinServer) Ping(context.Context, *PingRequest) (*PingResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method Ping not implemented")
+}
 func (UnimplementedCoinServer) mustEmbedUnimplementedCoinServer() {}
 
 // UnsafeCoinServer may be embedded to opt out of forward compatibility for this service.
@@ -290,6 +306,24 @@ func _Coin_GetAddresses_Handler(srv interface{}, ctx context.Context, dec func(i
 	return interceptor(ctx, in, info, handler)
 }
 
+func _Coin_Ping_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(PingRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(CoinServer).Ping(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/Coin/Ping",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(CoinServer).Ping(ctx, req.(*PingRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
 // Coin_ServiceDesc is the grpc.ServiceDesc for Coin service.
 // It's only intended for direct use with grpc.RegisterService,
 // and not to be introspected or modified (even as a copy)
@@ -325,6 +359,10 @@ var Coin_ServiceDesc = grpc.ServiceDesc{
 			MethodName: "GetAddresses",
 			Handler:    _Coin_GetAddresses_Handler,
 		},
+		{
+			MethodName: "Ping",
+			Handler:    _Coin_Ping_Handler,
+		},
 	},
 	Streams:  []grpc.StreamDesc{},
 	Metadata: "coin.proto",
@@ -0,0 +1,393 @@
+package script
+
+import (
+	"Coin/pkg/block"
+	"bytes"
+	"crypto/ecdsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/hex"
+	"errors"
+	"fmt"
+)
+
+// Checker answers the questions that scripts ask about the
+// transaction that spends their coin.
+type Checker interface {
+	// CheckSig returns whether sig is the signature of the
+	// spending transaction by the public key pk.
+	CheckSig(sig []byte, pk []byte) bool
+	// LockTime returns the lock time of the spending
+	// transaction.
+	LockTime() uint32
+}
+
+// TxChecker is the Checker of an input of a transaction.
+// Signatures are checked against the input's SigHash, as
+// ECDSA signatures in ASN.1 form by public keys in PKIX form.
+// sigHash is the input's SigHash, once it has been needed.
+type TxChecker struct {
+	Tx    *block.Transaction
+	Index int
+
+	sigHash []byte
+}
+
+// NewTxChecker returns the Checker of the input at index of tx.
+func NewTxChecker(tx *block.Transaction, index int) *TxChecker {
+	return &TxChecker{Tx: tx, Index: index}
+}
+
+func (c *TxChecker) CheckSig(sig []byte, pk []byte) bool {
+	key, err := x509.ParsePKIXPublicKey(pk)
+	if err != nil {
+		return false
+	}
+	ecKey, ok := key.(*ecdsa.PublicKey)
+	if !ok {
+		return false
+	}
+	if c.sigHash == nil {
+		c.sigHash = c.Tx.SigHash(c.Index)
+	}
+	return ecdsa.VerifyASN1(ecKey, c.sigHash, sig)
+}
+
+func (c *TxChecker) LockTime() uint32 {
+	return c.Tx.LockTime
+}
+
+// Verify checks that an input may spend a coin. If the coin's
+// locking script is a script, the input's unlocking script must
+// unlock it, like Run. Otherwise the locking script is a bare
+// public key, and the unlocking script must be the key's
+// signature of the spending transaction, as hex.
+// Inputs:
+// unlocking string the input's unlocking script
+// locking string the coin's locking script
+// c Checker the transaction that spends the coin
+// Returns:
+// error why the coin may not be spent, or nil if it may
+func Verify(unlocking string, locking string, c Checker) error {
+	if IsScript(locking) {
+		return Run(unlocking, locking, c)
+	}
+	pk := bareKey(locking)
+	if _, err := x509.ParsePKIXPublicKey(pk); err != nil {
+		return fmt.Errorf("coin is locked to %q, which is not a public key", locking)
+	}
+	sig, err := hex.DecodeString(unlocking)
+	if err != nil || len(sig) == 0 {
+		return errors.New("unlocking script is not a hex signature")
+	}
+	if !c.CheckSig(sig, pk) {
+		return errors.New("signature is not by the coin's public key")
+	}
+	return nil
+}
+
+// Run runs an unlocking script and then the locking script of
+// the coin that it spends.
+// Inputs:
+// unlocking string the unlocking script, which may only push
+// data
+// locking string the locking script
+// c Checker the transaction that spends the coin
+// Returns:
+// error why the coin may not be spent, or nil if it may
+func Run(unlocking string, locking string, c Checker) error {
+	unlock, err := parse(unlocking)
+	if err != nil {
+		return fmt.Errorf("unlocking script is invalid: %v", err)
+	}
+	for _, ins := range unlock {
+		if !isPush(ins.op) {
+			return fmt.Errorf("unlocking script may only push data, but has %v", ins.op)
+		}
+	}
+	lock, err := parse(locking)
+	if err != nil {
+		return fmt.Errorf("locking script is invalid: %v", err)
+	}
+	e := &engine{checker: c}
+	if err := e.run(unlock); err != nil {
+		return fmt.Errorf("unlocking script failed: %v", err)
+	}
+	e.ops = 0
+	if err := e.run(lock); err != nil {
+		return fmt.Errorf("locking script failed: %v", err)
+	}
+	if len(e.stack) == 0 || !truthy(e.stack[len(e.stack)-1]) {
+		return errors.New("locking script ended false")
+	}
+	return nil
+}
+
+// engine runs scripts.
+// checker is the transaction that spends the coin.
+// stack is the data stack, top last.
+// conditions are whether each OpIf that the engine is in is
+// running its current branch, innermost last.
+// ops is how many Ops the current script has run.
+type engine struct {
+	checker    Checker
+	stack      [][]byte
+	conditions []bool
+	ops        int
+}
+
+// running returns whether the engine is in branches that run.
+func (e *engine) running() bool {
+	for _, c := range e.conditions {
+		if !c {
+			return false
+		}
+	}
+	return true
+}
+
+func (e *engine) push(b []byte) error {
+	if len(e.stack) >= MaxStackSize {
+		return fmt.Errorf("stack holds more than %v values", MaxStackSize)
+	}
+	e.stack = append(e.stack, b)
+	return nil
+}
+
+func (e *engine) pop() ([]byte, error) {
+	if len(e.stack) == 0 {
+		return nil, errors.New("stack is empty")
+	}
+	b := e.stack[len(e.stack)-1]
+	e.stack = e.stack[:len(e.stack)-1]
+	return b, nil
+}
+
+func (e *engine) popNum() (int64, error) {
+	b, err := e.pop()
+	if err != nil {
+		return 0, err
+	}
+	return decodeNum(b)
+}
+
+func (e *engine) pushBool(v bool) error {
+	if v {
+		return e.push([]byte{1})
+	}
+	return e.push(nil)
+}
+
+// run runs a script's instructions on the engine's stack. Each
+// script must close the OpIfs that it opens.
+func (e *engine) run(instructions []*instruction) error {
+	for _, ins := range instructions {
+		if err := e.step(ins); err != nil {
+			return fmt.Errorf("%v: %v", ins.op, err)
+		}
+	}
+	if len(e.conditions) > 0 {
+		return errors.New("OP_IF is missing its OP_ENDIF")
+	}
+	return nil
+}
+
+// step runs an instruction.
+func (e *engine) step(ins *instruction) error {
+	if len(ins.data) > MaxElementSize {
+		return fmt.Errorf("pushes %v bytes, more than %v", len(ins.data), MaxElementSize)
+	}
+	if !isPush(ins.op) {
+		if e.ops++; e.ops > MaxOps {
+			return fmt.Errorf("script runs more than %v ops", MaxOps)
+		}
+	}
+	switch ins.op {
+	case OpIf, OpNotIf:
+		run := false
+		if e.running() {
+			b, err := e.pop()
+			if err != nil {
+				return err
+			}
+			run = truthy(b) == (ins.op == OpIf)
+		}
+		e.conditions = append(e.conditions, run)
+		return nil
+	case OpElse:
+		if len(e.conditions) == 0 {
+			return errors.New("no OP_IF to be in")
+		}
+		e.conditions[len(e.conditions)-1] = !e.conditions[len(e.conditions)-1]
+		return nil
+	case OpEndIf:
+		if len(e.conditions) == 0 {
+			return errors.New("no OP_IF to end")
+		}
+		e.conditions = e.conditions[:len(e.conditions)-1]
+		return nil
+	}
+	if !e.running() {
+		return nil
+	}
+
+	switch op := ins.op; {
+	case op <= OpPushData2:
+		return e.push(ins.data)
+	case op == Op1Negate:
+		return e.push(encodeNum(-1))
+	case op >= Op1 && op <= Op16:
+		return e.push(encodeNum(int64(op-Op1) + 1))
+	}
+
+	switch ins.op {
+	case OpNop:
+		return nil
+	case OpVerify:
+		return e.verify()
+	case OpReturn:
+		return errors.New("output cannot be spent")
+	case OpDrop:
+		_, err := e.pop()
+		return err
+	case OpDup:
+		if len(e.stack) == 0 {
+			return errors.New("stack is empty")
+		}
+		return e.push(e.stack[len(e.stack)-1])
+	case OpSwap:
+		if len(e.stack) < 2 {
+			return errors.New("stack holds fewer than 2 values")
+		}
+		top := len(e.stack) - 1
+		e.stack[top], e.stack[top-1] = e.stack[top-1], e.stack[top]
+		return nil
+	case OpSize:
+		if len(e.stack) == 0 {
+			return errors.New("stack is empty")
+		}
+		return e.push(encodeNum(int64(len(e.stack[len(e.stack)-1]))))
+	case OpEqual, OpEqualVerify:
+		a, err := e.pop()
+		if err != nil {
+			return err
+		}
+		b, err := e.pop()
+		if err != nil {
+			return err
+		}
+		if err := e.pushBool(bytes.Equal(a, b)); err != nil {
+			return err
+		}
+		if ins.op == OpEqualVerify {
+			return e.verify()
+		}
+		return nil
+	case OpSHA256:
+		b, err := e.pop()
+		if err != nil {
+			return err
+		}
+		h := sha256.Sum256(b)
+		return e.push(h[:])
+	case OpCheckSig, OpCheckSigVerify:
+		pk, err := e.pop()
+		if err != nil {
+			return err
+		}
+		sig, err := e.pop()
+		if err != nil {
+			return err
+		}
+		if err := e.pushBool(e.checker.CheckSig(sig, pk)); err != nil {
+			return err
+		}
+		if ins.op == OpCheckSigVerify {
+			return e.verify()
+		}
+		return nil
+	case OpCheckMultiSig, OpCheckMultiSigVerify:
+		if err := e.checkMultiSig(); err != nil {
+			return err
+		}
+		if ins.op == OpCheckMultiSigVerify {
+			return e.verify()
+		}
+		return nil
+	case OpCheckLockTimeVerify:
+		if len(e.stack) == 0 {
+			return errors.New("stack is empty")
+		}
+		lockTime, err := decodeNum(e.stack[len(e.stack)-1])
+		if err != nil {
+			return err
+		}
+		if lockTime < 0 {
+			return errors.New("lock time is negative")
+		}
+		if int64(e.checker.LockTime()) < lockTime {
+			return fmt.Errorf("transaction is locked until %v, not %v", e.checker.LockTime(), lockTime)
+		}
+		return nil
+	}
+	return fmt.Errorf("op does not exist")
+}
+
+// verify pops a value and fails unless it is true.
+func (e *engine) verify() error {
+	b, err := e.pop()
+	if err != nil {
+		return err
+	}
+	if !truthy(b) {
+		return errors.New("verify failed")
+	}
+	return nil
+}
+
+// checkMultiSig pops the keys and signatures of an
+// OpCheckMultiSig, and pushes whether the signatures match.
+func (e *engine) checkMultiSig() error {
+	n, err := e.popNum()
+	if err != nil {
+		return err
+	}
+	if n < 1 || n > MaxMultiSigKeys {
+		return fmt.Errorf("key count %v is not between 1 and %v", n, MaxMultiSigKeys)
+	}
+	if e.ops += int(n); e.ops > MaxOps {
+		return fmt.Errorf("script runs more than %v ops", MaxOps)
+	}
+	keys := make([][]byte, n)
+	for i := n - 1; i >= 0; i-- {
+		if keys[i], err = e.pop(); err != nil {
+			return err
+		}
+	}
+	m, err := e.popNum()
+	if err != nil {
+		return err
+	}
+	if m < 1 || m > n {
+		return fmt.Errorf("signature count %v is not between 1 and the key count %v", m, n)
+	}
+	sigs := make([][]byte, m)
+	for i := m - 1; i >= 0; i-- {
+		if sigs[i], err = e.pop(); err != nil {
+			return err
+		}
+	}
+	// each signature must match a key after the one that the
+	// signature before it matched
+	k := 0
+	for _, sig := range sigs {
+		for k < len(keys) && !e.checker.CheckSig(sig, keys[k]) {
+			k++
+		}
+		if k == len(keys) {
+			return e.pushBool(false)
+		}
+		k++
+	}
+	return e.pushBool(true)
+}
@@ -0,0 +1,365 @@
+// Package script is a small, deterministic, stack-based language
+// for the conditions under which coins may be spent.
+//
+// A TransactionOutput's LockingScript says what it takes to spend
+// the coin, and the UnlockingScript of the TransactionInput that
+// spends it provides that. To check a spend, the unlocking script
+// is run, and then the locking script is run on the stack that it
+// left behind. The spend is valid if neither fails and the top of
+// the stack is then true.
+//
+// Scripts are bytes: a Version byte, followed by instructions. An
+// instruction either pushes data, which is how numbers, public
+// keys and signatures get on the stack, or is one of the Ops.
+// Transactions carry scripts as hex, like they do public keys.
+// Numbers are little-endian, with the top bit of the last byte as
+// their sign, and are at most 4 bytes long. Empty data, zeroes and
+// negative zero are false, and anything else is true.
+//
+// A locking script that is not hex starting with Version is a bare
+// public key, from before scripts existed. It is not run; Verify
+// checks the spender's signature against it instead. Public keys
+// in PKIX form start with 0x30, so are never mistaken for scripts.
+//
+// The standard scripts, such as PayToPubKeyHash, are built by the
+// functions in standard.go, and others by a Builder.
+package script
+
+import (
+	"encoding/binary"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// Version is the first byte of every script.
+const Version byte = 0x01
+
+// Limits that keep scripts cheap to run.
+const (
+	// MaxScriptSize is the largest a script may be, in bytes.
+	MaxScriptSize = 10000
+	// MaxElementSize is the most bytes that may be pushed at
+	// once.
+	MaxElementSize = 520
+	// MaxStackSize is the most elements that the stack may hold.
+	MaxStackSize = 1000
+	// MaxOps is the most Ops, other than pushes, that a script
+	// may run.
+	MaxOps = 201
+	// MaxMultiSigKeys is the most public keys that one
+	// OpCheckMultiSig may check against.
+	MaxMultiSigKeys = 20
+	// maxNumSize is the longest that a number may be, in bytes.
+	maxNumSize = 4
+)
+
+// Op is an instruction of a script.
+type Op byte
+
+// The Ops. Their values are those of the matching Bitcoin
+// opcodes.
+const (
+	// Op0 pushes empty data, which is false.
+	Op0 Op = 0x00
+	// Ops from 0x01 to 0x4b push that many bytes, which follow
+	// them.
+	// OpPushData1 pushes as many bytes as the byte after it says.
+	OpPushData1 Op = 0x4c
+	// OpPushData2 pushes as many bytes as the two bytes after it
+	// say, little-endian.
+	OpPushData2 Op = 0x4d
+	// Op1Negate pushes -1.
+	Op1Negate Op = 0x4f
+	// Op1 to Op16 push the numbers 1 to 16.
+	Op1  Op = 0x51
+	Op16 Op = 0x60
+	// OpNop does nothing.
+	OpNop Op = 0x61
+	// OpIf runs the instructions up to the matching OpElse or
+	// OpEndIf if it pops true, and the ones after OpElse, if
+	// there is one, otherwise.
+	OpIf Op = 0x63
+	// OpNotIf is OpIf for false.
+	OpNotIf Op = 0x64
+	// OpElse separates the branches of an OpIf or OpNotIf.
+	OpElse Op = 0x67
+	// OpEndIf ends an OpIf or OpNotIf.
+	OpEndIf Op = 0x68
+	// OpVerify pops a value and fails unless it is true.
+	OpVerify Op = 0x69
+	// OpReturn fails. It makes outputs that cannot be spent.
+	OpReturn Op = 0x6a
+	// OpDrop pops a value.
+	OpDrop Op = 0x75
+	// OpDup pushes the top value again.
+	OpDup Op = 0x76
+	// OpSwap swaps the top two values.
+	OpSwap Op = 0x7c
+	// OpSize pushes the size of the top value, in bytes.
+	OpSize Op = 0x82
+	// OpEqual pops two values and pushes whether they are equal.
+	OpEqual Op = 0x87
+	// OpEqualVerify is OpEqual followed by OpVerify.
+	OpEqualVerify Op = 0x88
+	// OpSHA256 pops a value and pushes its SHA-256 hash.
+	OpSHA256 Op = 0xa8
+	// OpCheckSig pops a public key and then a signature, and
+	// pushes whether the signature is the key's signature of
+	// the spending transaction.
+	OpCheckSig Op = 0xac
+	// OpCheckSigVerify is OpCheckSig followed by OpVerify.
+	OpCheckSigVerify Op = 0xad
+	// OpCheckMultiSig pops a count n, n public keys, a count m
+	// and m signatures, and pushes whether each signature is of
+	// the spending transaction by a different one of the keys.
+	// The signatures must be in the same order as their keys.
+	OpCheckMultiSig Op = 0xae
+	// OpCheckMultiSigVerify is OpCheckMultiSig followed by
+	// OpVerify.
+	OpCheckMultiSigVerify Op = 0xaf
+	// OpCheckLockTimeVerify fails unless the lock time of the
+	// spending transaction is at least the number on top of the
+	// stack, which it leaves there.
+	OpCheckLockTimeVerify Op = 0xb1
+)
+
+// opNames are the names of the Ops that are not pushes.
+var opNames = map[Op]string{
+	OpNop:                 "OP_NOP",
+	OpIf:                  "OP_IF",
+	OpNotIf:               "OP_NOTIF",
+	OpElse:                "OP_ELSE",
+	OpEndIf:               "OP_ENDIF",
+	OpVerify:              "OP_VERIFY",
+	OpReturn:              "OP_RETURN",
+	OpDrop:                "OP_DROP",
+	OpDup:                 "OP_DUP",
+	OpSwap:                "OP_SWAP",
+	OpSize:                "OP_SIZE",
+	OpEqual:               "OP_EQUAL",
+	OpEqualVerify:         "OP_EQUALVERIFY",
+	OpSHA256:              "OP_SHA256",
+	OpCheckSig:            "OP_CHECKSIG",
+	OpCheckSigVerify:      "OP_CHECKSIGVERIFY",
+	OpCheckMultiSig:       "OP_CHECKMULTISIG",
+	OpCheckMultiSigVerify: "OP_CHECKMULTISIGVERIFY",
+	OpCheckLockTimeVerify: "OP_CHECKLOCKTIMEVERIFY",
+}
+
+// String returns the name of an Op.
+func (op Op) String() string {
+	switch {
+	case op == Op0:
+		return "OP_0"
+	case op < OpPushData1:
+		return fmt.Sprintf("OP_PUSH%v", byte(op))
+	case op == OpPushData1:
+		return "OP_PUSHDATA1"
+	case op == OpPushData2:
+		return "OP_PUSHDATA2"
+	case op == Op1Negate:
+		return "OP_1NEGATE"
+	case op >= Op1 && op <= Op16:
+		return fmt.Sprintf("OP_%v", byte(op-Op1)+1)
+	}
+	if name, ok := opNames[op]; ok {
+		return name
+	}
+	return fmt.Sprintf("OP_UNKNOWN%v", byte(op))
+}
+
+// isPush returns whether an Op only pushes a value.
+func isPush(op Op) bool {
+	return op <= OpPushData2 || op == Op1Negate || (op >= Op1 && op <= Op16)
+}
+
+// instruction is a parsed instruction of a script. data is what
+// a push pushes.
+type instruction struct {
+	op   Op
+	data []byte
+}
+
+// IsScript returns whether s is a script, as opposed to a bare
+// public key.
+func IsScript(s string) bool {
+	b, err := hex.DecodeString(s)
+	return err == nil && len(b) > 0 && b[0] == Version
+}
+
+// parse splits a script into its instructions. It fails on
+// scripts that are too large, that push data past their end or
+// that hold Ops that do not exist.
+func parse(s string) ([]*instruction, error) {
+	b, err := hex.DecodeString(s)
+	if err != nil {
+		return nil, fmt.Errorf("script is not hex: %v", err)
+	}
+	if len(b) == 0 || b[0] != Version {
+		return nil, errors.New("script does not start with the script version")
+	}
+	if len(b) > MaxScriptSize {
+		return nil, fmt.Errorf("script is %v bytes, more than %v", len(b), MaxScriptSize)
+	}
+	b = b[1:]
+	var instructions []*instruction
+	for i := 0; i < len(b); {
+		op := Op(b[i])
+		i++
+		size := 0
+		switch {
+		case op < OpPushData1:
+			size = int(op)
+		case op == OpPushData1:
+			if i+1 > len(b) {
+				return nil, errors.New("OP_PUSHDATA1 is missing its size")
+			}
+			size = int(b[i])
+			i++
+		case op == OpPushData2:
+			if i+2 > len(b) {
+				return nil, errors.New("OP_PUSHDATA2 is missing its size")
+			}
+			size = int(binary.LittleEndian.Uint16(b[i:]))
+			i += 2
+		case isPush(op):
+		default:
+			if _, ok := opNames[op]; !ok {
+				return nil, fmt.Errorf("byte %v of the script, 0x%02x, is not an op", i, byte(op))
+			}
+		}
+		if i+size > len(b) {
+			return nil, fmt.Errorf("%v pushes past the end of the script", op)
+		}
+		ins := &instruction{op: op}
+		if op <= OpPushData2 {
+			ins.data = b[i : i+size]
+		}
+		instructions = append(instructions, ins)
+		i += size
+	}
+	return instructions, nil
+}
+
+// Disassemble returns a script as text: the names of its Ops,
+// with pushed data as hex in angle brackets.
+func Disassemble(s string) (string, error) {
+	instructions, err := parse(s)
+	if err != nil {
+		return "", err
+	}
+	var words []string
+	for _, ins := range instructions {
+		if ins.op <= OpPushData2 && len(ins.data) > 0 {
+			words = append(words, "<"+hex.EncodeToString(ins.data)+">")
+		} else {
+			words = append(words, ins.op.String())
+		}
+	}
+	return strings.Join(words, " "), nil
+}
+
+// Builder builds a script one instruction at a time. It pushes
+// data with the shortest instruction that can push it.
+type Builder struct {
+	b []byte
+}
+
+// NewBuilder returns a Builder of an empty script.
+func NewBuilder() *Builder {
+	return &Builder{b: []byte{Version}}
+}
+
+// AddOp adds an Op.
+func (b *Builder) AddOp(op Op) *Builder {
+	b.b = append(b.b, byte(op))
+	return b
+}
+
+// AddData adds a push of data.
+func (b *Builder) AddData(data []byte) *Builder {
+	switch n := len(data); {
+	case n < int(OpPushData1):
+		b.b = append(b.b, byte(n))
+	case n <= 0xff:
+		b.b = append(b.b, byte(OpPushData1), byte(n))
+	default:
+		b.b = append(b.b, byte(OpPushData2), byte(n), byte(n>>8))
+	}
+	b.b = append(b.b, data...)
+	return b
+}
+
+// AddInt adds a push of a number.
+func (b *Builder) AddInt(n int64) *Builder {
+	switch {
+	case n == 0:
+		return b.AddOp(Op0)
+	case n == -1:
+		return b.AddOp(Op1Negate)
+	case n >= 1 && n <= 16:
+		return b.AddOp(Op1 + Op(n-1))
+	}
+	return b.AddData(encodeNum(n))
+}
+
+// Script returns the script built so far, as hex.
+func (b *Builder) Script() string {
+	return hex.EncodeToString(b.b)
+}
+
+// encodeNum returns the shortest encoding of a number.
+func encodeNum(n int64) []byte {
+	if n == 0 {
+		return nil
+	}
+	negative := n < 0
+	if negative {
+		n = -n
+	}
+	var b []byte
+	for ; n > 0; n >>= 8 {
+		b = append(b, byte(n))
+	}
+	if b[len(b)-1]&0x80 != 0 {
+		// the top bit is taken, so the sign gets a byte of its own
+		if negative {
+			b = append(b, 0x80)
+		} else {
+			b = append(b, 0)
+		}
+	} else if negative {
+		b[len(b)-1] |= 0x80
+	}
+	return b
+}
+
+// decodeNum returns the number that b encodes.
+func decodeNum(b []byte) (int64, error) {
+	if len(b) > maxNumSize {
+		return 0, fmt.Errorf("number is %v bytes, more than %v", len(b), maxNumSize)
+	}
+	if len(b) == 0 {
+		return 0, nil
+	}
+	var n int64
+	for i, c := range b {
+		n |= int64(c) << (8 * uint(i))
+	}
+	if top := len(b) - 1; b[top]&0x80 != 0 {
+		return -(n &^ (int64(0x80) << (8 * uint(top)))), nil
+	}
+	return n, nil
+}
+
+// truthy returns whether a value is true.
+func truthy(b []byte) bool {
+	for i, c := range b {
+		if c != 0 && !(i == len(b)-1 && c == 0x80) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,152 @@
+package script
+
+import (
+	"Coin/pkg/block"
+	"crypto/ecdsa"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+)
+
+// Public keys in the standard scripts are in PKIX form, as
+// id.ID.GetPublicKeyBytes returns them, and signatures are made
+// by Sign. The scripts are hex, ready to be a TransactionOutput's
+// LockingScript or a TransactionInput's UnlockingScript.
+
+// PayToPubKey returns a locking script that the holder of a
+// public key may spend:
+//
+//	<pk> OP_CHECKSIG
+//
+// It is unlocked by UnlockPubKey.
+func PayToPubKey(pk []byte) string {
+	return NewBuilder().AddData(pk).AddOp(OpCheckSig).Script()
+}
+
+// PayToPubKeyHash returns a locking script that the holder of
+// the public key with a SHA-256 hash may spend, without the key
+// being known until it is spent:
+//
+//	OP_DUP OP_SHA256 <sha256(pk)> OP_EQUALVERIFY OP_CHECKSIG
+//
+// It is unlocked by UnlockPubKeyHash.
+func PayToPubKeyHash(pk []byte) string {
+	h := sha256.Sum256(pk)
+	return NewBuilder().AddOp(OpDup).AddOp(OpSHA256).AddData(h[:]).
+		AddOp(OpEqualVerify).AddOp(OpCheckSig).Script()
+}
+
+// MultiSig returns a locking script that m of the holders of
+// public keys must sign to spend:
+//
+//	m <pk 1> ... <pk n> n OP_CHECKMULTISIG
+//
+// It is unlocked by UnlockMultiSig.
+func MultiSig(m int, pks ...[]byte) (string, error) {
+	if len(pks) < 1 || len(pks) > MaxMultiSigKeys {
+		return "", fmt.Errorf("a multisig needs between 1 and %v keys, not %v", MaxMultiSigKeys, len(pks))
+	}
+	if m < 1 || m > len(pks) {
+		return "", fmt.Errorf("a multisig of %v keys needs between 1 and %v signatures, not %v", len(pks), len(pks), m)
+	}
+	b := NewBuilder().AddInt(int64(m))
+	for _, pk := range pks {
+		b.AddData(pk)
+	}
+	return b.AddInt(int64(len(pks))).AddOp(OpCheckMultiSig).Script(), nil
+}
+
+// HashLock returns a locking script that whoever knows data with
+// a SHA-256 hash may spend. If pk is not nil, the holder of pk
+// must also sign:
+//
+//	OP_SHA256 <hash> OP_EQUAL
+//	OP_SHA256 <hash> OP_EQUALVERIFY <pk> OP_CHECKSIG
+//
+// It is unlocked by UnlockHashLock.
+func HashLock(hash []byte, pk []byte) string {
+	b := NewBuilder().AddOp(OpSHA256).AddData(hash)
+	if pk == nil {
+		return b.AddOp(OpEqual).Script()
+	}
+	return b.AddOp(OpEqualVerify).AddData(pk).AddOp(OpCheckSig).Script()
+}
+
+// TimeLock returns a locking script that the holder of a public
+// key may only spend in a transaction whose lock time is at
+// least lockTime:
+//
+//	<lockTime> OP_CHECKLOCKTIMEVERIFY OP_DROP <pk> OP_CHECKSIG
+//
+// It is unlocked by UnlockPubKey.
+func TimeLock(lockTime uint32, pk []byte) string {
+	return NewBuilder().AddInt(int64(lockTime)).AddOp(OpCheckLockTimeVerify).AddOp(OpDrop).
+		AddData(pk).AddOp(OpCheckSig).Script()
+}
+
+// UnlockPubKey returns the unlocking script of a PayToPubKey or
+// TimeLock coin.
+func UnlockPubKey(sig []byte) string {
+	return NewBuilder().AddData(sig).Script()
+}
+
+// UnlockPubKeyHash returns the unlocking script of a
+// PayToPubKeyHash coin.
+func UnlockPubKeyHash(sig []byte, pk []byte) string {
+	return NewBuilder().AddData(sig).AddData(pk).Script()
+}
+
+// UnlockMultiSig returns the unlocking script of a MultiSig coin.
+// The signatures must be in the same order as their keys are in
+// the locking script.
+func UnlockMultiSig(sigs ...[]byte) string {
+	b := NewBuilder()
+	for _, sig := range sigs {
+		b.AddData(sig)
+	}
+	return b.Script()
+}
+
+// UnlockHashLock returns the unlocking script of a HashLock coin.
+// sig is nil if the coin needs no signature.
+func UnlockHashLock(data []byte, sig []byte) string {
+	b := NewBuilder()
+	if sig != nil {
+		b.AddData(sig)
+	}
+	return b.AddData(data).Script()
+}
+
+// Sign returns the signature, by a private key, of the input at
+// index of a transaction, for an unlocking script. Every other
+// part of the transaction, other than unlocking scripts, must be
+// final, since the signature covers it.
+func Sign(sk *ecdsa.PrivateKey, tx *block.Transaction, index int) ([]byte, error) {
+	return ecdsa.SignASN1(rand.Reader, sk, tx.SigHash(index))
+}
+
+// PaysTo returns whether a locking script pays to a public key
+// alone: whether it is the bare key, or a PayToPubKey or
+// PayToPubKeyHash script of it. The key may be hex, like the
+// bare keys of locking scripts are.
+func PaysTo(locking string, pk string) bool {
+	if locking == pk {
+		return true
+	}
+	if !IsScript(locking) {
+		return false
+	}
+	b := bareKey(pk)
+	return locking == PayToPubKey(b) || locking == PayToPubKeyHash(b)
+}
+
+// bareKey returns the bytes of a public key that is held as a
+// string, either as hex, like the genesis key, or as its bytes,
+// like id.ID.GetPublicKeyString returns it.
+func bareKey(pk string) []byte {
+	if b, err := hex.DecodeString(pk); err == nil {
+		return b
+	}
+	return []byte(pk)
+}
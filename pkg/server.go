@@ -3,12 +3,22 @@ package pkg
 import (
 	"Coin/pkg/address"
 	"Coin/pkg/block"
+	"Coin/pkg/id"
 	"Coin/pkg/peer"
 	"Coin/pkg/pro"
 	"Coin/pkg/utils"
 	"errors"
 	"fmt"
 	"golang.org/x/net/context"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/metadata"
+	grpcpeer "google.golang.org/grpc/peer"
+	"google.golang.org/grpc/status"
+	"math/rand"
+	"net"
+	"path"
 	"time"
 )
 
@@ -17,46 +27,309 @@ func (n *Node) peerCheck(addr string) error {
 	if n.PeerDb.Get(addr) == nil {
 		return errors.New("request from non-peered node")
 	}
-	err := n.PeerDb.UpdateLastSeen(addr, uint32(time.Now().UnixNano()))
+	err := n.updateLastSeen(addr, uint32(time.Now().Unix()))
 	if err != nil {
-		fmt.Printf("ERROR {Node.peerCheck}: error" +
-			"when calling updatelastseen.\n")
+		n.log.Warn("unable to update last seen", "peer", addr, "err", err)
 	}
 	return nil
 }
 
-// Version Handles version request (a request to become a peer)
-func (n *Node) Version(ctx context.Context, in *pro.VersionRequest) (*pro.Empty, error) {
-	// Reject all outdated versions (this is not true to Satoshi Client)
-	if int(in.Version) != n.Config.Version {
-		return &pro.Empty{}, nil
+// updateLastSeen records when a peer was last heard from. The
+// peer and the address database hold separate copies of the
+// peer's address, so both are updated.
+func (n *Node) updateLastSeen(addr string, lastSeen uint32) error {
+	_ = n.AddressDB.UpdateLastSeen(addr, lastSeen)
+	return n.PeerDb.UpdateLastSeen(addr, lastSeen)
+}
+
+// peerPublicKey returns the public key that the requesting
+// node proved it holds during the TLS handshake.
+func peerPublicKey(ctx context.Context) (string, error) {
+	p, ok := grpcpeer.FromContext(ctx)
+	if !ok {
+		return "", errors.New("request has no peer information")
+	}
+	tlsInfo, ok := p.AuthInfo.(credentials.TLSInfo)
+	if !ok {
+		return "", errors.New("request was not made over tls")
+	}
+	rawCerts := make([][]byte, 0, len(tlsInfo.State.PeerCertificates))
+	for _, cert := range tlsInfo.State.PeerCertificates {
+		rawCerts = append(rawCerts, cert.Raw)
+	}
+	return id.VerifyCertificate(rawCerts)
+}
+
+// senderHint returns the address that a request says it was
+// sent from in its metadata, or the empty string if it does
+// not say. Anyone can claim any address there, so it only
+// helps to tell apart peers that share a connection identity.
+func senderHint(ctx context.Context) string {
+	md, ok := metadata.FromIncomingContext(ctx)
+	if !ok {
+		return ""
+	}
+	if vals := md.Get(address.SenderMetadataKey); len(vals) > 0 {
+		return vals[0]
+	}
+	return ""
+}
+
+// remoteHost returns the IP address that a request came from,
+// or the empty string if it is not known.
+func remoteHost(ctx context.Context) string {
+	p, ok := grpcpeer.FromContext(ctx)
+	if !ok || p.Addr == nil {
+		return ""
 	}
-	// If addr map is full or does not contain addr of ver, reject
-	newAddr := address.New(in.AddrMe, uint32(time.Now().UnixNano()))
-	if n.AddressDB.Get(newAddr.Addr) != nil {
-		err := n.AddressDB.UpdateLastSeen(newAddr.Addr, newAddr.LastSeen)
+	host, _, err := net.SplitHostPort(p.Addr.String())
+	if err != nil {
+		return ""
+	}
+	return host
+}
+
+// resolveHost returns the IP addresses of the host of addr,
+// for matching requests to the peer at addr by the host they
+// come from.
+func resolveHost(addr string) []string {
+	host, _, err := net.SplitHostPort(addr)
+	if err != nil {
+		return nil
+	}
+	if net.ParseIP(host) != nil {
+		return []string{host}
+	}
+	ips, err := net.LookupHost(host)
+	if err != nil {
+		return nil
+	}
+	return ips
+}
+
+// requestPeer returns the peer that made a request, identified
+// by the connection that the request came over: by the public
+// key that it proved it holds if the transport is secured, and
+// by the host it connected from otherwise. The sender named in
+// the request's metadata is only trusted if it agrees with the
+// connection. If the peer cannot be determined, nil is returned.
+func (n *Node) requestPeer(ctx context.Context) *peer.Peer {
+	hint := n.PeerDb.Get(senderHint(ctx))
+	if n.certificate != nil {
+		pk, err := peerPublicKey(ctx)
 		if err != nil {
-			return &pro.Empty{}, nil
+			return nil
 		}
-	} else if err := n.AddressDB.Add(newAddr); err != nil {
-		return &pro.Empty{}, nil
+		if hint != nil && hint.Addr.PublicKey == pk {
+			return hint
+		}
+		for _, p := range n.PeerDb.List() {
+			if p.Addr.PublicKey == pk {
+				return p
+			}
+		}
+		return nil
+	}
+	host := remoteHost(ctx)
+	if host == "" {
+		return nil
+	}
+	if hint != nil && hint.OnHost(host) {
+		return hint
+	}
+	// without the sender, only a peer that is alone on its host
+	// can be told apart
+	var found *peer.Peer
+	for _, p := range n.PeerDb.List() {
+		if p.OnHost(host) {
+			if found != nil {
+				return nil
+			}
+			found = p
+		}
+	}
+	return found
+}
+
+// requestSender returns the address of the peer that made a
+// request, as identified by requestPeer. If the sender cannot
+// be determined, the empty string is returned.
+func (n *Node) requestSender(ctx context.Context) string {
+	if p := n.requestPeer(ctx); p != nil {
+		return p.Addr.Addr
+	}
+	return ""
+}
+
+// callerLimiter returns the rate limiter that a request is held
+// to: the limiter of the peer that made it, or, for callers that
+// are not peers, a limiter of their own, kept by the public key
+// that they proved they hold if the transport is secured, and
+// by the host they connected from otherwise.
+func (n *Node) callerLimiter(ctx context.Context) *peer.RateLimiter {
+	if p := n.requestPeer(ctx); p != nil && p.Limiter != nil {
+		return p.Limiter
+	}
+	caller := remoteHost(ctx)
+	if n.certificate != nil {
+		if pk, err := peerPublicKey(ctx); err == nil {
+			caller = pk
+		}
+	}
+	n.callerMutex.Lock()
+	defer n.callerMutex.Unlock()
+	if l := n.callerLimits[caller]; l != nil {
+		return l
+	}
+	for len(n.callerOrder) > 0 && len(n.callerOrder) >= n.Config.PeerConfig.MaxUnknownCallers {
+		delete(n.callerLimits, n.callerOrder[0])
+		n.callerOrder = n.callerOrder[1:]
+	}
+	l := peer.NewRateLimiter(n.Config.PeerConfig.RateLimits)
+	n.callerLimits[caller] = l
+	n.callerOrder = append(n.callerOrder, caller)
+	return l
+}
+
+// serverUnaryInterceptor refuses requests from callers that
+// are over their rate limit for the RPC being called, and
+// times the ones it lets through.
+func (n *Node) serverUnaryInterceptor(
+	ctx context.Context,
+	req interface{},
+	info *grpc.UnaryServerInfo,
+	handler grpc.UnaryHandler,
+) (interface{}, error) {
+	method := path.Base(info.FullMethod)
+	if !n.callerLimiter(ctx).Allow(method) {
+		return nil, status.Errorf(codes.ResourceExhausted, "rate limit for %v exceeded", method)
+	}
+	start := time.Now()
+	resp, err := handler(ctx, req)
+	n.stats.rpcServer.With(method).Observe(time.Since(start).Seconds())
+	return resp, err
+}
+
+// Version Handles version request (a request to become a peer).
+// The reply is a verack that says whether the node was added as
+// a peer, which protocol version was agreed on, and what we can
+// do for it.
+func (n *Node) Version(ctx context.Context, in *pro.VersionRequest) (*pro.VersionAck, error) {
+	// Reject nodes on other networks
+	if in.Magic != n.Config.Network.Magic || in.GenesisHash != n.genesisHash {
+		return &pro.VersionAck{}, status.Errorf(codes.FailedPrecondition,
+			"node is not on network %v", n.Config.Network.Name)
+	}
+	// Reject nodes whose version range does not overlap ours
+	version, ok := n.negotiateVersion(in.MinVersion, in.Version)
+	if !ok {
+		return &pro.VersionAck{}, status.Errorf(codes.FailedPrecondition,
+			"no common protocol version: we speak %v to %v, node speaks %v to %v",
+			n.Config.MinVersion, n.Config.Version, in.MinVersion, in.Version)
+	}
+	// Reject nodes that cannot prove they hold the key they advertise
+	if n.certificate != nil {
+		pk, err := peerPublicKey(ctx)
+		if err != nil || pk != in.PublicKey {
+			return &pro.VersionAck{}, status.Error(codes.Unauthenticated, "public key does not match transport identity")
+		}
+	}
+	// Refuse banned nodes
+	if n.BanList.IsBanned(in.AddrMe, in.PublicKey) {
+		return &pro.VersionAck{}, status.Error(codes.PermissionDenied, "node is banned")
+	}
+	// Reject nodes claiming an address that is pinned to another key
+	if pinned := n.Conns.PinnedKey(in.AddrMe); pinned != "" && pinned != in.PublicKey {
+		return &pro.VersionAck{}, status.Error(codes.PermissionDenied, "address is pinned to another public key")
+	}
+	if !utils.ValidAddr(in.AddrMe) {
+		return &pro.VersionAck{}, status.Errorf(codes.InvalidArgument, "invalid address %q", in.AddrMe)
+	}
+	newAddr := n.newAddress(in.AddrMe, uint32(time.Now().Unix()))
+	// The address is only the node's word, so it is bucketed by the
+	// host that the node actually connected from
+	newAddr.Source = remoteHost(ctx)
+	peerAddr := newAddr
+	if in.NoListen {
+		// Nodes that do not listen cannot be reached, so they are not worth remembering
+		newAddr.PublicKey = in.PublicKey
+	} else {
+		// If addr map is full or does not contain addr of ver, reject
+		if n.AddressDB.Get(newAddr.Addr) != nil {
+			err := n.AddressDB.UpdateLastSeen(newAddr.Addr, newAddr.LastSeen)
+			if err != nil {
+				return n.versionAck(version, false, "unable to update address"), nil
+			}
+		} else if err := n.AddressDB.Add(newAddr); err != nil {
+			return n.versionAck(version, false, err.Error()), nil
+		}
+		// the peer keeps its own copy of the address, which
+		// uses our connections, and holds the key the node
+		// proved it has
+		if known := n.AddressDB.Get(newAddr.Addr); known != nil {
+			peerAddr = known
+			peerAddr.Conns = n.Conns
+		}
+		if n.certificate != nil {
+			peerAddr.PublicKey = in.PublicKey
+		}
+	}
+	newPeer := peer.New(peerAddr, version, in.BestHeight)
+	newPeer.NoListen = in.NoListen
+	newPeer.Services = peer.Services(in.Services)
+	newPeer.UserAgent = in.UserAgent
+	newPeer.SetBest(in.BestHeight, in.BestHash)
+	newPeer.Inbound = !n.isOutbound(newPeer.Addr.Addr)
+	newPeer.Limiter = peer.NewRateLimiter(n.Config.PeerConfig.RateLimits)
+	if n.certificate == nil {
+		newPeer.IPs = resolveHost(newPeer.Addr.Addr)
 	}
-	newPeer := peer.New(n.AddressDB.Get(newAddr.Addr), in.Version, in.BestHeight)
 	// Check if we are waiting for a ver in response to a ver, do not respond if this is a confirmation of peering
 	pendingVer := newPeer.Addr.SentVer != time.Time{} && newPeer.Addr.SentVer.Add(n.Config.VersionTimeout).After(time.Now())
-	if n.PeerDb.Add(newPeer) && !pendingVer {
+	added, full := n.admitPeer(newPeer)
+	if full {
+		return n.versionAck(version, false, "inbound limit reached"), nil
+	}
+	if added {
+		if !in.NoListen {
+			n.goroutine(func() { n.pingLoop(newPeer) })
+		}
+	} else if p := n.PeerDb.Get(newPeer.Addr.Addr); p != nil {
+		p.SetBest(in.BestHeight, in.BestHash)
+	} else {
+		return n.versionAck(version, false, "peer limit reached"), nil
+	}
+	if added && !pendingVer && !in.NoListen {
 		newPeer.Addr.SentVer = time.Now()
-		_, err := newAddr.VersionRPC(&pro.VersionRequest{
-			Version:    uint32(n.Config.Version),
-			AddrYou:    in.AddrYou,
-			AddrMe:     n.Address,
-			BestHeight: n.BlockChain.Length,
-		})
+		_, err := newAddr.VersionRPC(n.versionRequest(in.AddrMe))
 		if err != nil {
-			return &pro.Empty{}, err
+			return &pro.VersionAck{}, err
 		}
 	}
-	return &pro.Empty{}, nil
+	// A node only holds the address it claims once dialing the
+	// address proves that the node there holds the node's key
+	if n.certificate != nil && !in.NoListen {
+		if n.Conns.PinnedKey(in.AddrMe) != in.PublicKey {
+			n.Disconnect(in.AddrMe)
+			return n.versionAck(version, false, "address is not held by the public key"), nil
+		}
+		n.rememberKey(in.AddrMe, in.PublicKey)
+	}
+	return n.versionAck(version, true, ""), nil
+}
+
+// versionAck returns the verack that the node replies to
+// a version request with.
+func (n *Node) versionAck(version uint32, accepted bool, reason string) *pro.VersionAck {
+	return &pro.VersionAck{
+		Accepted:   accepted,
+		Version:    version,
+		Services:   uint64(n.Services()),
+		UserAgent:  n.Config.UserAgent,
+		BestHeight: n.BlockChain.Length,
+		BestHash:   n.BlockChain.LastHash,
+		Reason:     reason,
+	}
 }
 
 // GetBlocks Handles get blocks request (request for blocks past a certain block)
@@ -83,8 +356,7 @@ func (n *Node) GetBlocks(ctx context.Context, in *pro.GetBlocksRequest) (*pro.Ge
 func (n *Node) GetData(ctx context.Context, in *pro.GetDataRequest) (*pro.GetDataResponse, error) {
 	blk := n.BlockChain.GetBlock(in.BlockHash)
 	if blk == nil {
-		utils.Debug.Printf("Node {%v} received a data req from the network for a block {%v} that could not be found locally.\n",
-			n.Address, in.BlockHash)
+		n.log.Debug("asked for unknown block", "block", in.BlockHash)
 		return &pro.GetDataResponse{}, nil
 	}
 	return &pro.GetDataResponse{Block: block.EncodeBlock(blk)}, nil
@@ -94,17 +366,27 @@ func (n *Node) GetData(ctx context.Context, in *pro.GetDataRequest) (*pro.GetDat
 func (n *Node) SendAddresses(ctx context.Context, in *pro.Addresses) (*pro.Empty, error) {
 	// Forward nodes to all neighbors if new nodes were found (without redundancy)
 	foundNew := false
+	sender := n.requestSender(ctx)
+	source := sender
+	if source == "" {
+		source = remoteHost(ctx)
+	}
+	if len(in.Addrs) > n.Config.PeerConfig.MaxAddrsPerMessage {
+		n.Misbehaving(sender, peer.MalformedMessage)
+		return &pro.Empty{}, status.Errorf(codes.InvalidArgument,
+			"too many addresses: %v, at most %v", len(in.Addrs), n.Config.PeerConfig.MaxAddrsPerMessage)
+	}
 	for _, addr := range in.Addrs {
-		if addr.Addr == n.Address {
+		if addr.Addr == n.Address || !utils.ValidAddr(addr.Addr) {
 			continue
 		}
-		newAddr := address.New(addr.Addr, addr.LastSeen)
+		newAddr := n.newAddress(addr.Addr, addr.LastSeen)
+		newAddr.Source = source
 		if p := n.PeerDb.Get(addr.Addr); p != nil {
 			if p.Addr.LastSeen < addr.LastSeen {
-				err := n.PeerDb.UpdateLastSeen(addr.Addr, addr.LastSeen)
+				err := n.updateLastSeen(addr.Addr, addr.LastSeen)
 				if err != nil {
-					fmt.Printf("ERROR {Node.SendAddresses}: error" +
-						"when calling updatelastseen.\n")
+					n.log.Warn("unable to update last seen", "peer", addr.Addr, "err", err)
 				}
 				foundNew = true
 			}
@@ -112,8 +394,7 @@ func (n *Node) SendAddresses(ctx context.Context, in *pro.Addresses) (*pro.Empty
 			if a.LastSeen < addr.LastSeen {
 				err := n.AddressDB.UpdateLastSeen(addr.Addr, addr.LastSeen)
 				if err != nil {
-					fmt.Printf("ERROR {Node.SendAddresses}: error" +
-						"when calling updatelastseen.\n")
+					n.log.Warn("unable to update last seen", "peer", addr.Addr, "err", err)
 				}
 			}
 		} else {
@@ -124,25 +405,18 @@ func (n *Node) SendAddresses(ctx context.Context, in *pro.Addresses) (*pro.Empty
 		}
 		// Try to connect to each new address as true peers (it is okay if this is repeated, this may be a reboot)
 		go func() {
-			_, err := newAddr.VersionRPC(&pro.VersionRequest{
-				Version:    uint32(n.Config.Version),
-				AddrYou:    newAddr.Addr,
-				AddrMe:     n.Address,
-				BestHeight: n.BlockChain.Length,
-			})
+			_, err := newAddr.VersionRPC(n.versionRequest(newAddr.Addr))
 			if err != nil {
-				utils.Debug.Printf("%v recieved no response from VersionRPC to %v",
-					utils.FmtAddr(n.Address), utils.FmtAddr(addr.Addr))
+				n.log.Debug("no response to version", "peer", newAddr.Addr, "err", err)
 			}
 		}()
 	}
 	if foundNew {
-		bcPeers := n.PeerDb.GetRandom(2, []string{n.Address})
+		bcPeers := n.PeerDb.GetRandom(2, append([]string{n.Address}, n.unreachablePeers()...))
 		for _, p := range bcPeers {
 			_, err := p.Addr.SendAddressesRPC(in)
 			if err != nil {
-				utils.Debug.Printf("%v recieved no response from SendAddressesRPC to %v",
-					utils.FmtAddr(n.Address), utils.FmtAddr(p.Addr.Addr))
+				n.log.Debug("no response to addresses", "peer", p.Addr.Addr, "err", err)
 			}
 		}
 	}
@@ -151,51 +425,76 @@ func (n *Node) SendAddresses(ctx context.Context, in *pro.Addresses) (*pro.Empty
 
 // Handles get addresses request (request for all known addresses from a specific node)
 func (n *Node) GetAddresses(ctx context.Context, in *pro.Empty) (*pro.Addresses, error) {
-	utils.Debug.Printf("Node {%v} received a GetAddresses req from the network.\n",
-		n.Address)
-	return &pro.Addresses{Addrs: n.AddressDB.Serialize()}, nil
+	n.log.Debug("asked for addresses", "peer", n.requestSender(ctx))
+	addrs := n.AddressDB.Serialize()
+	if len(addrs) > n.Config.PeerConfig.MaxAddrsPerMessage {
+		rand.Shuffle(len(addrs), func(i, j int) { addrs[i], addrs[j] = addrs[j], addrs[i] })
+		addrs = addrs[:n.Config.PeerConfig.MaxAddrsPerMessage]
+	}
+	return &pro.Addresses{Addrs: addrs}, nil
+}
+
+// Ping handles a ping from a node checking that we are
+// still alive. If the node is a peer, it is marked as seen.
+func (n *Node) Ping(ctx context.Context, in *pro.PingRequest) (*pro.PingResponse, error) {
+	if sender := n.requestSender(ctx); sender != "" {
+		_ = n.peerCheck(sender)
+	}
+	return &pro.PingResponse{Nonce: in.Nonce}, nil
 }
 
 // Handles forward transaction request (tx propagation)
 func (n *Node) ForwardTransaction(ctx context.Context, in *pro.Transaction) (*pro.Empty, error) {
 	t := block.DecodeTransaction(in)
-	_, seen := n.SeenTransactions[t.Hash()]
-	if seen {
+	if !n.SeenTransactions.Add(t.Hash()) {
 		return &pro.Empty{}, nil
-	} else {
-		n.SeenTransactions[t.Hash()] = true
 	}
-	if !n.CheckTransaction(t) {
-		utils.Debug.Printf("%v recieved invalid %v", utils.FmtAddr(n.Address), t.NameTag())
+	if err := n.ValidateTransaction(t); err != nil {
+		sender := n.requestSender(ctx)
+		n.log.Debug("received invalid transaction", "transaction", t.Hash(), "peer", sender, "err", err)
+		n.stats.txRejected.With(rejectReason(err)).Inc()
+		if misbehaves(rejectReason(err)) {
+			n.Misbehaving(sender, peer.InvalidTransaction)
+		}
 		return &pro.Empty{}, errors.New("transaction is not valid")
 	}
-	utils.Debug.Printf("%v recieved valid %v", utils.FmtAddr(n.Address), t.NameTag())
+	n.log.Debug("received transaction", "transaction", t.Hash())
+	n.relayTransaction(t)
+	return &pro.Empty{}, nil
+}
+
+// relayTransaction hands a valid transaction to the miner, if
+// the node has one, and forwards it to every reachable peer.
+func (n *Node) relayTransaction(t *block.Transaction) {
 	if n.Config.MinerConfig.HasMiner {
 		n.Miner.HandleTransaction(t)
 	}
-	for _, p := range n.PeerDb.List() {
+	n.stats.txRelayed.Inc()
+	for _, p := range n.reachablePeers() {
 		go func(addr *address.Address) {
 			_, err := addr.ForwardTransactionRPC(block.EncodeTransaction(t))
 			if err != nil {
-				utils.Debug.Printf("%v recieved no response from ForwardTransaction to %v",
-					utils.FmtAddr(n.Address), utils.FmtAddr(p.Addr.Addr))
+				n.log.Debug("no response to transaction", "peer", addr.Addr, "transaction", t.Hash(), "err", err)
 			}
 		}(p.Addr)
 	}
-	return &pro.Empty{}, nil
 }
 
 // ForwardBlock Handles forward block request (block propagation)
 func (n *Node) ForwardBlock(ctx context.Context, in *pro.Block) (*pro.Empty, error) {
 	b := block.DecodeBlock(in)
-	_, seen := n.SeenBlocks[b.Hash()]
-	if seen {
+	if !n.SeenBlocks.Add(b.Hash()) {
 		return &pro.Empty{}, nil
-	} else {
-		n.SeenBlocks[b.Hash()] = true
 	}
-	if !n.CheckBlock(b) {
-		utils.Debug.Printf("%v recieved invalid %v", utils.FmtAddr(n.Address), b.NameTag())
+	if err := n.ValidateBlock(b); err != nil {
+		sender := n.requestSender(ctx)
+		n.log.Debug("received invalid block", "block", b.Hash(), "peer", sender, "err", err)
+		n.stats.blocksRejected.With(rejectReason(err)).Inc()
+		// a peer ahead of the node may send blocks on a
+		// parent the node has not seen yet
+		if rejectReason(err) != RejectOrphan {
+			n.Misbehaving(sender, peer.InvalidBlock)
+		}
 		return &pro.Empty{}, errors.New("block is not valid")
 	}
 	mnChn := n.BlockChain.LastHash == b.Header.PreviousHash && n.BlockChain.CoinDB.ValidateBlock(b.Transactions)
@@ -206,12 +505,12 @@ func (n *Node) ForwardBlock(ctx context.Context, in *pro.Block) (*pro.Empty, err
 	if n.Config.WalletConfig.HasWallet && mnChn {
 		go n.Wallet.HandleBlock(b.Transactions)
 	}
-	for _, p := range n.PeerDb.List() {
+	n.stats.blocksRelayed.Inc()
+	for _, p := range n.reachablePeers() {
 		go func(addr *address.Address) {
 			_, err := addr.ForwardBlockRPC(block.EncodeBlock(b))
 			if err != nil {
-				utils.Debug.Printf("%v recieved no response from ForwardBlockRPC to %v",
-					utils.FmtAddr(n.Address), utils.FmtAddr(p.Addr.Addr))
+				n.log.Debug("no response to block", "peer", addr.Addr, "block", b.Hash(), "err", err)
 			}
 		}(p.Addr)
 	}
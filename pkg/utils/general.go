@@ -1,5 +1,10 @@
 package utils
 
+import (
+	"net"
+	"strconv"
+)
+
 // RevStrArr (ReverseStringArray) reverses
 // the order of an array of strings in place.
 // Inputs:
@@ -57,3 +62,21 @@ func InSlice(a []string, val string) bool {
 	}
 	return false
 }
+
+// ValidAddr returns whether an address is a host and a port
+// that another node could be reached at, such as
+// "example.com:8000", "10.0.0.1:8000" or "[::1]:8000".
+func ValidAddr(addr string) bool {
+	host, port, err := net.SplitHostPort(addr)
+	if err != nil || host == "" {
+		return false
+	}
+	p, err := strconv.Atoi(port)
+	if err != nil || p <= 0 || p > 65535 {
+		return false
+	}
+	if ip := net.ParseIP(host); ip != nil && ip.IsUnspecified() {
+		return false
+	}
+	return true
+}
@@ -1,50 +1,348 @@
 package utils
 
 import (
+	"bytes"
+	"encoding/json"
 	"fmt"
-	"io/ioutil"
-	"log"
+	"io"
+	"net"
 	"os"
+	"sort"
 	"strconv"
 	"strings"
+	"sync"
+	"time"
 )
 
-// Debug is optional logger for debugging
-var Debug *log.Logger
+// Level is how important a log entry is. Entries below the
+// level of their component are dropped.
+type Level int
 
-// Out is logger to Stdout
-var Out *log.Logger
+const (
+	LevelDebug Level = iota
+	LevelInfo
+	LevelWarn
+	LevelError
+	LevelOff
+)
+
+var levelNames = []string{"debug", "info", "warn", "error", "off"}
+
+func (l Level) String() string {
+	if l < LevelDebug || l > LevelOff {
+		return strconv.Itoa(int(l))
+	}
+	return levelNames[l]
+}
+
+// ParseLevel returns the level with the given name, such as
+// "debug" or "warn".
+func ParseLevel(s string) (Level, error) {
+	for i, name := range levelNames {
+		if strings.EqualFold(s, name) {
+			return Level(i), nil
+		}
+	}
+	return 0, fmt.Errorf("unknown log level %q, want one of %v", s, strings.Join(levelNames, ", "))
+}
+
+// The components that log entries come from.
+const (
+	ComponentNode   = "node"
+	ComponentChain  = "chain"
+	ComponentCoinDB = "coindb"
+	ComponentMiner  = "miner"
+	ComponentWallet = "wallet"
+	ComponentP2P    = "p2p"
+	ComponentRPC    = "rpc"
+)
+
+// Components lists every component, for validating
+// per-component levels.
+var Components = []string{
+	ComponentNode, ComponentChain, ComponentCoinDB, ComponentMiner,
+	ComponentWallet, ComponentP2P, ComponentRPC,
+}
+
+// The formats that log entries can be written in.
+const (
+	LogFormatText = "text"
+	LogFormatJSON = "json"
+)
+
+// LogConfig is the configuration of logging.
+// Format is LogFormatText, which writes each entry as a line
+// of key=value pairs, or LogFormatJSON, which writes each
+// entry as a JSON object.
+// Level is the lowest level that is logged, for components
+// that are not in Levels.
+// Levels are the lowest levels that are logged, by component.
+// Color is whether text entries have their level colored,
+// and whether FmtAddr and Colorize use colors.
+// Output is where entries are written.
+type LogConfig struct {
+	Format string
+	Level  Level
+	Levels map[string]Level
+	Color  bool
+	Output io.Writer
+}
+
+// DefaultLogConfig returns the default configuration of
+// logging, which writes info and above as text to stderr.
+func DefaultLogConfig() *LogConfig {
+	return &LogConfig{
+		Format: LogFormatText,
+		Level:  LevelInfo,
+		Levels: make(map[string]Level),
+		Output: os.Stderr,
+	}
+}
 
-// Err is logger to Stderr
-var Err *log.Logger
+// logging is the configuration that every Logger writes with.
+var logging = struct {
+	config *LogConfig
+	mutex  sync.RWMutex
+	write  sync.Mutex
+}{config: DefaultLogConfig()}
+
+// ConfigureLogging sets the configuration of logging. It
+// applies to every Logger, including ones made before.
+func ConfigureLogging(c *LogConfig) {
+	logging.mutex.Lock()
+	logging.config = c
+	logging.mutex.Unlock()
+}
+
+// logConfig returns the configuration of logging.
+func logConfig() *LogConfig {
+	logging.mutex.RLock()
+	defer logging.mutex.RUnlock()
+	return logging.config
+}
+
+// Logger writes structured log entries for a component.
+// component is the component the entries come from.
+// fields are key value pairs added to every entry.
+type Logger struct {
+	component string
+	fields    []interface{}
+}
+
+// NewLogger returns a Logger for a component.
+func NewLogger(component string) *Logger {
+	return &Logger{component: component}
+}
+
+// With returns a Logger that adds the given key value pairs
+// to every entry, such as With("node", addr).
+func (l *Logger) With(kv ...interface{}) *Logger {
+	fields := make([]interface{}, 0, len(l.fields)+len(kv))
+	fields = append(append(fields, l.fields...), kv...)
+	return &Logger{component: l.component, fields: fields}
+}
+
+// Enabled returns whether entries of a level are logged.
+func (l *Logger) Enabled(level Level) bool {
+	c := logConfig()
+	min, ok := c.Levels[l.component]
+	if !ok {
+		min = c.Level
+	}
+	return level >= min && level < LevelOff
+}
+
+// Debug logs a message, with key value pairs, for debugging.
+func (l *Logger) Debug(msg string, kv ...interface{}) {
+	l.log(LevelDebug, msg, kv)
+}
+
+// Info logs a message, with key value pairs, about the normal
+// running of the node.
+func (l *Logger) Info(msg string, kv ...interface{}) {
+	l.log(LevelInfo, msg, kv)
+}
+
+// Warn logs a message, with key value pairs, about something
+// that went wrong but that the node recovers from.
+func (l *Logger) Warn(msg string, kv ...interface{}) {
+	l.log(LevelWarn, msg, kv)
+}
 
-// init initializes the loggers.
-func init() {
-	Debug = log.New(ioutil.Discard, "DEBUG: ", 0)
-	Out = log.New(os.Stdout, "INFO: ", log.Ltime|log.Lshortfile)
-	Err = log.New(os.Stderr, "ERROR: ", log.Ltime|log.Lshortfile)
+// Error logs a message, with key value pairs, about something
+// that the node cannot do.
+func (l *Logger) Error(msg string, kv ...interface{}) {
+	l.log(LevelError, msg, kv)
 }
 
-// SetDebug turns debug print statements on or off.
-func SetDebug(enabled bool) {
-	if enabled {
-		Debug.SetOutput(os.Stdout)
+// log writes an entry, if its level is enabled.
+func (l *Logger) log(level Level, msg string, kv []interface{}) {
+	if !l.Enabled(level) {
+		return
+	}
+	c := logConfig()
+	fields := append(append([]interface{}{}, l.fields...), kv...)
+	if len(fields)%2 != 0 {
+		fields = append(fields, "(missing)")
+	}
+	var b bytes.Buffer
+	now := time.Now().UTC().Format(time.RFC3339Nano)
+	if c.Format == LogFormatJSON {
+		writeJSONEntry(&b, now, level, l.component, msg, fields)
 	} else {
-		Debug.SetOutput(ioutil.Discard)
+		writeTextEntry(&b, now, level, l.component, msg, fields, c.Color)
+	}
+	logging.write.Lock()
+	_, _ = c.Output.Write(b.Bytes())
+	logging.write.Unlock()
+}
+
+// levelColors are the ANSI colors of the levels in text
+// entries.
+var levelColors = []string{"\033[36m", "\033[32m", "\033[33m", "\033[31m"}
+
+// writeTextEntry writes an entry as a line of key=value pairs.
+func writeTextEntry(b *bytes.Buffer, now string, level Level, component, msg string, fields []interface{}, color bool) {
+	levelName := level.String()
+	if color {
+		levelName = levelColors[level] + levelName + "\033[0m"
+	}
+	fmt.Fprintf(b, "time=%v level=%v component=%v msg=%v", now, levelName, component, quoteText(msg))
+	for i := 0; i < len(fields); i += 2 {
+		fmt.Fprintf(b, " %v=%v", fieldKey(fields[i]), quoteText(fieldString(fields[i+1])))
+	}
+	b.WriteByte('\n')
+}
+
+// writeJSONEntry writes an entry as a JSON object on one line.
+func writeJSONEntry(b *bytes.Buffer, now string, level Level, component, msg string, fields []interface{}) {
+	fmt.Fprintf(b, `{"time":%v,"level":%v,"component":%v,"msg":%v`,
+		jsonValue(now), jsonValue(level.String()), jsonValue(component), jsonValue(msg))
+	for i := 0; i < len(fields); i += 2 {
+		fmt.Fprintf(b, ",%v:%v", jsonValue(fieldKey(fields[i])), jsonValue(fields[i+1]))
+	}
+	b.WriteString("}\n")
+}
+
+// fieldKey returns a key of a key value pair as a string.
+func fieldKey(k interface{}) string {
+	if s, ok := k.(string); ok {
+		return s
+	}
+	return fmt.Sprint(k)
+}
+
+// fieldString returns a value of a key value pair as a string.
+func fieldString(v interface{}) string {
+	switch v := v.(type) {
+	case string:
+		return v
+	case error:
+		return v.Error()
+	case fmt.Stringer:
+		return v.String()
+	default:
+		return fmt.Sprint(v)
+	}
+}
+
+// quoteText quotes a text value if it is empty or holds
+// spaces, quotes or equals signs, so that lines can be split
+// into pairs.
+func quoteText(s string) string {
+	if s == "" || strings.ContainsAny(s, " \t\n\"=") {
+		return strconv.Quote(s)
 	}
+	return s
 }
 
+// jsonValue returns a value of a key value pair as JSON.
+// Errors and Stringers are written as strings.
+func jsonValue(v interface{}) string {
+	switch v.(type) {
+	case error, fmt.Stringer:
+		v = fieldString(v)
+	}
+	data, err := json.Marshal(v)
+	if err != nil {
+		data, _ = json.Marshal(fmt.Sprint(v))
+	}
+	return string(data)
+}
+
+// ParseLevels parses per-component levels given as a comma
+// separated list of component=level pairs, such as
+// "p2p=debug,chain=warn".
+func ParseLevels(s string) (map[string]Level, error) {
+	levels := make(map[string]Level)
+	if strings.TrimSpace(s) == "" {
+		return levels, nil
+	}
+	for _, pair := range strings.Split(s, ",") {
+		parts := strings.SplitN(strings.TrimSpace(pair), "=", 2)
+		if len(parts) != 2 {
+			return nil, fmt.Errorf("log level %q is not component=level", pair)
+		}
+		if !isComponent(parts[0]) {
+			return nil, fmt.Errorf("unknown log component %q, want one of %v", parts[0], strings.Join(Components, ", "))
+		}
+		level, err := ParseLevel(parts[1])
+		if err != nil {
+			return nil, err
+		}
+		levels[parts[0]] = level
+	}
+	return levels, nil
+}
+
+// FormatLevels formats per-component levels the way
+// ParseLevels reads them.
+func FormatLevels(levels map[string]Level) string {
+	pairs := make([]string, 0, len(levels))
+	for component, level := range levels {
+		pairs = append(pairs, component+"="+level.String())
+	}
+	sort.Strings(pairs)
+	return strings.Join(pairs, ",")
+}
+
+func isComponent(s string) bool {
+	for _, c := range Components {
+		if c == s {
+			return true
+		}
+	}
+	return false
+}
+
+// FmtAddr formats an address for debugging output. If colors
+// are on, it is colored by its port. It accepts any address,
+// including IPv6 addresses and addresses without a port.
 func FmtAddr(addr string) string {
 	if addr == "" {
 		return ""
 	}
+	if !logConfig().Color {
+		return fmt.Sprintf("[%v]", addr)
+	}
 	colors := []string{"\033[41m", "\033[42m", "\033[43m", "\033[44m", "\033[45m", "\033[46m", "\033[47m"}
-	port, _ := strconv.ParseInt(strings.Split(addr, ":")[1], 10, 64)
-	randomColor := colors[int(port)%len(colors)]
+	// addresses without a port, or with one we cannot parse, all get the first color
+	port := 0
+	if _, p, err := net.SplitHostPort(addr); err == nil {
+		port, _ = strconv.Atoi(p)
+	}
+	if port < 0 {
+		port = 0
+	}
+	randomColor := colors[port%len(colors)]
 	return fmt.Sprintf("%v\033[97m[%v]\033[0m", randomColor, addr)
 }
 
+// Colorize colors s by a seed, if colors are on.
 func Colorize(s string, seed int) string {
+	if !logConfig().Color {
+		return s
+	}
 	lowestColor, highestColor := 104, 226
 	return fmt.Sprintf("\033[38;5;%vm%v\033[0m", seed%(highestColor-lowestColor)+lowestColor, s)
 }
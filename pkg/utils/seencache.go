@@ -0,0 +1,94 @@
+package utils
+
+import (
+	"container/list"
+	"sync"
+	"time"
+)
+
+// SeenCache remembers which objects, such as transactions
+// and blocks, were seen recently. It is safe to use from
+// multiple goroutines. It holds at most capacity objects,
+// forgetting the least recently seen one when full, and
+// forgets objects that were seen longer than ttl ago.
+// order is a list of entries, most recently seen first.
+// entries maps the key of an object to its element in order.
+type SeenCache struct {
+	capacity int
+	ttl      time.Duration
+	order    *list.List
+	entries  map[string]*list.Element
+
+	mutex sync.Mutex
+}
+
+// seenEntry is an object in a SeenCache.
+type seenEntry struct {
+	key  string
+	seen time.Time
+}
+
+// NewSeenCache returns an empty SeenCache. If ttl is 0,
+// objects are only forgotten when the cache is full.
+func NewSeenCache(capacity int, ttl time.Duration) *SeenCache {
+	return &SeenCache{
+		capacity: capacity,
+		ttl:      ttl,
+		order:    list.New(),
+		entries:  make(map[string]*list.Element),
+	}
+}
+
+// Add marks an object as seen. It returns true if the object
+// was not seen before, which lets callers check and mark an
+// object in one step.
+func (c *SeenCache) Add(key string) bool {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	now := time.Now()
+	c.expire(now)
+	if e, ok := c.entries[key]; ok {
+		e.Value.(*seenEntry).seen = now
+		c.order.MoveToFront(e)
+		return false
+	}
+	c.entries[key] = c.order.PushFront(&seenEntry{key: key, seen: now})
+	for c.order.Len() > c.capacity {
+		c.remove(c.order.Back())
+	}
+	return true
+}
+
+// Contains returns whether an object was seen recently.
+func (c *SeenCache) Contains(key string) bool {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	c.expire(time.Now())
+	_, ok := c.entries[key]
+	return ok
+}
+
+// Len returns the number of objects in the cache.
+func (c *SeenCache) Len() int {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	c.expire(time.Now())
+	return c.order.Len()
+}
+
+// expire forgets the objects that were seen longer than
+// ttl ago. The caller must hold the mutex.
+func (c *SeenCache) expire(now time.Time) {
+	if c.ttl == 0 {
+		return
+	}
+	for e := c.order.Back(); e != nil && now.Sub(e.Value.(*seenEntry).seen) > c.ttl; e = c.order.Back() {
+		c.remove(e)
+	}
+}
+
+// remove forgets an object. The caller must hold the mutex.
+func (c *SeenCache) remove(e *list.Element) {
+	c.order.Remove(e)
+	delete(c.entries, e.Value.(*seenEntry).key)
+}
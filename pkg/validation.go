@@ -2,6 +2,9 @@ package pkg
 
 import (
 	"Coin/pkg/block"
+	"Coin/pkg/blockchain/coindatabase"
+	"Coin/pkg/utils"
+	"errors"
 	"fmt"
 )
 
@@ -16,8 +19,7 @@ import (
 // otherwise
 func CheckBlockSyntax(b *block.Block) bool {
 	if b.Transactions == nil || len(b.Transactions) == 0 {
-		fmt.Printf("{Validation.ChkBlkSyn} ERROR: transactions were" +
-			" either nil or had zero length.\n")
+		utils.NewLogger(utils.ComponentChain).Debug("block has no transactions", "func", "CheckBlockSyntax")
 		return false
 	}
 	return b.Transactions[0].IsCoinbase() && b.Transactions[0].SumOutputs() > 0
@@ -69,9 +71,22 @@ func (n *Node) CheckBlockConfiguration(b *block.Block) bool {
 // bool True if the block is valid. false
 // otherwise
 func (n *Node) CheckBlock(b *block.Block) bool {
+	return n.ValidateBlock(b) == nil
+}
+
+// ValidateBlock validates a block like CheckBlock, but
+// says why an invalid block is invalid. A block on a
+// previous block the node does not know is an orphan, and
+// no two inputs of a block may spend the same coin.
+// Inputs:
+// b *block.Block the block to be checked for validity
+// Returns:
+// error a *RejectError for the first check the block
+// failed, or nil if it is valid
+func (n *Node) ValidateBlock(b *block.Block) error {
 	if b == nil {
-		fmt.Printf("{Validation.ChkBlk} ERROR: block was nil.\n")
-		return false
+		n.logger(utils.ComponentChain).Debug("received a nil block", "func", "Node.ValidateBlock")
+		return reject(RejectMalformed, "block is nil")
 	}
 	//if !(CheckBlockSyntax(b) && CheckBlockSemantics(b) && n.CheckBlockConfiguration(b)) {
 	//	return false
@@ -82,7 +97,30 @@ func (n *Node) CheckBlock(b *block.Block) bool {
 	//		return false
 	//	}
 	//}
-	return n.BlockChain.CoinDB.ValidateBlock(b.Transactions)
+	if !n.BlockChain.BlockInfoDB.HasBlockRecord(b.Header.PreviousHash) {
+		return reject(RejectOrphan, "previous block %v is unknown", b.Header.PreviousHash)
+	}
+	height := n.BlockChain.BlockInfoDB.GetBlockRecord(b.Header.PreviousHash).Height + 1
+	spent := make(map[coindatabase.CoinLocator]bool)
+	for i, tx := range b.Transactions {
+		if !tx.IsCoinbase() && !IsFinal(tx, height) {
+			return reject(RejectNonFinal, "transaction %v is locked until height %v", i, tx.LockTime)
+		}
+		for j, txi := range tx.Inputs {
+			key := coindatabase.CoinLocator{
+				ReferenceTransactionHash: txi.ReferenceTransactionHash,
+				OutputIndex:              txi.OutputIndex,
+			}
+			if spent[key] {
+				return reject(RejectDoubleSpend, "input %v of transaction %v spends a coin that the block already spends", j, i)
+			}
+			spent[key] = true
+		}
+		if err := n.BlockChain.CoinDB.ValidateTransaction(tx); err != nil {
+			return reject(inputsReason(err), "transaction %v: %v", i, err)
+		}
+	}
+	return nil
 }
 
 // CheckTransactionSyntax validates a transaction
@@ -108,15 +146,16 @@ func CheckTransactionSyntax(t *block.Transaction) bool {
 // CheckTransactionSemantics validates a
 // a transaction semantically.
 // To be valid:
-// The sum of the transaction's inputs must be larger
-// than the sum of the transaction's outputs.
+// The sum of the transaction's inputs must be at least
+// the sum of the transaction's outputs. The difference
+// is the fee.
 // Inputs:
 // t *block.Transaction the transaction to be checked for validity
 // Returns:
 // bool True if the transaction is semantically valid. false
 // otherwise
 func (n *Node) CheckTransactionSemantics(tx *block.Transaction) bool {
-	return false
+	return n.BlockChain.GetInputSums([]*block.Transaction{tx})[0] >= tx.SumOutputs()
 }
 
 // CheckNonOrphanSemantically validates
@@ -133,18 +172,20 @@ func (n *Node) CheckTransactionSemantics(tx *block.Transaction) bool {
 // bool True if the transaction is semantically valid. false
 // otherwise
 func (n *Node) CheckNonOrphanSemantically(t *block.Transaction) bool {
-	//for _, inp := range t.Inputs {
-	//	if n.Chain.IsInvalidInput(inp) {
-	//		return false
-	//	}
-	//}
-	//for _, inp := range t.Inputs {
-	//	utxo := n.Chain.GetUTXO(inp)
-	//	if utxo == nil || !utxo.IsUnlckd(inp.UnlockingScript) {
-	//		return false
-	//	}
-	//}
-	return true
+	return n.BlockChain.CoinDB.ValidateTransaction(t) == nil
+}
+
+// IsFinal returns whether a transaction may be in a block
+// at a height: whether its LockTime is no later than the
+// height.
+// Inputs:
+// t *block.Transaction the transaction to be checked
+// height uint32 the height of the block that it would be in
+// Returns:
+// bool True if the transaction may be in the block. false
+// otherwise
+func IsFinal(t *block.Transaction, height uint32) bool {
+	return t.LockTime <= height
 }
 
 // CheckTransactionConfiguration validates
@@ -173,9 +214,110 @@ func (n *Node) CheckTransactionConfiguration(t *block.Transaction) bool {
 // bool True if the transaction is syntactically valid. false
 // otherwise
 func (n *Node) CheckTransaction(t *block.Transaction) bool {
-	valid := CheckTransactionSyntax(t) && n.CheckTransactionSemantics(t) && n.CheckTransactionConfiguration(t)
+	return n.ValidateTransaction(t) == nil
+}
+
+// ValidateTransaction validates a transaction like
+// CheckTransaction, but says why an invalid
+// transaction is invalid. Its inputs must also be
+// unspent coins on the main chain, each spent once
+// and signed for by its owner.
+// Inputs:
+// t *block.Transaction the transaction to be checked for validity
+// Returns:
+// error a *RejectError for the first check the
+// transaction failed, or nil if it is valid
+func (n *Node) ValidateTransaction(t *block.Transaction) error {
+	if t == nil {
+		return reject(RejectMalformed, "transaction is nil")
+	}
+	if !CheckTransactionSyntax(t) {
+		return reject(RejectMalformed, "transaction must have inputs and outputs, and every output an amount")
+	}
+	if !n.CheckTransactionConfiguration(t) {
+		return reject(RejectTooLarge, "transaction is larger than %v bytes", n.Config.MaxBlockSize)
+	}
+	spent := make(map[coindatabase.CoinLocator]bool)
+	for i, txi := range t.Inputs {
+		key := coindatabase.CoinLocator{
+			ReferenceTransactionHash: txi.ReferenceTransactionHash,
+			OutputIndex:              txi.OutputIndex,
+		}
+		if spent[key] {
+			return reject(RejectDoubleSpend, "input %v spends a coin that an earlier input spends", i)
+		}
+		spent[key] = true
+	}
+	if height := n.BlockChain.Length + 1; !IsFinal(t, height) {
+		return reject(RejectNonFinal, "transaction is locked until height %v, and the next block is at %v", t.LockTime, height)
+	}
 	if err := n.BlockChain.CoinDB.ValidateTransaction(t); err != nil {
-		return false
+		return reject(inputsReason(err), "%v", err)
+	}
+	if !n.CheckTransactionSemantics(t) {
+		return reject(RejectOverspend, "transaction spends more than its inputs are worth")
+	}
+	return nil
+}
+
+// Reasons that a transaction or block is rejected for.
+const (
+	RejectMalformed   = "malformed"
+	RejectTooLarge    = "too_large"
+	RejectDoubleSpend = "double_spend"
+	RejectBadInputs   = "bad_inputs"
+	RejectOverspend   = "overspend"
+	RejectNonFinal    = "non_final"
+	RejectBadSig      = "bad_signature"
+	RejectOrphan      = "orphan"
+)
+
+// inputsReason returns the reason to reject a transaction
+// for, given why the coin database found its inputs invalid.
+func inputsReason(err error) string {
+	if errors.Is(err, coindatabase.ErrLocked) {
+		return RejectBadSig
+	}
+	return RejectBadInputs
+}
+
+// misbehaves returns whether a transaction rejected for a reason
+// could only have come from a misbehaving peer. Honest peers relay
+// transactions whose inputs the node does not know yet, such as a
+// child before its parent, or that a new block just spent.
+func misbehaves(reason string) bool {
+	switch reason {
+	case RejectMalformed, RejectBadSig, RejectOverspend:
+		return true
+	}
+	return false
+}
+
+// RejectError is why a transaction or block was found
+// invalid.
+// Reason is one of the Reject constants, which groups
+// rejections for metrics.
+// msg describes the rejection.
+type RejectError struct {
+	Reason string
+	msg    string
+}
+
+func (e *RejectError) Error() string {
+	return e.msg
+}
+
+// reject returns a *RejectError for a reason.
+func reject(reason string, format string, a ...interface{}) error {
+	return &RejectError{Reason: reason, msg: fmt.Sprintf(format, a...)}
+}
+
+// rejectReason returns the reason of a rejection, for errors
+// that come from ValidateTransaction or ValidateBlock.
+func rejectReason(err error) string {
+	var rerr *RejectError
+	if errors.As(err, &rerr) {
+		return rerr.Reason
 	}
-	return valid
+	return "unknown"
 }
@@ -4,6 +4,7 @@ import (
 	"Coin/pkg/block"
 	"Coin/pkg/blockchain/chainwriter"
 	"Coin/pkg/id"
+	"Coin/pkg/utils"
 )
 
 // CoinInfo holds the information about a TransactionOutput
@@ -34,6 +35,8 @@ type CoinInfo struct {
 // UnconfirmedReceivedCoins is a mapping of CoinInfos to number of confirmations
 // (which are integers). We can't confirm we've received a Coin until
 // we've seen enough POW on top the block containing our received transaction.
+//
+// log is the wallet's logger, which tags entries with Address.
 type Wallet struct {
 	Config              *Config
 	Id                  id.ID
@@ -50,12 +53,15 @@ type Wallet struct {
 	// Seen but not confirmed
 	UnconfirmedSpentCoins    map[*CoinInfo]uint32
 	UnconfirmedReceivedCoins map[*CoinInfo]uint32
+
+	log *utils.Logger
 }
 
 // SetAddress sets the address
 // of the node in the wallet.
 func (w *Wallet) SetAddress(a string) {
 	w.Address = a
+	w.log = utils.NewLogger(utils.ComponentWallet).With("node", a)
 }
 
 // New creates a wallet object
@@ -72,6 +78,7 @@ func New(config *Config, id id.ID) *Wallet {
 		UnseenSpentCoins:         make(map[string][]*CoinInfo),
 		UnconfirmedSpentCoins:    make(map[*CoinInfo]uint32),
 		UnconfirmedReceivedCoins: make(map[*CoinInfo]uint32),
+		log:                      utils.NewLogger(utils.ComponentWallet),
 	}
 }
 
@@ -0,0 +1,216 @@
+package test
+
+import (
+	"Coin/pkg"
+	"Coin/pkg/address"
+	"Coin/pkg/address/addressdb"
+	"Coin/pkg/blockchain"
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestAddressDbSurvivesRestart(t *testing.T) {
+	// set up cluster
+	cluster := NewCluster(2)
+	chains := []*blockchain.BlockChain{cluster[0].BlockChain, cluster[1].BlockChain}
+	StartCluster(cluster)
+	cluster[0].ConnectToPeer(cluster[1].Address)
+
+	a := cluster[0].AddressDB.Get(cluster[1].Address)
+	if a == nil || a.Successes != 1 {
+		t.Fatalf("node 0 should have recorded a successful connection to node 1")
+	}
+	cluster[0].Kill()
+
+	// restart node 0 with its address database, but a fresh chain
+	conf := setNodeConfig(pkg.DefaultConfig(GetFreePort()), 2)
+	conf.AddressDBPath = cluster[0].Config.AddressDBPath
+	restarted := pkg.New(conf)
+	chains = append(chains, restarted.BlockChain)
+	defer CleanUp(chains)
+	if restarted.AddressDB.Get(cluster[1].Address) == nil {
+		t.Fatalf("restarted node should have loaded node 1's address")
+	}
+	restarted.Start()
+
+	// the restarted node should reconnect without being told to
+	time.Sleep(500 * time.Millisecond)
+	if restarted.PeerDb.Get(cluster[1].Address) == nil {
+		t.Errorf("restarted node should have reconnected to node 1")
+	}
+	if restarted.Conns.PinnedKey(cluster[1].Address) == "" {
+		t.Errorf("restarted node should have kept node 1's pinned key")
+	}
+}
+
+func TestConnectRecordsFailedAttempt(t *testing.T) {
+	n := pkg.New(setNodeConfig(GenesisConfig(GetFreePort()), 0))
+	defer CleanUp([]*blockchain.BlockChain{n.BlockChain})
+	n.Start()
+
+	// nothing listens at the address, which the node did not know
+	unreachable := fmt.Sprintf("127.0.0.1:%v", GetFreePort())
+	if n.ConnectToPeer(unreachable) {
+		t.Fatalf("node should not have peered with an unreachable address")
+	}
+	a := n.AddressDB.Get(unreachable)
+	if a == nil || a.Attempts != 1 || a.Successes != 0 {
+		t.Errorf("node should have recorded a failed attempt to connect to the address, got %+v", a)
+	}
+}
+
+func TestAddrManagerLimitsSingleSource(t *testing.T) {
+	am := addressdb.New(true, 10000, "")
+
+	// an attacker announces many addresses from one network group
+	for i := 0; i < 2000; i++ {
+		a := address.New(fmt.Sprintf("10.%v.%v.1:8000", i/250, i%250), 0)
+		a.Source = "10.0.0.1:8000"
+		_ = am.Add(a)
+	}
+	max := addressdb.NewBucketsPerSourceGroup * addressdb.BucketSize
+	if n := len(am.List()); n > max {
+		t.Fatalf("a single source should fill at most %v slots, filled %v", max, n)
+	}
+
+	// addresses from an honest source still get in
+	honest := address.New("192.168.1.1:8000", 0)
+	honest.Source = "172.16.0.1:8000"
+	if err := am.Add(honest); err != nil {
+		t.Fatalf("honest address should have been added: %v", err)
+	}
+	if err := am.RecordAttempt(honest.Addr, true); err != nil {
+		t.Fatalf("recording attempt failed: %v", err)
+	}
+	if am.(*addressdb.AddrManager).TriedCount() != 1 {
+		t.Errorf("successful connection should move the address to the tried table")
+	}
+}
+
+func TestAddrManagerKeepsGoodAddresses(t *testing.T) {
+	am := addressdb.New(true, 10000, "")
+
+	// addresses from one group, told by one source, share a bucket
+	addrs := make([]string, addressdb.BucketSize)
+	for i := range addrs {
+		addrs[i] = fmt.Sprintf("10.0.0.%v:8000", i+1)
+		a := address.New(addrs[i], uint32(time.Now().Unix()))
+		a.Source = "10.1.0.1:8000"
+		if err := am.Add(a); err != nil {
+			t.Fatalf("address should have been added: %v", err)
+		}
+	}
+
+	// a full bucket of good addresses turns away new ones
+	late := address.New("10.0.1.1:8000", uint32(time.Now().Unix()))
+	late.Source = "10.1.0.1:8000"
+	if err := am.Add(late); err == nil {
+		t.Errorf("a full bucket should not have evicted a good address")
+	}
+	for _, addr := range addrs {
+		if am.Get(addr) == nil {
+			t.Errorf("good address %v should have been kept", addr)
+		}
+	}
+
+	// but makes room by evicting a stale one
+	for i := 0; i < addressdb.MaxNewFailures; i++ {
+		_ = am.RecordAttempt(addrs[0], false)
+	}
+	if err := am.Add(late); err != nil {
+		t.Errorf("the stale address should have been evicted to make room, got %v", err)
+	}
+	if am.Get(addrs[0]) != nil {
+		t.Errorf("the stale address should have been evicted")
+	}
+}
+
+func TestVersionAddressSource(t *testing.T) {
+	// set up cluster
+	cluster := NewCluster(2)
+	chains := []*blockchain.BlockChain{cluster[0].BlockChain, cluster[1].BlockChain}
+	defer CleanUp(chains)
+	StartCluster(cluster)
+	cluster[0].ConnectToPeer(cluster[1].Address)
+
+	// node 1 learned node 0's address from node 0 itself, so it
+	// is bucketed by the host node 0 connected from
+	a := cluster[1].AddressDB.Get(cluster[0].Address)
+	if a == nil {
+		t.Fatalf("node 1 should have remembered node 0's address")
+	}
+	if addressdb.NetworkGroup(a.Source) != "127.0" {
+		t.Errorf("the address should have come from the host node 0 connected from, got %q", a.Source)
+	}
+}
+
+func TestStaleAddressesEvicted(t *testing.T) {
+	conf := setNodeConfig(GenesisConfig(GetFreePort()), 0)
+	conf.ConnectInterval = 20 * time.Millisecond
+	conf.DiscoveryInterval = 50 * time.Millisecond
+	conf.AddressConfig.MinBackoff = time.Millisecond
+	conf.AddressConfig.MaxBackoff = time.Millisecond
+	n := pkg.New(conf)
+	defer CleanUp([]*blockchain.BlockChain{n.BlockChain})
+	unreachable := fmt.Sprintf("127.0.0.1:%v", GetFreePort())
+	if err := n.AddressDB.Add(address.New(unreachable, uint32(time.Now().Unix()))); err != nil {
+		t.Fatal(err)
+	}
+	n.Start()
+	defer n.Kill()
+
+	// the node keeps failing to connect, until it forgets the address
+	time.Sleep(time.Second)
+	if n.AddressDB.Get(unreachable) != nil {
+		t.Errorf("the unreachable address should have gone stale and been evicted")
+	}
+}
+
+func TestAddressDbReturnsCopies(t *testing.T) {
+	for name, adb := range map[string]addressdb.AddressDb{
+		"manager":   addressdb.New(true, 100, ""),
+		"ephemeral": addressdb.NewEphemeralAddressDb(100),
+	} {
+		if err := adb.Add(address.New("10.0.0.1:8000", 1)); err != nil {
+			t.Fatal(err)
+		}
+
+		// changing an address that was handed out does not
+		// change the database's
+		a := adb.Get("10.0.0.1:8000")
+		a.LastSeen = 2
+		a.PublicKey = "key"
+		if got := adb.Get("10.0.0.1:8000"); got.LastSeen != 1 || got.PublicKey != "" {
+			t.Errorf("%v: changing a returned address should not have changed the stored one, got %+v", name, got)
+		}
+
+		// the database changes its addresses under its lock while
+		// they are read elsewhere
+		var wg sync.WaitGroup
+		wg.Add(2)
+		go func() {
+			defer wg.Done()
+			for i := uint32(0); i < 100; i++ {
+				_ = adb.UpdateLastSeen("10.0.0.1:8000", i)
+				_ = adb.RecordAttempt("10.0.0.1:8000", i%2 == 0)
+			}
+		}()
+		go func() {
+			defer wg.Done()
+			for i := 0; i < 100; i++ {
+				if a := adb.Get("10.0.0.1:8000"); a != nil {
+					_ = a.LastSeen + a.Attempts
+				}
+				for _, a := range adb.List() {
+					_ = a.Successes
+				}
+			}
+		}()
+		wg.Wait()
+		if got := adb.Get("10.0.0.1:8000"); got.Attempts != 100 || got.Successes != 50 {
+			t.Errorf("%v: every attempt should have been recorded, got %+v", name, got)
+		}
+	}
+}
@@ -0,0 +1,167 @@
+package test
+
+import (
+	"Coin/pkg/blockchain"
+	"Coin/pkg/pro"
+	"context"
+	"fmt"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+	"testing"
+)
+
+func TestAdminService(t *testing.T) {
+	// set up cluster
+	cluster := NewCluster(2)
+	chains := []*blockchain.BlockChain{cluster[0].BlockChain, cluster[1].BlockChain}
+	defer CleanUp(chains)
+	cluster[0].Config.AdminAddr = fmt.Sprintf("127.0.0.1:%v", GetFreePort())
+	StartCluster(cluster)
+
+	conn, err := grpc.Dial(cluster[0].Config.AdminAddr, grpc.WithInsecure())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+	admin := pro.NewAdminClient(conn)
+	ctx := context.Background()
+
+	count, err := admin.GetBlockCount(ctx, &pro.Empty{})
+	if err != nil || count.Count != 1 {
+		t.Fatalf("chain should have 1 block, got %v (%v)", count, err)
+	}
+	info, err := admin.GetBlock(ctx, &pro.GetBlockRequest{Height: 1})
+	if err != nil || info.Hash != cluster[0].BlockChain.LastHash {
+		t.Fatalf("block at height 1 should be the genesis block, got %v (%v)", info, err)
+	}
+	if _, err := admin.GetBlock(ctx, &pro.GetBlockRequest{Hash: "nope"}); status.Code(err) != codes.NotFound {
+		t.Errorf("unknown blocks should not be found, got %v", err)
+	}
+
+	if _, err := admin.AddPeer(ctx, &pro.AddPeerRequest{Addr: cluster[1].Address}); err != nil {
+		t.Fatalf("node 0 should have peered with node 1: %v", err)
+	}
+	peers, err := admin.ListPeers(ctx, &pro.Empty{})
+	if err != nil || len(peers.Peers) != 1 || peers.Peers[0].Addr != cluster[1].Address || peers.Peers[0].Inbound {
+		t.Errorf("node 0 should list node 1 as its outbound peer, got %v (%v)", peers, err)
+	}
+
+	if _, err := admin.StartMining(ctx, &pro.Empty{}); err != nil || !cluster[0].Miner.Active.Load() {
+		t.Errorf("miner should have started: %v", err)
+	}
+	if _, err := admin.Pause(ctx, &pro.Empty{}); err != nil || !cluster[0].Paused.Load() {
+		t.Errorf("node should have paused: %v", err)
+	}
+	if _, err := admin.StopMining(ctx, &pro.Empty{}); err != nil {
+		t.Errorf("miner should have stopped: %v", err)
+	}
+	if _, err := admin.Resume(ctx, &pro.Empty{}); err != nil || cluster[0].Paused.Load() {
+		t.Errorf("node should have resumed: %v", err)
+	}
+	if cluster[0].Miner.Active.Load() {
+		t.Errorf("miner stopped while paused should stay stopped after resuming")
+	}
+}
+
+func TestAdminToken(t *testing.T) {
+	cluster := NewCluster(1)
+	defer CleanUp([]*blockchain.BlockChain{cluster[0].BlockChain})
+	cluster[0].Config.AdminAddr = fmt.Sprintf("127.0.0.1:%v", GetFreePort())
+	cluster[0].Config.AdminToken = "secret"
+	StartCluster(cluster)
+
+	conn, err := grpc.Dial(cluster[0].Config.AdminAddr, grpc.WithInsecure())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+	admin := pro.NewAdminClient(conn)
+
+	if _, err := admin.GetBlockCount(context.Background(), &pro.Empty{}); status.Code(err) != codes.Unauthenticated {
+		t.Errorf("calls without a token should be refused, got %v", err)
+	}
+	wrong := metadata.AppendToOutgoingContext(context.Background(), "authorization", "Bearer guess")
+	if _, err := admin.GetBlockCount(wrong, &pro.Empty{}); status.Code(err) != codes.Unauthenticated {
+		t.Errorf("calls with the wrong token should be refused, got %v", err)
+	}
+	right := metadata.AppendToOutgoingContext(context.Background(), "authorization", "Bearer secret")
+	if _, err := admin.GetBlockCount(right, &pro.Empty{}); err != nil {
+		t.Errorf("calls with the token should be served: %v", err)
+	}
+}
+
+func TestAdminRefusesOpenAddrWithoutToken(t *testing.T) {
+	cluster := NewCluster(1)
+	defer CleanUp([]*blockchain.BlockChain{cluster[0].BlockChain})
+	n := cluster[0]
+
+	// reachable from other machines, but without a token
+	open := fmt.Sprintf("0.0.0.0:%v", GetFreePort())
+	if err := n.StartAdminServer(open); err == nil {
+		n.AdminServer.Stop()
+		t.Fatalf("admin service on %v without a token should have been refused", open)
+	}
+
+	// with a token, the same address is served
+	n.Config.AdminToken = "secret"
+	if err := n.StartAdminServer(open); err != nil {
+		t.Fatalf("admin service on %v with a token should have been served: %v", open, err)
+	}
+	n.AdminServer.Stop()
+}
+
+func TestAdminQueries(t *testing.T) {
+	cluster := NewCluster(2)
+	chains := []*blockchain.BlockChain{cluster[0].BlockChain, cluster[1].BlockChain}
+	defer CleanUp(chains)
+	cluster[0].Config.AdminAddr = fmt.Sprintf("127.0.0.1:%v", GetFreePort())
+	StartCluster(cluster)
+	ConnectCluster(cluster)
+
+	conn, err := grpc.Dial(cluster[0].Config.AdminAddr, grpc.WithInsecure())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+	admin := pro.NewAdminClient(conn)
+	ctx := context.Background()
+
+	node, err := admin.GetNodeInfo(ctx, &pro.Empty{})
+	if err != nil || node.Address != cluster[0].Address || node.Inbound+node.Outbound != 1 || node.Paused {
+		t.Errorf("node info should describe node 0 with 1 peer, got %v (%v)", node, err)
+	}
+	chain, err := admin.GetChainInfo(ctx, &pro.Empty{})
+	if err != nil || chain.Height != 1 || chain.BestHash != cluster[0].BlockChain.LastHash || chain.GenesisHash != chain.BestHash {
+		t.Errorf("chain info should describe a chain of just the genesis block, got %v (%v)", chain, err)
+	}
+	mining, err := admin.GetMiningInfo(ctx, &pro.Empty{})
+	if err != nil || !mining.HasMiner || mining.Active || mining.ChainLength != 1 {
+		t.Errorf("mining info should describe an idle miner, got %v (%v)", mining, err)
+	}
+	pool, err := admin.GetMempool(ctx, &pro.Empty{})
+	if err != nil || pool.Count != 0 || len(pool.Entries) != 0 || pool.Capacity == 0 {
+		t.Errorf("mempool should be empty, got %v (%v)", pool, err)
+	}
+
+	// ban node 1, which also drops it, then lift the ban
+	peerAddr := cluster[1].Address
+	if _, err := admin.BanPeer(ctx, &pro.BanRequest{Addr: peerAddr, Reason: "testing"}); err != nil {
+		t.Fatalf("node 1 should have been banned: %v", err)
+	}
+	if cluster[0].PeerDb.Get(peerAddr) != nil {
+		t.Errorf("banned peers should be disconnected")
+	}
+	bans, err := admin.ListBans(ctx, &pro.Empty{})
+	if err != nil || len(bans.Bans) == 0 || bans.Bans[0].Reason != "testing" {
+		t.Errorf("ban list should hold node 1, got %v (%v)", bans, err)
+	}
+	unban, err := admin.UnbanPeer(ctx, &pro.PeerRequest{Addr: peerAddr})
+	if err != nil || !unban.WasBanned {
+		t.Errorf("node 1 should have been unbanned, got %v (%v)", unban, err)
+	}
+	if _, err := admin.DisconnectPeer(ctx, &pro.PeerRequest{Addr: peerAddr}); status.Code(err) != codes.NotFound {
+		t.Errorf("disconnecting a node that is not a peer should fail, got %v", err)
+	}
+}
@@ -0,0 +1,85 @@
+package test
+
+import (
+	"Coin/pkg"
+	"Coin/pkg/block"
+	"Coin/pkg/blockchain"
+	"Coin/pkg/script"
+	"encoding/hex"
+	"testing"
+)
+
+func TestBalanceLeavesOutSpentCoins(t *testing.T) {
+	n := pkg.New(setNodeConfig(GenesisConfig(GetFreePort()), 0))
+	defer CleanUp([]*blockchain.BlockChain{n.BlockChain})
+	alice, bob := newKey(t), newKey(t)
+	alicePk := hex.EncodeToString(alice.GetPublicKeyBytes())
+	bobPk := hex.EncodeToString(bob.GetPublicKeyBytes())
+
+	coinbase := &block.Transaction{Outputs: []*block.TransactionOutput{
+		{Amount: 30, LockingScript: script.PayToPubKeyHash(alice.GetPublicKeyBytes())},
+	}}
+	n.BlockChain.HandleBlock(&block.Block{
+		Header:       &block.Header{PreviousHash: n.BlockChain.LastHash, Timestamp: 1},
+		Transactions: []*block.Transaction{coinbase},
+	})
+	tx := &block.Transaction{
+		Inputs:  []*block.TransactionInput{{ReferenceTransactionHash: coinbase.Hash(), OutputIndex: 0}},
+		Outputs: []*block.TransactionOutput{{Amount: 10, LockingScript: script.PayToPubKeyHash(bob.GetPublicKeyBytes())}},
+	}
+	tx.Inputs[0].UnlockingScript = script.UnlockPubKeyHash(sign(t, alice, tx, 0), alice.GetPublicKeyBytes())
+	n.BlockChain.HandleBlock(&block.Block{
+		Header:       &block.Header{PreviousHash: n.BlockChain.LastHash, Timestamp: 2},
+		Transactions: []*block.Transaction{tx},
+	})
+	if n.BlockChain.Length != 3 {
+		t.Fatalf("both blocks should have been appended")
+	}
+
+	// alice's coin was spent in the main cache, which reading a
+	// balance should neither count nor flush
+	_, _, flushes := n.BlockChain.CoinDB.CacheStats()
+	if balance := n.BlockChain.GetBalance(alicePk); balance != 0 {
+		t.Errorf("alice's spent coin should not count towards her balance, got %v", balance)
+	}
+	if balance := n.BlockChain.GetBalance(bobPk); balance != 10 {
+		t.Errorf("bob should have a balance of 10, got %v", balance)
+	}
+	if locators, _ := n.BlockChain.CoinDB.GetUnspentCoins(alicePk); len(locators) != 0 {
+		t.Errorf("alice should have no unspent coins, got %v", locators)
+	}
+	if _, _, after := n.BlockChain.CoinDB.CacheStats(); after != flushes {
+		t.Errorf("reading balances should not have flushed the main cache")
+	}
+}
+
+func TestBalanceWhileHandlingBlocks(t *testing.T) {
+	n := pkg.New(setNodeConfig(GenesisConfig(GetFreePort()), 0))
+	defer CleanUp([]*blockchain.BlockChain{n.BlockChain})
+	alice := newKey(t)
+	alicePk := hex.EncodeToString(alice.GetPublicKeyBytes())
+
+	// balances are read while blocks are stored, which must
+	// not race on the main cache
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		for i := 0; i < 200; i++ {
+			n.BlockChain.GetBalance(alicePk)
+			n.BlockChain.CoinDB.GetUnspentCoins(alicePk)
+		}
+	}()
+	for i := uint32(1); i <= 50; i++ {
+		n.BlockChain.HandleBlock(&block.Block{
+			Header: &block.Header{PreviousHash: n.BlockChain.LastHash, Timestamp: i},
+			Transactions: []*block.Transaction{{
+				Outputs:  []*block.TransactionOutput{{Amount: 1, LockingScript: script.PayToPubKeyHash(alice.GetPublicKeyBytes())}},
+				LockTime: i,
+			}},
+		})
+	}
+	<-done
+	if balance := n.BlockChain.GetBalance(alicePk); balance != 50 {
+		t.Errorf("alice should have a balance of 50, got %v", balance)
+	}
+}
@@ -0,0 +1,119 @@
+package test
+
+import (
+	"Coin/pkg"
+	"Coin/pkg/blockchain"
+	"Coin/pkg/network"
+	"flag"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func writeConfigFile(t *testing.T, contents string) string {
+	t.Helper()
+	dir, err := ioutil.TempDir("", "coinconfig")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { os.RemoveAll(dir) })
+	path := filepath.Join(dir, "coin.json")
+	if err := ioutil.WriteFile(path, []byte(contents), 0600); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func TestLoadConfigPrecedence(t *testing.T) {
+	path := writeConfigFile(t, `{
+		"network": "testnet",
+		"listen_addr": "127.0.0.1:9000",
+		"seeds": ["10.0.0.1:17777", "10.0.0.2:17777"],
+		"peer_limit": 30,
+		"target_outbound": 10,
+		"connect_interval": "10s",
+		"miner": false
+	}`)
+	os.Setenv("COIN_PEER_LIMIT", "40")
+	os.Setenv("COIN_MAX_INBOUND", "20")
+	defer os.Unsetenv("COIN_PEER_LIMIT")
+	defer os.Unsetenv("COIN_MAX_INBOUND")
+
+	fs := flag.NewFlagSet("coin", flag.ContinueOnError)
+	c, err := pkg.LoadConfig(fs, []string{"-config", path, "-max_inbound", "25"})
+	if err != nil {
+		t.Fatalf("config should have loaded: %v", err)
+	}
+	if c.Network != network.Testnet || c.Port != network.Testnet.DefaultPort {
+		t.Errorf("file should have chosen the testnet and its default port")
+	}
+	if c.ListenAddr != "127.0.0.1:9000" || len(c.Seeds) != 2 || c.ConnectInterval != 10*time.Second || c.MinerConfig.HasMiner {
+		t.Errorf("file settings should have been applied")
+	}
+	if c.TargetOutbound != 10 || c.PeerLimit != 40 || c.MaxInbound != 25 {
+		t.Errorf("expected file < env < flags, got target_outbound %v, peer_limit %v, max_inbound %v",
+			c.TargetOutbound, c.PeerLimit, c.MaxInbound)
+	}
+	if c.DiscoveryInterval != pkg.DefaultConfig(0).DiscoveryInterval {
+		t.Errorf("settings that are not given should keep their defaults")
+	}
+}
+
+func TestLoadConfigRejectsBadSettings(t *testing.T) {
+	cases := map[string]string{
+		"unknown setting":  `{"colour": "blue"}`,
+		"bad duration":     `{"connect_interval": 30}`,
+		"unknown network":  `{"network": "moonnet"}`,
+		"too many peers":   `{"peer_limit": 5, "target_outbound": 8}`,
+		"bad seed":         `{"seeds": "not an address"}`,
+		"shared db paths":  `{"coin_db_path": "data", "block_info_db_path": "data"}`,
+		"conflicting mode": `{"no_listen": true, "listen_addr": "127.0.0.1:9000"}`,
+		"public admin":     `{"admin_addr": "0.0.0.0:7778"}`,
+		"explorer clash":   `{"explorer_addr": "127.0.0.1:7780", "gateway_addr": "127.0.0.1:7780"}`,
+	}
+	for name, contents := range cases {
+		fs := flag.NewFlagSet("coin", flag.ContinueOnError)
+		if _, err := pkg.LoadConfig(fs, []string{"-config", writeConfigFile(t, contents)}); err == nil {
+			t.Errorf("%v: config should have been rejected", name)
+		}
+	}
+
+	// every problem is reported at once
+	c := pkg.DefaultConfig(0)
+	c.PeerLimit = 0
+	err := c.Validate()
+	verr, ok := err.(*pkg.ValidationError)
+	if !ok || len(verr.Problems) < 2 || !strings.Contains(err.Error(), "port") {
+		t.Errorf("expected the port and peer limit problems, got %v", err)
+	}
+}
+
+func TestNoMinerNode(t *testing.T) {
+	conf := setNodeConfig(pkg.NoMinerConfig(GetFreePort()), 0)
+	if err := conf.Validate(); err != nil {
+		t.Fatalf("no miner config should be valid: %v", err)
+	}
+	n := pkg.New(conf)
+	defer CleanUp([]*blockchain.BlockChain{n.BlockChain})
+	if n.Miner != nil {
+		t.Fatalf("node should not have a miner")
+	}
+	n.Start()
+	n.Kill()
+}
+
+func TestTestingConfigPeersWithDefault(t *testing.T) {
+	testing0 := pkg.New(setNodeConfig(pkg.TestingConfig(GetFreePort()), 0))
+	default1 := pkg.New(setNodeConfig(pkg.DefaultConfig(GetFreePort()), 1))
+	defer CleanUp([]*blockchain.BlockChain{testing0.BlockChain, default1.BlockChain})
+	if testing0.Config.Network != default1.Config.Network {
+		t.Fatalf("testing nodes should be on the same network as default ones")
+	}
+	StartCluster([]*pkg.Node{testing0, default1})
+	if !testing0.ConnectToPeer(default1.Address) {
+		t.Errorf("a testing node should peer with a default node")
+	}
+}
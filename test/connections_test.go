@@ -0,0 +1,133 @@
+package test
+
+import (
+	"Coin/pkg"
+	"Coin/pkg/blockchain"
+	"Coin/pkg/utils"
+	"fmt"
+	"os"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestSeedsAndInboundLimit(t *testing.T) {
+	// set up cluster
+	cluster := NewCluster(3)
+	chains := []*blockchain.BlockChain{cluster[0].BlockChain, cluster[1].BlockChain, cluster[2].BlockChain}
+	defer CleanUp(chains)
+	hostname, _ := os.Hostname()
+	seed := fmt.Sprintf("%v:%v", hostname, cluster[0].Config.Port)
+	cluster[0].Config.MaxInbound = 1
+	cluster[1].Config.Seeds = []string{seed}
+	cluster[2].Config.Seeds = []string{seed}
+	StartCluster(cluster)
+
+	// both nodes try the seed, but it only takes one of them
+	time.Sleep(500 * time.Millisecond)
+	if cluster[0].InboundCount() != 1 || cluster[0].OutboundCount() != 0 {
+		t.Errorf("seed should have exactly 1 inbound peer, has %v inbound and %v outbound",
+			cluster[0].InboundCount(), cluster[0].OutboundCount())
+	}
+	if cluster[1].OutboundCount()+cluster[2].OutboundCount() != 1 {
+		t.Errorf("exactly one node should have connected to the seed")
+	}
+}
+
+func TestInboundLimitUnderConcurrentVersions(t *testing.T) {
+	// set up cluster
+	cluster := NewCluster(6)
+	chains := make([]*blockchain.BlockChain, 0, len(cluster))
+	for _, n := range cluster {
+		chains = append(chains, n.BlockChain)
+	}
+	defer CleanUp(chains)
+	cluster[0].Config.MaxInbound = 2
+	StartCluster(cluster)
+
+	// every other node connects at once, but only two get in
+	var wg sync.WaitGroup
+	for _, n := range cluster[1:] {
+		wg.Add(1)
+		go func(n *pkg.Node) {
+			defer wg.Done()
+			n.ConnectToPeer(cluster[0].Address)
+		}(n)
+	}
+	wg.Wait()
+	if cluster[0].InboundCount() != 2 {
+		t.Errorf("node 0 should have exactly 2 inbound peers, has %v", cluster[0].InboundCount())
+	}
+}
+
+func TestDiscoveryFindsNewPeers(t *testing.T) {
+	// set up cluster
+	cluster := NewCluster(3)
+	chains := []*blockchain.BlockChain{cluster[0].BlockChain, cluster[1].BlockChain, cluster[2].BlockChain}
+	defer CleanUp(chains)
+	cluster[0].Config.ConnectInterval = 100 * time.Millisecond
+	cluster[0].Config.DiscoveryInterval = 100 * time.Millisecond
+	StartCluster(cluster)
+	cluster[1].ConnectToPeer(cluster[2].Address)
+	cluster[0].ConnectToPeer(cluster[1].Address)
+
+	// node 0 only knows node 1, but should learn of node 2
+	// through it and connect to it
+	time.Sleep(time.Second)
+	p := cluster[0].PeerDb.Get(cluster[2].Address)
+	if p == nil {
+		t.Fatalf("node 0 should have discovered and connected to node 2")
+	}
+	if p.Inbound {
+		t.Errorf("node 0 should have counted node 2 as an outbound peer")
+	}
+}
+
+func TestListenAndAdvertiseAddrs(t *testing.T) {
+	// set up cluster
+	cluster := NewCluster(3)
+	chains := []*blockchain.BlockChain{cluster[0].BlockChain, cluster[1].BlockChain, cluster[2].BlockChain}
+	defer CleanUp(chains)
+	cluster[0].Config.ListenAddr = fmt.Sprintf("127.0.0.1:%v", cluster[0].Config.Port)
+	cluster[1].Config.ListenAddr = fmt.Sprintf("[::]:%v", cluster[1].Config.Port)
+	cluster[1].Config.AdvertiseAddr = fmt.Sprintf("[::1]:%v", cluster[1].Config.Port)
+	cluster[2].Config.NoListen = true
+	StartCluster(cluster)
+
+	if cluster[0].Address != cluster[0].Config.ListenAddr {
+		t.Errorf("node 0 should advertise its listen address, advertises %v", cluster[0].Address)
+	}
+	if cluster[1].Address != cluster[1].Config.AdvertiseAddr {
+		t.Errorf("node 1 should advertise its advertise address, advertises %v", cluster[1].Address)
+	}
+	if !cluster[0].ConnectToPeer(cluster[1].Address) || cluster[1].PeerDb.Get(cluster[0].Address) == nil {
+		t.Fatalf("nodes 0 and 1 should have peered over IPv4 and IPv6")
+	}
+
+	// a node that does not listen only makes outbound connections
+	if !cluster[2].ConnectToPeer(cluster[0].Address) {
+		t.Fatalf("node 2 should have peered with node 0")
+	}
+	p := cluster[0].PeerDb.Get(cluster[2].Address)
+	if p == nil || !p.NoListen || !p.Inbound {
+		t.Fatalf("node 0 should have node 2 as an inbound peer that does not listen")
+	}
+	if cluster[0].AddressDB.Get(cluster[2].Address) != nil {
+		t.Errorf("node 0 should not remember the address of a node that does not listen")
+	}
+}
+
+func TestValidAddr(t *testing.T) {
+	valid := []string{"localhost:8000", "10.0.0.1:8000", "[::1]:8000", "[2001:db8::1]:65535"}
+	invalid := []string{"", "localhost", ":8000", "0.0.0.0:8000", "[::]:8000", "10.0.0.1:0", "10.0.0.1:70000", "::1:8000"}
+	for _, addr := range valid {
+		if !utils.ValidAddr(addr) {
+			t.Errorf("%q should be a valid address", addr)
+		}
+	}
+	for _, addr := range invalid {
+		if utils.ValidAddr(addr) {
+			t.Errorf("%q should not be a valid address", addr)
+		}
+	}
+}
@@ -0,0 +1,140 @@
+package test
+
+import (
+	"Coin/pkg/address"
+	"fmt"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/connectivity"
+	"net"
+	"testing"
+	"time"
+)
+
+// newTestConns returns a connection manager without transport
+// security, with short timeouts, and a free address to dial.
+func newTestConns(t *testing.T, c *address.Config) (*address.ConnectionManager, string) {
+	cm := address.NewConnectionManager(c, nil)
+	t.Cleanup(cm.Close)
+	return cm, fmt.Sprintf("127.0.0.1:%v", GetFreePort())
+}
+
+// serveGRPC serves gRPC, with no services, on a free address,
+// so that connections to it stay healthy.
+func serveGRPC(t *testing.T) string {
+	lis, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	s := grpc.NewServer()
+	go s.Serve(lis)
+	t.Cleanup(s.Stop)
+	return lis.Addr().String()
+}
+
+func TestConnectionReuse(t *testing.T) {
+	cm, addr := newTestConns(t, address.DefaultConfig())
+	first, err := cm.Get(addr)
+	if err != nil {
+		t.Fatal(err)
+	}
+	second, err := cm.Get(addr)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if first != second {
+		t.Errorf("the pooled connection should have been reused")
+	}
+	other, err := cm.Get(fmt.Sprintf("127.0.0.1:%v", GetFreePort()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if other == first {
+		t.Errorf("each address should get a connection of its own")
+	}
+
+	// a removed connection is closed, and the next Get dials again
+	cm.Remove(addr)
+	if first.GetState() != connectivity.Shutdown {
+		t.Errorf("a removed connection should have been closed")
+	}
+	if third, err := cm.Get(addr); err != nil || third == first {
+		t.Errorf("a new connection should have been dialed, got %v", err)
+	}
+}
+
+func TestConnectionBackoff(t *testing.T) {
+	c := address.DefaultConfig()
+	c.MinBackoff = 100 * time.Millisecond
+	c.MaxBackoff = 400 * time.Millisecond
+	cm, addr := newTestConns(t, c)
+	if _, err := cm.Get(addr); err != nil {
+		t.Fatal(err)
+	}
+
+	// backsOff returns whether the manager backs off for about
+	// want before it dials again
+	backsOff := func(want time.Duration) bool {
+		start := time.Now()
+		for {
+			if _, err := cm.Get(addr); err == nil {
+				d := time.Since(start)
+				return d >= want*9/10 && d < want*3/2
+			}
+			time.Sleep(5 * time.Millisecond)
+		}
+	}
+
+	cm.ReportFailure(addr)
+	if !backsOff(100 * time.Millisecond) {
+		t.Errorf("the first backoff should have been 100ms")
+	}
+	cm.ReportFailure(addr)
+	if !backsOff(200 * time.Millisecond) {
+		t.Errorf("the second backoff should have doubled to 200ms")
+	}
+	cm.ReportFailure(addr)
+	cm.ReportFailure(addr)
+	if !backsOff(400 * time.Millisecond) {
+		t.Errorf("the backoff should have been capped at 400ms")
+	}
+
+	// a success resets the backoff
+	cm.ReportSuccess(addr)
+	cm.ReportFailure(addr)
+	if !backsOff(100 * time.Millisecond) {
+		t.Errorf("a success should have reset the backoff to 100ms")
+	}
+}
+
+func TestConnectionIdleEviction(t *testing.T) {
+	c := address.DefaultConfig()
+	c.IdleTimeout = 100 * time.Millisecond
+	c.HealthCheckInterval = 20 * time.Millisecond
+	cm, _ := newTestConns(t, c)
+	addr, busyAddr := serveGRPC(t), serveGRPC(t)
+	idle, err := cm.Get(addr)
+	if err != nil {
+		t.Fatal(err)
+	}
+	busy, err := cm.Get(busyAddr)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// keep one connection in use while the other goes idle
+	for i := 0; i < 10; i++ {
+		time.Sleep(30 * time.Millisecond)
+		if _, err := cm.Get(busyAddr); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if idle.GetState() != connectivity.Shutdown {
+		t.Errorf("the idle connection should have been closed")
+	}
+	if busy.GetState() == connectivity.Shutdown {
+		t.Errorf("the connection in use should have been kept open")
+	}
+	if again, err := cm.Get(addr); err != nil || again == idle {
+		t.Errorf("the evicted connection should have been dialed again, got %v", err)
+	}
+}
@@ -0,0 +1,59 @@
+package test
+
+import (
+	"Coin/pkg"
+	"Coin/pkg/block"
+	"Coin/pkg/blockchain"
+	"errors"
+	"testing"
+)
+
+func TestBlockDoubleSpend(t *testing.T) {
+	// the genesis output must be worth something to be spent
+	conf := setNodeConfig(GenesisConfig(GetFreePort()), 0)
+	conf.ChainConfig.InitialSubsidy = 50
+	n := pkg.New(conf)
+	defer CleanUp([]*blockchain.BlockChain{n.BlockChain})
+	genesis := n.BlockChain.LastBlock
+
+	spend := func(payee string, inputs int) *block.Transaction {
+		tx := &block.Transaction{Outputs: []*block.TransactionOutput{{Amount: 40, LockingScript: payee}}}
+		for i := 0; i < inputs; i++ {
+			tx.Inputs = append(tx.Inputs, &block.TransactionInput{ReferenceTransactionHash: genesis.Transactions[0].Hash(), OutputIndex: 0})
+		}
+		SignGenesisInputs(tx)
+		return tx
+	}
+
+	for name, txs := range map[string][]*block.Transaction{
+		// each transaction is valid on its own, but not both
+		"two transactions": {spend("alice", 1), spend("bob", 1)},
+		"a repeated input": {spend("alice", 2)},
+	} {
+		b := &block.Block{
+			Header:       &block.Header{PreviousHash: genesis.Hash(), Timestamp: 1},
+			Transactions: txs,
+		}
+		var rerr *pkg.RejectError
+		if err := n.ValidateBlock(b); !errors.As(err, &rerr) || rerr.Reason != pkg.RejectDoubleSpend {
+			t.Errorf("block spending a coin twice in %v should have been rejected as a double spend, got %v", name, err)
+		}
+		n.BlockChain.HandleBlock(b)
+		if n.BlockChain.Length != 1 {
+			t.Fatalf("block spending a coin twice in %v should not have been appended", name)
+		}
+	}
+
+	// the coin can still be spent once
+	b := &block.Block{
+		Header:       &block.Header{PreviousHash: genesis.Hash(), Timestamp: 2},
+		Transactions: []*block.Transaction{spend("alice", 1)},
+	}
+	if err := n.ValidateBlock(b); err != nil {
+		t.Errorf("block spending the coin once should have been valid, got %v", err)
+	}
+	n.BlockChain.HandleBlock(b)
+	if n.BlockChain.Length != 2 {
+		t.Errorf("block spending the coin once should have been appended")
+	}
+}
@@ -0,0 +1,97 @@
+package test
+
+import (
+	"Coin/pkg"
+	"Coin/pkg/block"
+	"Coin/pkg/blockchain"
+	"encoding/hex"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"strings"
+	"testing"
+)
+
+// getPage fetches an explorer page, following redirects, and
+// returns its body, status code and final path.
+func getPage(t *testing.T, url string) (string, int, string) {
+	resp, err := http.Get(url)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	body, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return string(body), resp.StatusCode, resp.Request.URL.Path
+}
+
+func TestExplorer(t *testing.T) {
+	// the genesis output must be worth something to be spent
+	conf := setNodeConfig(GenesisConfig(GetFreePort()), 0)
+	conf.ChainConfig.InitialSubsidy = 50
+	conf.ExplorerAddr = fmt.Sprintf("127.0.0.1:%v", GetFreePort())
+	n := pkg.New(conf)
+	other := pkg.New(setNodeConfig(pkg.DefaultConfig(GetFreePort()), 1))
+	defer CleanUp([]*blockchain.BlockChain{n.BlockChain, other.BlockChain})
+	cluster := []*pkg.Node{n, other}
+	StartCluster(cluster)
+	ConnectCluster(cluster)
+	url := "http://" + n.Config.ExplorerAddr
+
+	genesis := n.BlockChain.LastHash
+	genTx := n.BlockChain.LastBlock.Transactions[0]
+	tx := &block.Transaction{
+		Inputs:  []*block.TransactionInput{{ReferenceTransactionHash: genTx.Hash(), OutputIndex: 0}},
+		Outputs: []*block.TransactionOutput{{Amount: 40, LockingScript: "someone"}},
+	}
+	SignGenesisInputs(tx)
+	if err := n.SubmitTransaction(tx); err != nil {
+		t.Fatalf("transaction should have been accepted: %v", err)
+	}
+	pk := hex.EncodeToString([]byte(blockchain.GENPK))
+
+	pages := []struct {
+		path string
+		want []string
+	}{
+		{"/", []string{"The main chain has 1 blocks", genesis}},
+		{"/blocks/1", []string{genesis, "Main chain</th><td>yes", genTx.Hash()}},
+		{"/blocks/" + genesis, []string{"Block 1", "None, this transaction makes new coins"}},
+		{"/transactions/" + genTx.Hash(), []string{"in block", genesis, "at height 1"}},
+		{"/transactions/" + tx.Hash(), []string{"waiting in the mempool", "/transactions/" + genTx.Hash()}},
+		{"/addresses/" + pk, []string{"Balance: 50", genTx.Hash()}},
+		{"/mempool", []string{"1 transactions are waiting", tx.Hash()}},
+		{"/peers", []string{other.Address, pkg.UserAgent}},
+	}
+	for _, p := range pages {
+		body, code, _ := getPage(t, url+p.path)
+		if code != http.StatusOK {
+			t.Errorf("%v should have been found, got %v", p.path, code)
+		}
+		for _, want := range p.want {
+			if !strings.Contains(body, want) {
+				t.Errorf("%v should show %q", p.path, want)
+			}
+		}
+	}
+
+	for _, path := range []string{"/blocks/2", "/blocks/nope", "/transactions/nope", "/addresses/zz", "/nowhere"} {
+		if _, code, _ := getPage(t, url+path); code != http.StatusNotFound {
+			t.Errorf("%v should not have been found, got %v", path, code)
+		}
+	}
+
+	searches := map[string]string{
+		"1":          "/blocks/1",
+		genesis:      "/blocks/" + genesis,
+		genTx.Hash(): "/transactions/" + genTx.Hash(),
+		pk:           "/addresses/" + pk,
+	}
+	for q, want := range searches {
+		if _, _, path := getPage(t, url+"/search?q="+q); path != want {
+			t.Errorf("searching for %v should lead to %v, got %v", q, want, path)
+		}
+	}
+}
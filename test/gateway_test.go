@@ -0,0 +1,127 @@
+package test
+
+import (
+	"Coin/pkg"
+	"Coin/pkg/block"
+	"Coin/pkg/blockchain"
+	"Coin/pkg/gateway"
+	"bytes"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"testing"
+)
+
+// getJSON fetches a gateway endpoint into v, and returns the
+// status code of the response.
+func getJSON(t *testing.T, url string, v interface{}) int {
+	resp, err := http.Get(url)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	if err := json.NewDecoder(resp.Body).Decode(v); err != nil {
+		t.Fatalf("%v did not answer with JSON: %v", url, err)
+	}
+	return resp.StatusCode
+}
+
+// postJSON posts body to a gateway endpoint, decodes the answer
+// into v, and returns the status code of the response.
+func postJSON(t *testing.T, url string, body []byte, v interface{}) int {
+	resp, err := http.Post(url, "application/json", bytes.NewReader(body))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	if err := json.NewDecoder(resp.Body).Decode(v); err != nil {
+		t.Fatalf("%v did not answer with JSON: %v", url, err)
+	}
+	return resp.StatusCode
+}
+
+func TestGateway(t *testing.T) {
+	// the genesis output must be worth something to be spent
+	conf := setNodeConfig(GenesisConfig(GetFreePort()), 0)
+	conf.ChainConfig.InitialSubsidy = 50
+	conf.GatewayAddr = fmt.Sprintf("127.0.0.1:%v", GetFreePort())
+	n := pkg.New(conf)
+	defer CleanUp([]*blockchain.BlockChain{n.BlockChain})
+	StartCluster([]*pkg.Node{n})
+	url := "http://" + n.Config.GatewayAddr
+
+	var chain gateway.ChainInfo
+	if code := getJSON(t, url+"/v1/chain", &chain); code != http.StatusOK || chain.Height != 1 {
+		t.Fatalf("chain should have 1 block, got %v (%v)", chain, code)
+	}
+	var byHeight, byHash gateway.Block
+	getJSON(t, url+"/v1/blocks/1", &byHeight)
+	getJSON(t, url+"/v1/blocks/"+chain.BestHash, &byHash)
+	if byHeight.Hash != chain.BestHash || byHash.Hash != chain.BestHash || byHash.Height != 1 || len(byHash.Transactions) != 1 {
+		t.Fatalf("block 1 should be the genesis block, got %v and %v", byHeight, byHash)
+	}
+	var e gateway.Error
+	if code := getJSON(t, url+"/v1/blocks/2", &e); code != http.StatusNotFound || e.Error == "" {
+		t.Errorf("blocks past the tip should not be found, got %v", code)
+	}
+
+	genTx := byHash.Transactions[0]
+	var info gateway.TransactionInfo
+	if getJSON(t, url+"/v1/transactions/"+genTx.Hash, &info); !info.Confirmed || info.Height != 1 || info.BlockHash != chain.BestHash {
+		t.Errorf("genesis transaction should be confirmed in block 1, got %v", info)
+	}
+
+	pk := hex.EncodeToString([]byte(blockchain.GENPK))
+	subsidy := n.Config.ChainConfig.InitialSubsidy
+	var balance gateway.Balance
+	if getJSON(t, url+"/v1/addresses/"+pk+"/balance", &balance); balance.Balance != subsidy {
+		t.Errorf("genesis key should hold the initial subsidy of %v, got %v", subsidy, balance.Balance)
+	}
+	var utxos gateway.UTXOs
+	getJSON(t, url+"/v1/addresses/"+pk+"/utxos", &utxos)
+	if len(utxos.UTXOs) != 1 || utxos.UTXOs[0].TransactionHash != genTx.Hash || utxos.UTXOs[0].Amount != subsidy {
+		t.Fatalf("genesis key should have the genesis output unspent, got %v", utxos.UTXOs)
+	}
+
+	// a transaction that spends a coin that does not exist
+	bad := gateway.EncodeTransaction(&block.Transaction{
+		Inputs:  []*block.TransactionInput{{ReferenceTransactionHash: "nope"}},
+		Outputs: []*block.TransactionOutput{{Amount: 1, LockingScript: "someone"}},
+	})
+	body, _ := json.Marshal(bad)
+	var result gateway.SubmitResult
+	if code := postJSON(t, url+"/v1/transactions", body, &result); code != http.StatusUnprocessableEntity || result.Accepted || result.Error == "" {
+		t.Errorf("transaction spending an unknown coin should be rejected, got %v (%v)", result, code)
+	}
+	if code := postJSON(t, url+"/v1/transactions", []byte(`{"inputs": 5}`), &e); code != http.StatusBadRequest {
+		t.Errorf("malformed transactions should be refused, got %v", code)
+	}
+
+	// a transaction that spends the genesis output, which must be
+	// signed for by the genesis key
+	spend := &block.Transaction{
+		Inputs:  []*block.TransactionInput{{ReferenceTransactionHash: genTx.Hash, OutputIndex: 0}},
+		Outputs: []*block.TransactionOutput{{Amount: subsidy - 1, LockingScript: "someone"}},
+	}
+	body, _ = json.Marshal(gateway.EncodeTransaction(spend))
+	result = gateway.SubmitResult{}
+	if code := postJSON(t, url+"/v1/transactions", body, &result); code != http.StatusUnprocessableEntity || result.Accepted {
+		t.Errorf("unsigned transaction spending the genesis output should be rejected, got %v (%v)", result, code)
+	}
+	SignGenesisInputs(spend)
+	good := gateway.EncodeTransaction(spend)
+	body, _ = json.Marshal(good)
+	result = gateway.SubmitResult{}
+	if code := postJSON(t, url+"/v1/transactions", body, &result); code != http.StatusOK || !result.Accepted || result.Hash != good.Hash {
+		t.Fatalf("transaction spending the genesis output should be accepted, got %v (%v)", result, code)
+	}
+	var pool gateway.Mempool
+	if getJSON(t, url+"/v1/mempool", &pool); pool.Count != 1 || pool.Entries[0].Transaction.Hash != good.Hash {
+		t.Errorf("mempool should hold the submitted transaction, got %v", pool)
+	}
+	info = gateway.TransactionInfo{}
+	if getJSON(t, url+"/v1/transactions/"+good.Hash, &info); info.Confirmed || info.Transaction == nil {
+		t.Errorf("submitted transaction should be found unconfirmed, got %v", info)
+	}
+}
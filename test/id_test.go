@@ -0,0 +1,40 @@
+package test
+
+import (
+	"Coin/pkg/id"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestIdentitySurvivesRestart(t *testing.T) {
+	dir, err := ioutil.TempDir("", "coinid")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "identity.pem")
+
+	first, err := id.LoadOrCreate(path)
+	if err != nil {
+		t.Fatalf("identity should have been created: %v", err)
+	}
+	second, err := id.LoadOrCreate(path)
+	if err != nil {
+		t.Fatalf("identity should have been loaded: %v", err)
+	}
+	if id.PublicKeyHex(first.GetPublicKey()) != id.PublicKeyHex(second.GetPublicKey()) {
+		t.Errorf("loaded identity should have the same key as the saved one")
+	}
+	if info, err := os.Stat(path); err != nil || info.Mode().Perm() != 0600 {
+		t.Errorf("identity file should only be readable by its owner")
+	}
+
+	if err := ioutil.WriteFile(path, []byte("garbage"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := id.LoadOrCreate(path); err == nil {
+		t.Errorf("a corrupt identity file should not be replaced silently")
+	}
+}
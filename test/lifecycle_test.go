@@ -0,0 +1,70 @@
+package test
+
+import (
+	"Coin/pkg"
+	"Coin/pkg/blockchain"
+	"context"
+	"net"
+	"testing"
+	"time"
+)
+
+func TestContextCancelShutsDownNode(t *testing.T) {
+	// set up cluster
+	cluster := NewCluster(2)
+	chains := []*blockchain.BlockChain{cluster[0].BlockChain, cluster[1].BlockChain}
+	ctx, cancel := context.WithCancel(context.Background())
+	cluster[0].StartContext(ctx)
+	cluster[1].Start()
+	cluster[1].ConnectToPeer(cluster[0].Address)
+	if cluster[1].PeerDb.Get(cluster[0].Address) == nil {
+		t.Fatalf("node 1 should have peered with node 0")
+	}
+
+	// canceling the context should shut node 0 down
+	cancel()
+	select {
+	case <-cluster[0].Done():
+	case <-time.After(5 * time.Second):
+		t.Fatalf("node 0 should have shut down after its context was canceled")
+	}
+	// shutting down again should be harmless
+	cluster[0].Kill()
+	cluster[1].Kill()
+	cluster[1].Kill()
+
+	// the shut down node should no longer accept peers
+	other := pkg.New(setNodeConfig(pkg.DefaultConfig(GetFreePort()), 2))
+	chains = append(chains, other.BlockChain)
+	defer CleanUp(chains)
+	other.Start()
+	defer other.Kill()
+	if other.ConnectToPeer(cluster[0].Address) {
+		t.Errorf("shut down node should not accept peers")
+	}
+}
+
+func TestStartFailsWhenPortTaken(t *testing.T) {
+	// another process already serves on the gateway's address
+	lis, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer lis.Close()
+	conf := setNodeConfig(GenesisConfig(GetFreePort()), 0)
+	conf.GatewayAddr = lis.Addr().String()
+	n := pkg.New(conf)
+	defer CleanUp([]*blockchain.BlockChain{n.BlockChain})
+
+	// starting reports the error instead of panicking, and
+	// killing the node closes what it did open
+	if err := n.Start(); err == nil {
+		t.Fatalf("starting should have failed with the gateway's port taken")
+	}
+	n.Kill()
+	other, err := net.Listen("tcp", n.Address)
+	if err != nil {
+		t.Fatalf("the node's own port should have been released, got %v", err)
+	}
+	other.Close()
+}
@@ -0,0 +1,152 @@
+package test
+
+import (
+	"Coin/pkg"
+	"Coin/pkg/utils"
+	"bytes"
+	"encoding/json"
+	"errors"
+	"flag"
+	"strings"
+	"sync"
+	"testing"
+)
+
+// logBuffer collects log entries. Nodes from other tests may
+// still be logging, so it is safe for concurrent use.
+type logBuffer struct {
+	b     bytes.Buffer
+	mutex sync.Mutex
+}
+
+func (l *logBuffer) Write(p []byte) (int, error) {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+	return l.b.Write(p)
+}
+
+// lines returns the entries logged by a component.
+func (l *logBuffer) lines(component string) []string {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+	var lines []string
+	for _, line := range strings.Split(l.b.String(), "\n") {
+		if strings.Contains(line, `"component":"`+component+`"`) || strings.Contains(line, "component="+component+" ") {
+			lines = append(lines, line)
+		}
+	}
+	return lines
+}
+
+// captureLogs sends log entries to a buffer until the test ends.
+func captureLogs(t *testing.T, c *utils.LogConfig) *logBuffer {
+	buf := &logBuffer{}
+	c.Output = buf
+	utils.ConfigureLogging(c)
+	t.Cleanup(func() { utils.ConfigureLogging(utils.DefaultLogConfig()) })
+	return buf
+}
+
+func TestLoggingJSON(t *testing.T) {
+	c := utils.DefaultLogConfig()
+	c.Format = utils.LogFormatJSON
+	buf := captureLogs(t, c)
+
+	log := utils.NewLogger(utils.ComponentWallet).With("node", "127.0.0.1:8000")
+	log.Warn("could not spend coin", "amount", 12, "err", errors.New("no coins"))
+	lines := buf.lines(utils.ComponentWallet)
+	if len(lines) != 1 {
+		t.Fatalf("expected one entry, got %v", lines)
+	}
+	var entry map[string]interface{}
+	if err := json.Unmarshal([]byte(lines[0]), &entry); err != nil {
+		t.Fatalf("entry should be JSON: %v", err)
+	}
+	want := map[string]interface{}{
+		"level":     "warn",
+		"component": "wallet",
+		"node":      "127.0.0.1:8000",
+		"msg":       "could not spend coin",
+		"amount":    float64(12),
+		"err":       "no coins",
+	}
+	for k, v := range want {
+		if entry[k] != v {
+			t.Errorf("expected %v to be %v, got %v", k, v, entry[k])
+		}
+	}
+	if _, ok := entry["time"]; !ok {
+		t.Errorf("entry should have a time")
+	}
+}
+
+func TestLoggingLevels(t *testing.T) {
+	c := utils.DefaultLogConfig()
+	c.Level = utils.LevelWarn
+	c.Levels[utils.ComponentWallet] = utils.LevelDebug
+	c.Levels[utils.ComponentRPC] = utils.LevelOff
+	buf := captureLogs(t, c)
+
+	utils.NewLogger(utils.ComponentWallet).Debug("shown by the wallet's level")
+	utils.NewLogger(utils.ComponentRPC).Error("hidden by the rpc's level")
+	miner := utils.NewLogger(utils.ComponentMiner)
+	miner.Info("hidden by the default level")
+	miner.Warn("shown by the default level")
+
+	if lines := buf.lines(utils.ComponentWallet); len(lines) != 1 || !strings.Contains(lines[0], "level=debug") {
+		t.Errorf("expected the wallet's debug entry, got %v", lines)
+	}
+	if lines := buf.lines(utils.ComponentRPC); len(lines) != 0 {
+		t.Errorf("rpc logging should be off, got %v", lines)
+	}
+	if lines := buf.lines(utils.ComponentMiner); len(lines) != 1 || !strings.Contains(lines[0], `msg="shown by the default level"`) {
+		t.Errorf("expected only the miner's warning, got %v", lines)
+	}
+	if !miner.Enabled(utils.LevelError) || miner.Enabled(utils.LevelInfo) {
+		t.Errorf("Enabled should follow the default level")
+	}
+}
+
+func TestLoggingText(t *testing.T) {
+	buf := captureLogs(t, utils.DefaultLogConfig())
+	utils.NewLogger(utils.ComponentWallet).With("node", "127.0.0.1:8000").Info("sent", "to", "a b", "empty", "")
+	lines := buf.lines(utils.ComponentWallet)
+	if len(lines) != 1 {
+		t.Fatalf("expected one entry, got %v", lines)
+	}
+	for _, part := range []string{"level=info", "component=wallet", "msg=sent", "node=127.0.0.1:8000", `to="a b"`, `empty=""`} {
+		if !strings.Contains(lines[0], part) {
+			t.Errorf("expected %v in %v", part, lines[0])
+		}
+	}
+	if strings.Contains(lines[0], "\033[") || utils.FmtAddr("127.0.0.1:8000") != "[127.0.0.1:8000]" {
+		t.Errorf("nothing should be colored unless colors are on")
+	}
+}
+
+func TestLoadLogConfig(t *testing.T) {
+	fs := flag.NewFlagSet("coin", flag.ContinueOnError)
+	c, err := pkg.LoadConfig(fs, []string{"-log_level", "warn", "-log_levels", "p2p=debug,chain=error", "-log_format", "json"})
+	if err != nil {
+		t.Fatalf("config should have loaded: %v", err)
+	}
+	if c.LogConfig.Level != utils.LevelWarn || c.LogConfig.Format != utils.LogFormatJSON {
+		t.Errorf("expected warn and json, got %v and %v", c.LogConfig.Level, c.LogConfig.Format)
+	}
+	if c.LogConfig.Levels[utils.ComponentP2P] != utils.LevelDebug || c.LogConfig.Levels[utils.ComponentChain] != utils.LevelError {
+		t.Errorf("per-component levels should have been applied, got %v", c.LogConfig.Levels)
+	}
+
+	for _, args := range [][]string{
+		{"-log_level", "loud"},
+		{"-log_levels", "disk=debug"},
+		{"-log_levels", "p2p"},
+		{"-log_format", "xml"},
+	} {
+		fs := flag.NewFlagSet("coin", flag.ContinueOnError)
+		fs.SetOutput(&bytes.Buffer{})
+		if _, err := pkg.LoadConfig(fs, args); err == nil {
+			t.Errorf("%v should have been rejected", args)
+		}
+	}
+}
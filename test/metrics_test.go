@@ -0,0 +1,97 @@
+package test
+
+import (
+	"Coin/pkg/block"
+	"Coin/pkg/blockchain"
+	"Coin/pkg/metrics"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"strings"
+	"testing"
+)
+
+func TestMetricsTextFormat(t *testing.T) {
+	r := metrics.NewRegistry()
+	r.NewCounter("test_events_total", "Events.").Add(3)
+	r.NewCounterVec("test_rejected_total", "Rejections.", "reason").With(`bad "input"`).Inc()
+	r.NewGaugeFunc("test_height", "Height.", func() float64 { return 7 })
+	h := r.NewHistogramVec("test_latency_seconds", "Latency.", []float64{0.1, 1}, "method")
+	h.With("Ping").Observe(0.05)
+	h.With("Ping").Observe(0.5)
+	h.With("Ping").Observe(5)
+
+	var b strings.Builder
+	if _, err := r.WriteTo(&b); err != nil {
+		t.Fatal(err)
+	}
+	want := `# HELP test_events_total Events.
+# TYPE test_events_total counter
+test_events_total 3
+# HELP test_height Height.
+# TYPE test_height gauge
+test_height 7
+# HELP test_latency_seconds Latency.
+# TYPE test_latency_seconds histogram
+test_latency_seconds_bucket{method="Ping",le="0.1"} 1
+test_latency_seconds_bucket{method="Ping",le="1"} 2
+test_latency_seconds_bucket{method="Ping",le="+Inf"} 3
+test_latency_seconds_sum{method="Ping"} 5.55
+test_latency_seconds_count{method="Ping"} 3
+# HELP test_rejected_total Rejections.
+# TYPE test_rejected_total counter
+test_rejected_total{reason="bad \"input\""} 1
+`
+	if b.String() != want {
+		t.Errorf("got\n%v\nwant\n%v", b.String(), want)
+	}
+}
+
+func TestNodeMetrics(t *testing.T) {
+	cluster := NewCluster(2)
+	chains := []*blockchain.BlockChain{cluster[0].BlockChain, cluster[1].BlockChain}
+	defer CleanUp(chains)
+	cluster[0].Config.MetricsAddr = fmt.Sprintf("127.0.0.1:%v", GetFreePort())
+	StartCluster(cluster)
+	ConnectCluster(cluster)
+
+	// a transaction that spends a coin that does not exist
+	tx := &block.Transaction{
+		Inputs:  []*block.TransactionInput{{ReferenceTransactionHash: "nope"}},
+		Outputs: []*block.TransactionOutput{{Amount: 1, LockingScript: "someone"}},
+	}
+	if err := cluster[0].SubmitTransaction(tx); err == nil {
+		t.Fatalf("transaction spending an unknown coin should be rejected")
+	}
+
+	resp, err := http.Get("http://" + cluster[0].Config.MetricsAddr + "/metrics")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	body, _ := ioutil.ReadAll(resp.Body)
+	if resp.Header.Get("Content-Type") != metrics.ContentType {
+		t.Errorf("metrics should be served as the text format, got %v", resp.Header.Get("Content-Type"))
+	}
+	for _, line := range []string{
+		"coin_chain_height 1",
+		"coin_chain_reorgs_total 0",
+		"coin_peers_outbound 1",
+		"coin_txpool_transactions 0",
+		`coin_transactions_rejected_total{reason="bad_inputs"} 1`,
+		"coin_coindb_cache_misses_total 1",
+	} {
+		if !strings.Contains(string(body), line+"\n") {
+			t.Errorf("metrics should contain %q", line)
+		}
+	}
+	// the handshake is timed on both ends
+	for _, prefix := range []string{
+		`coin_rpc_client_duration_seconds_count{method="Version"} `,
+		`coin_rpc_server_duration_seconds_count{method="Version"} `,
+	} {
+		if !strings.Contains(string(body), prefix) {
+			t.Errorf("metrics should contain %q", prefix)
+		}
+	}
+}
@@ -0,0 +1,285 @@
+package test
+
+import (
+	"Coin/pkg"
+	"Coin/pkg/block"
+	"Coin/pkg/blockchain"
+	"Coin/pkg/id"
+	"Coin/pkg/peer"
+	"Coin/pkg/pro"
+	"context"
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials"
+	"testing"
+)
+
+// dialWithoutSender dials addr over TLS as node n, but without
+// naming n as the sender of each request like n's own RPCs do.
+func dialWithoutSender(t *testing.T, n *pkg.Node, addr string) pro.CoinClient {
+	cert, err := id.Certificate(n.Id)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cc, err := grpc.Dial(addr, grpc.WithTransportCredentials(credentials.NewTLS(&tls.Config{
+		Certificates:       []tls.Certificate{cert},
+		InsecureSkipVerify: true,
+		MinVersion:         tls.VersionTLS13,
+	})))
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { cc.Close() })
+	return pro.NewCoinClient(cc)
+}
+
+func TestInvalidBlockBansPeer(t *testing.T) {
+	// set up cluster
+	cluster := NewCluster(2)
+	chains := []*blockchain.BlockChain{cluster[0].BlockChain, cluster[1].BlockChain}
+	defer CleanUp(chains)
+	StartCluster(cluster)
+	victim, offender := cluster[0], cluster[1]
+	offender.ConnectToPeer(victim.Address)
+
+	// the mocked block spends a coin that does not exist, which a
+	// block on a fork may do, so one block is not enough for a ban
+	p := offender.PeerDb.Get(victim.Address)
+	if p == nil {
+		t.Fatalf("offender should have peered with victim")
+	}
+	invalid := MockedBlock()
+	invalid.Header.PreviousHash = victim.BlockChain.LastHash
+	if _, err := p.Addr.ForwardBlockRPC(block.EncodeBlock(invalid)); err == nil {
+		t.Errorf("invalid block should have been rejected")
+	}
+	if victim.PeerDb.Get(offender.Address) == nil {
+		t.Errorf("a single invalid block should not have gotten the offender disconnected")
+	}
+
+	// leaving out the sender does not escape the penalty
+	c := victim.Config.PeerConfig
+	client := dialWithoutSender(t, offender, victim.Address)
+	for i := c.OffenseWeights[peer.InvalidBlock]; i < c.BanThreshold; i += c.OffenseWeights[peer.InvalidBlock] {
+		b := MockedBlock()
+		b.Header.PreviousHash = victim.BlockChain.LastHash
+		b.Header.Nonce = i
+		if _, err := client.ForwardBlock(context.Background(), block.EncodeBlock(b)); err == nil {
+			t.Errorf("invalid block should have been rejected")
+		}
+	}
+
+	// the offender should be disconnected and banned
+	if victim.PeerDb.Get(offender.Address) != nil {
+		t.Errorf("offender should have been disconnected")
+	}
+	bans := victim.ListBans()
+	AssertSize(t, len(bans), 1)
+	if len(bans) == 1 && bans[0].Addr != offender.Address {
+		t.Errorf("expected ban for %v, got %v", offender.Address, bans[0].Addr)
+	}
+	offender.ConnectToPeer(victim.Address)
+	if victim.PeerDb.Get(offender.Address) != nil {
+		t.Errorf("banned node should not be able to peer again")
+	}
+
+	// once the ban is cleared, the node may peer again
+	if !victim.ClearBan(offender.Address) {
+		t.Errorf("clearing an existing ban should succeed")
+	}
+	offender.ConnectToPeer(victim.Address)
+	if victim.PeerDb.Get(offender.Address) == nil {
+		t.Errorf("node should be able to peer again after its ban is cleared")
+	}
+}
+
+func TestOrphanBlockNotPenalized(t *testing.T) {
+	// set up cluster
+	cluster := NewCluster(2)
+	chains := []*blockchain.BlockChain{cluster[0].BlockChain, cluster[1].BlockChain}
+	defer CleanUp(chains)
+	StartCluster(cluster)
+	victim, sender := cluster[0], cluster[1]
+	sender.ConnectToPeer(victim.Address)
+
+	// a block on a parent the victim has not seen is rejected,
+	// but the sender may just be further along the chain
+	orphan := MockedBlock()
+	orphan.Header.PreviousHash = "unknown"
+	var rerr *pkg.RejectError
+	if err := victim.ValidateBlock(orphan); !errors.As(err, &rerr) || rerr.Reason != pkg.RejectOrphan {
+		t.Errorf("a block on an unknown parent should have been rejected as an orphan, got %v", err)
+	}
+	c := victim.Config.PeerConfig
+	client := dialWithoutSender(t, sender, victim.Address)
+	for i := uint32(0); i <= c.BanThreshold/c.OffenseWeights[peer.InvalidBlock]; i++ {
+		orphan.Header.Nonce = i
+		if _, err := client.ForwardBlock(context.Background(), block.EncodeBlock(orphan)); err == nil {
+			t.Errorf("an orphan block should not have been accepted")
+		}
+	}
+	if victim.PeerDb.Get(sender.Address) == nil || len(victim.ListBans()) != 0 {
+		t.Errorf("sending orphan blocks should not have gotten the sender banned")
+	}
+	if victim.BlockChain.Length != 1 {
+		t.Errorf("the orphan block should not have been stored")
+	}
+}
+
+func TestUnknownInputsNotPenalized(t *testing.T) {
+	// set up cluster
+	cluster := NewCluster(2)
+	chains := []*blockchain.BlockChain{cluster[0].BlockChain, cluster[1].BlockChain}
+	defer CleanUp(chains)
+	StartCluster(cluster)
+	victim, sender := cluster[0], cluster[1]
+	sender.ConnectToPeer(victim.Address)
+	p := sender.PeerDb.Get(victim.Address)
+	if p == nil {
+		t.Fatalf("sender should have peered with victim")
+	}
+
+	// a child relayed before its parent is not the sender's fault
+	child := &block.Transaction{
+		Inputs:  []*block.TransactionInput{{ReferenceTransactionHash: "parent", OutputIndex: 0}},
+		Outputs: []*block.TransactionOutput{{Amount: 1, LockingScript: "payee"}},
+	}
+	if _, err := p.Addr.ForwardTransactionRPC(block.EncodeTransaction(child)); err == nil {
+		t.Errorf("a transaction with unknown inputs should have been rejected")
+	}
+	if score := victim.PeerDb.Get(sender.Address).Misbehavior.Load(); score != 0 {
+		t.Errorf("unknown inputs should not have raised the sender's score, got %v", score)
+	}
+
+	// but one that does not unlock its coin is
+	genesis := victim.BlockChain.LastBlock
+	unsigned := &block.Transaction{
+		Inputs:  []*block.TransactionInput{{ReferenceTransactionHash: genesis.Transactions[0].Hash(), OutputIndex: 0}},
+		Outputs: []*block.TransactionOutput{{Amount: 1, LockingScript: "payee"}},
+	}
+	if _, err := p.Addr.ForwardTransactionRPC(block.EncodeTransaction(unsigned)); err == nil {
+		t.Errorf("an unsigned transaction should have been rejected")
+	}
+	if score := victim.PeerDb.Get(sender.Address).Misbehavior.Load(); score != victim.Config.PeerConfig.OffenseWeights[peer.InvalidTransaction] {
+		t.Errorf("a bad signature should have raised the sender's score, got %v", score)
+	}
+}
+
+func TestPeerRateLimit(t *testing.T) {
+	// set up cluster
+	cluster := NewCluster(2)
+	chains := []*blockchain.BlockChain{cluster[0].BlockChain, cluster[1].BlockChain}
+	defer CleanUp(chains)
+	limit := cluster[0].Config.PeerConfig.RateLimits["GetAddresses"]
+	StartCluster(cluster)
+	cluster[1].ConnectToPeer(cluster[0].Address)
+	p := cluster[1].PeerDb.Get(cluster[0].Address)
+	if p == nil {
+		t.Fatalf("node 1 should have peered with node 0")
+	}
+
+	// requests past the burst should be refused
+	refused := 0
+	for i := 0; i < limit.Burst+3; i++ {
+		if _, err := p.Addr.GetAddressesRPC(&pro.Empty{}); err != nil {
+			refused++
+		}
+	}
+	if refused != 3 {
+		t.Errorf("expected 3 refused requests, got %v", refused)
+	}
+	stats := cluster[0].PeerDb.Get(cluster[1].Address).Stats()
+	if stats.Limits["GetAddresses"].Dropped != 3 {
+		t.Errorf("peer stats should show 3 dropped requests, got %v", stats.Limits["GetAddresses"].Dropped)
+	}
+}
+
+func TestUnknownCallerRateLimit(t *testing.T) {
+	// set up cluster
+	cluster := NewCluster(2)
+	chains := []*blockchain.BlockChain{cluster[0].BlockChain, cluster[1].BlockChain}
+	defer CleanUp(chains)
+	limit := cluster[0].Config.PeerConfig.RateLimits["GetAddresses"]
+	StartCluster(cluster)
+
+	// a caller that is not a peer and does not say who it is
+	// is still held to the limits
+	client := dialWithoutSender(t, cluster[1], cluster[0].Address)
+	refused := 0
+	for i := 0; i < limit.Burst+3; i++ {
+		if _, err := client.GetAddresses(context.Background(), &pro.Empty{}); err != nil {
+			refused++
+		}
+	}
+	if refused != 3 {
+		t.Errorf("expected 3 refused requests, got %v", refused)
+	}
+
+	// other callers have limits of their own
+	other := dialWithoutSender(t, cluster[0], cluster[0].Address)
+	if _, err := other.GetAddresses(context.Background(), &pro.Empty{}); err != nil {
+		t.Errorf("another caller should not have been limited, got %v", err)
+	}
+}
+
+func TestTooManyAddressesRejected(t *testing.T) {
+	// set up cluster
+	cluster := NewCluster(2)
+	chains := []*blockchain.BlockChain{cluster[0].BlockChain, cluster[1].BlockChain}
+	defer CleanUp(chains)
+	StartCluster(cluster)
+	cluster[1].ConnectToPeer(cluster[0].Address)
+	p := cluster[1].PeerDb.Get(cluster[0].Address)
+	if p == nil {
+		t.Fatalf("node 1 should have peered with node 0")
+	}
+
+	addrs := make([]*pro.Address, cluster[0].Config.PeerConfig.MaxAddrsPerMessage+1)
+	for i := range addrs {
+		addrs[i] = &pro.Address{Addr: fmt.Sprintf("10.0.%v.%v:8000", i/250, i%250)}
+	}
+	if _, err := p.Addr.SendAddressesRPC(&pro.Addresses{Addrs: addrs}); err == nil {
+		t.Errorf("message with too many addresses should have been rejected")
+	}
+	if cluster[0].AddressDB.Get(addrs[0].Addr) != nil {
+		t.Errorf("addresses from a rejected message should not be stored")
+	}
+}
+
+func TestInsecurePeerRateLimit(t *testing.T) {
+	var nodes []*pkg.Node
+	for i, conf := range []*pkg.Config{GenesisConfig(GetFreePort()), pkg.DefaultConfig(GetFreePort())} {
+		conf = setNodeConfig(conf, i)
+		conf.AddressConfig.SecureTransport = false
+		nodes = append(nodes, pkg.New(conf))
+	}
+	defer CleanUp([]*blockchain.BlockChain{nodes[0].BlockChain, nodes[1].BlockChain})
+	StartCluster(nodes)
+	victim, sender := nodes[0], nodes[1]
+	sender.ConnectToPeer(victim.Address)
+	p := victim.PeerDb.Get(sender.Address)
+	if p == nil {
+		t.Fatalf("sender should have peered with victim")
+	}
+
+	// without TLS, the peer's host is resolved once, when it is
+	// added, and requests are matched against it
+	if !p.OnHost("127.0.0.1") {
+		t.Fatalf("the peer's host should have been resolved when it was added, got %v", p.IPs)
+	}
+	cc, err := grpc.Dial(victim.Address, grpc.WithInsecure())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer cc.Close()
+	client := pro.NewCoinClient(cc)
+	limit := victim.Config.PeerConfig.RateLimits["GetAddresses"]
+	for i := 0; i < limit.Burst+3; i++ {
+		_, _ = client.GetAddresses(context.Background(), &pro.Empty{})
+	}
+	if dropped := p.Stats().Limits["GetAddresses"].Dropped; dropped != 3 {
+		t.Errorf("requests from the peer's host should count against its limit, got %v dropped", dropped)
+	}
+}
@@ -2,6 +2,7 @@ package test
 
 import (
 	"Coin/pkg/block"
+	"Coin/pkg/blockchain"
 	"Coin/pkg/blockchain/blockinfodatabase"
 	"Coin/pkg/blockchain/chainwriter"
 	"Coin/pkg/wallet"
@@ -106,7 +107,8 @@ func GenesisBlock() *block.Block {
 
 // MakeBlockFromPrev creates a new Block from an existing Block,
 // using the old Block's TransactionOutputs as TransactionInputs
-// for the new Transaction.
+// for the new Transaction. The old Block's TransactionOutputs
+// must be locked to the genesis public key, as the new ones are.
 func MakeBlockFromPrev(b *block.Block) *block.Block {
 	newHeader := &block.Header{
 		Version:          0,
@@ -129,7 +131,7 @@ func MakeBlockFromPrev(b *block.Block) *block.Block {
 			}
 			txo1 := &block.TransactionOutput{
 				Amount:        txo.Amount / 2,
-				LockingScript: "",
+				LockingScript: blockchain.GENPK,
 			}
 			tx1 := &block.Transaction{
 				Version:  uint32(i),
@@ -137,6 +139,7 @@ func MakeBlockFromPrev(b *block.Block) *block.Block {
 				Outputs:  []*block.TransactionOutput{txo1},
 				LockTime: 0,
 			}
+			SignGenesisInputs(tx1)
 			transactions = append(transactions, tx1)
 		}
 	}
@@ -0,0 +1,256 @@
+package test
+
+import (
+	"Coin/pkg"
+	"Coin/pkg/block"
+	"Coin/pkg/blockchain"
+	"Coin/pkg/notify"
+	"Coin/pkg/script"
+	"bufio"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+)
+
+// nextEvent waits for an event of a subscription.
+func nextEvent(t *testing.T, events <-chan *notify.Event) *notify.Event {
+	select {
+	case e, ok := <-events:
+		if !ok {
+			t.Fatalf("subscription ended early")
+		}
+		return e
+	case <-time.After(5 * time.Second):
+		t.Fatalf("no event arrived")
+		return nil
+	}
+}
+
+// streamEvents reads the Server-Sent Events of a gateway's event
+// stream, and returns them as they arrive, with the names that
+// they were sent under.
+func streamEvents(t *testing.T, url string) (<-chan *notify.Event, <-chan string) {
+	resp, err := http.Get(url)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("%v should have streamed, got %v", url, resp.StatusCode)
+	}
+	t.Cleanup(func() { resp.Body.Close() })
+	events := make(chan *notify.Event, 100)
+	names := make(chan string, 100)
+	go func() {
+		defer close(events)
+		scanner := bufio.NewScanner(resp.Body)
+		for scanner.Scan() {
+			line := scanner.Text()
+			if strings.HasPrefix(line, "event: ") {
+				names <- strings.TrimPrefix(line, "event: ")
+			} else if strings.HasPrefix(line, "data: ") {
+				e := &notify.Event{}
+				if err := json.Unmarshal([]byte(strings.TrimPrefix(line, "data: ")), e); err == nil {
+					events <- e
+				}
+			}
+		}
+	}()
+	return events, names
+}
+
+func TestNotifyHub(t *testing.T) {
+	h := notify.NewHub(&notify.Config{BufferSize: 2, MaxSubscribers: 2})
+	all, _ := h.Subscribe(nil)
+	keyed, _ := h.Subscribe(&notify.Filter{
+		Types:      map[notify.Type]bool{notify.Tip: true, notify.Receive: true},
+		PublicKeys: map[string]bool{"aa": true},
+	})
+	if _, err := h.Subscribe(nil); err != notify.ErrTooManySubscribers {
+		t.Errorf("a third subscriber should have been refused, got %v", err)
+	}
+
+	h.Publish(
+		&notify.Event{Type: notify.Receive, PublicKeys: []string{"bb"}},
+		&notify.Event{Type: notify.Tip},
+	)
+	if e := nextEvent(t, keyed.Events()); e.Type != notify.Tip || e.Seq != 2 {
+		t.Errorf("only the tip should have passed the filter, got %v", e)
+	}
+	if e := nextEvent(t, all.Events()); e.Seq != 1 {
+		t.Errorf("events should arrive in order, got %v", e)
+	}
+
+	// all has one event waiting, so two more overflow its buffer
+	h.Publish(&notify.Event{Type: notify.Spend}, &notify.Event{Type: notify.Spend})
+	nextEvent(t, all.Events())
+	nextEvent(t, all.Events())
+	if _, ok := <-all.Events(); ok || !all.Lagged() {
+		t.Errorf("a subscriber that fell behind should have been dropped")
+	}
+	if keyed.Lagged() || h.Subscribers() != 1 {
+		t.Errorf("only the lagging subscriber should have been dropped")
+	}
+	h.Close()
+	if _, ok := <-keyed.Events(); ok {
+		t.Errorf("closing the hub should end every subscription")
+	}
+}
+
+func TestNotifyEventStream(t *testing.T) {
+	// the genesis output must be worth something to be spent
+	conf := setNodeConfig(GenesisConfig(GetFreePort()), 0)
+	conf.ChainConfig.InitialSubsidy = 50
+	conf.GatewayAddr = fmt.Sprintf("127.0.0.1:%v", GetFreePort())
+	n := pkg.New(conf)
+	defer CleanUp([]*blockchain.BlockChain{n.BlockChain})
+	StartCluster([]*pkg.Node{n})
+	url := "http://" + n.Config.GatewayAddr + "/v1/events"
+
+	for _, query := range []string{"?types=tip,weather", "?public_keys=zz"} {
+		if resp, err := http.Get(url + query); err != nil || resp.StatusCode != http.StatusBadRequest {
+			t.Errorf("%v should have been rejected", query)
+		} else {
+			resp.Body.Close()
+		}
+	}
+
+	genPK := hex.EncodeToString([]byte(blockchain.GENPK))
+	payee := hex.EncodeToString([]byte("payee"))
+	events, names := streamEvents(t, url+"?public_keys="+genPK+","+payee)
+	// coins to anyone else are left out
+	other, _ := streamEvents(t, url+"?types=receive&public_keys="+hex.EncodeToString([]byte("other")))
+
+	genesis := n.BlockChain.LastBlock
+	tx := &block.Transaction{
+		Inputs:  []*block.TransactionInput{{ReferenceTransactionHash: genesis.Transactions[0].Hash(), OutputIndex: 0}},
+		Outputs: []*block.TransactionOutput{{Amount: 40, LockingScript: "payee"}},
+	}
+	SignGenesisInputs(tx)
+	if err := n.SubmitTransaction(tx); err != nil {
+		t.Fatalf("transaction should have been accepted: %v", err)
+	}
+	e := nextEvent(t, events)
+	if e.Type != notify.MempoolAdd || e.TransactionHash != tx.Hash() || e.PublicKeys[0] != payee {
+		t.Errorf("expected the transaction to enter the mempool, got %+v", e)
+	}
+	if name := <-names; name != string(notify.MempoolAdd) {
+		t.Errorf("events should be named after their type, got %v", name)
+	}
+
+	b := &block.Block{
+		Header:       &block.Header{PreviousHash: genesis.Hash(), Timestamp: 1},
+		Transactions: []*block.Transaction{tx},
+	}
+	n.BlockChain.HandleBlock(b)
+	n.Miner.HandleBlock(b)
+
+	want := []struct {
+		typ  notify.Type
+		pk   string
+		coin string
+	}{
+		{notify.Tip, "", ""},
+		{notify.Spend, genPK, genesis.Transactions[0].Hash()},
+		{notify.Receive, payee, tx.Hash()},
+		{notify.MempoolRemove, payee, ""},
+	}
+	for _, w := range want {
+		e := nextEvent(t, events)
+		if e.Type != w.typ || (w.pk != "" && e.PublicKeys[0] != w.pk) || (w.coin != "" && e.Coin.TransactionHash != w.coin) {
+			t.Errorf("expected a %v event, got %+v", w.typ, e)
+		}
+		if e.Type == notify.Tip && (e.BlockHash != b.Hash() || e.Height != 2) {
+			t.Errorf("the tip should be the new block at height 2, got %+v", e)
+		}
+		if e.Type == notify.MempoolRemove && e.Reason != notify.ReasonMined {
+			t.Errorf("the transaction should have left the mempool because it was mined, got %+v", e)
+		}
+	}
+	select {
+	case e := <-other:
+		t.Errorf("no coins moved to other, but got %+v", e)
+	default:
+	}
+
+	// shutting down ends the streams
+	n.Kill()
+	<-n.Done()
+	for range events {
+	}
+}
+
+func TestNotifyReorg(t *testing.T) {
+	n := pkg.New(setNodeConfig(GenesisConfig(GetFreePort()), 0))
+	defer CleanUp([]*blockchain.BlockChain{n.BlockChain})
+	sub, err := n.Events.Subscribe(&notify.Filter{Types: map[notify.Type]bool{notify.Tip: true, notify.Reorg: true}})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	genesis := n.BlockChain.LastHash
+	makeBlock := func(prev string, ts uint32) *block.Block {
+		return &block.Block{
+			Header:       &block.Header{PreviousHash: prev, Timestamp: ts},
+			Transactions: []*block.Transaction{{Outputs: []*block.TransactionOutput{{Amount: 1, LockingScript: "miner"}}}},
+		}
+	}
+	a := makeBlock(genesis, 1)
+	b1 := makeBlock(genesis, 2)
+	b2 := makeBlock(b1.Hash(), 3)
+	for _, b := range []*block.Block{a, b1, b2} {
+		n.BlockChain.HandleBlock(b)
+	}
+
+	if e := nextEvent(t, sub.Events()); e.Type != notify.Tip || e.BlockHash != a.Hash() {
+		t.Errorf("expected a to become the tip, got %+v", e)
+	}
+	e := nextEvent(t, sub.Events())
+	if e.Type != notify.Reorg || e.BlockHash != b2.Hash() || e.Height != 3 {
+		t.Fatalf("expected a reorg to b2 at height 3, got %+v", e)
+	}
+	if len(e.Disconnected) != 1 || e.Disconnected[0] != a.Hash() {
+		t.Errorf("the reorg should have disconnected a, got %v", e.Disconnected)
+	}
+	if len(e.Connected) != 2 || e.Connected[0] != b1.Hash() || e.Connected[1] != b2.Hash() {
+		t.Errorf("the reorg should have connected b1 and b2, got %v", e.Connected)
+	}
+}
+
+func TestNotifyScriptLockedCoins(t *testing.T) {
+	n := pkg.New(setNodeConfig(GenesisConfig(GetFreePort()), 0))
+	defer CleanUp([]*blockchain.BlockChain{n.BlockChain})
+	alice, bob := newKey(t), newKey(t)
+	sub, err := n.Events.Subscribe(&notify.Filter{
+		Types:      map[notify.Type]bool{notify.Receive: true},
+		PublicKeys: map[string]bool{hex.EncodeToString(alice.GetPublicKeyBytes()): true},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// coins locked by scripts that pay alice concern her key
+	coinbase := &block.Transaction{Outputs: []*block.TransactionOutput{
+		{Amount: 1, LockingScript: script.PayToPubKey(alice.GetPublicKeyBytes())},
+		{Amount: 2, LockingScript: script.PayToPubKeyHash(bob.GetPublicKeyBytes())},
+		{Amount: 3, LockingScript: script.PayToPubKeyHash(alice.GetPublicKeyBytes())},
+	}}
+	n.BlockChain.HandleBlock(&block.Block{
+		Header:       &block.Header{PreviousHash: n.BlockChain.LastHash, Timestamp: 1},
+		Transactions: []*block.Transaction{coinbase},
+	})
+	for _, amount := range []uint32{1, 3} {
+		e := nextEvent(t, sub.Events())
+		if e.Coin == nil || e.Coin.Amount != amount || len(e.Scripts) != 1 {
+			t.Errorf("expected alice to receive %v, got %+v", amount, e)
+		}
+	}
+	select {
+	case e := <-sub.Events():
+		t.Errorf("bob's coin should not have been sent to alice, got %+v", e)
+	case <-time.After(100 * time.Millisecond):
+	}
+}
@@ -0,0 +1,132 @@
+package test
+
+import (
+	"Coin/pkg"
+	"Coin/pkg/blockchain"
+	"fmt"
+	"net"
+	"testing"
+	"time"
+)
+
+func TestResumeCatchesUp(t *testing.T) {
+	// set up cluster
+	cluster := NewCluster(2)
+	chains := []*blockchain.BlockChain{cluster[0].BlockChain, cluster[1].BlockChain}
+	defer CleanUp(chains)
+	StartCluster(cluster)
+	cluster[1].ConnectToPeer(cluster[0].Address)
+	cluster[1].StartMiner()
+
+	cluster[1].PauseNetwork()
+	if !cluster[1].Paused.Load() || cluster[1].Miner.Active.Load() {
+		t.Fatalf("paused node should not be mining")
+	}
+
+	// the network moves on without the paused node
+	b := cluster[0].BlockChain.LastBlock
+	for i := 0; i < 3; i++ {
+		b = MakeBlockFromPrev(b)
+		cluster[0].BlockChain.HandleBlock(b)
+	}
+	time.Sleep(100 * time.Millisecond)
+	if cluster[1].BlockChain.Length == cluster[0].BlockChain.Length {
+		t.Fatalf("paused node should have missed the new blocks")
+	}
+
+	if err := cluster[1].ResumeNetwork(); err != nil {
+		t.Fatalf("resumed node should have caught up: %v", err)
+	}
+	CheckMainChains(t, cluster)
+	if cluster[1].Paused.Load() || !cluster[1].Miner.Active.Load() {
+		t.Errorf("resumed node should be mining again")
+	}
+	if p := cluster[1].PeerDb.Get(cluster[0].Address); p == nil || p.BestHeight() != cluster[0].BlockChain.Length {
+		t.Errorf("resumed node should have learned its peer's best height")
+	}
+}
+
+func TestResumeWhenPortTaken(t *testing.T) {
+	n := pkg.New(setNodeConfig(GenesisConfig(GetFreePort()), 0))
+	defer CleanUp([]*blockchain.BlockChain{n.BlockChain})
+	n.Start()
+	defer n.Kill()
+	time.Sleep(100 * time.Millisecond)
+	n.PauseNetwork()
+
+	// something else takes the port while the node is paused
+	lis, err := net.Listen("tcp", fmt.Sprintf(":%v", n.Config.Port))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := n.ResumeNetwork(); err == nil {
+		t.Errorf("resuming should have failed while the port is taken")
+	}
+	if !n.Paused.Load() {
+		t.Errorf("node should have stayed paused")
+	}
+
+	// once the port is free again, the node resumes
+	lis.Close()
+	if err := n.ResumeNetwork(); err != nil {
+		t.Errorf("node should have resumed, got %v", err)
+	}
+	if n.Paused.Load() {
+		t.Errorf("node should have resumed")
+	}
+}
+
+func TestBootstrapFromSeveralPeers(t *testing.T) {
+	// set up cluster, where every peer is ahead of node 0 by
+	// a different amount of blocks
+	cluster := NewCluster(4)
+	var chains []*blockchain.BlockChain
+	for _, n := range cluster {
+		chains = append(chains, n.BlockChain)
+	}
+	defer CleanUp(chains)
+	for i := 1; i < len(cluster); i++ {
+		b := cluster[i].BlockChain.LastBlock
+		for j := 0; j < i; j++ {
+			b = MakeBlockFromPrev(b)
+			cluster[i].BlockChain.HandleBlock(b)
+		}
+	}
+	StartCluster(cluster)
+	defer func() {
+		for _, n := range cluster {
+			n.Kill()
+		}
+	}()
+	for i := 1; i < len(cluster); i++ {
+		cluster[0].ConnectToPeer(cluster[i].Address)
+	}
+
+	// every peer answers at once, and node 0 follows the
+	// longest chain
+	if err := cluster[0].Bootstrap(); err != nil {
+		t.Fatalf("node should have bootstrapped: %v", err)
+	}
+	CheckEqualBlocks(t, cluster[0].BlockChain.List(), cluster[3].BlockChain.List())
+}
+
+func TestMinerCallsWithoutMiner(t *testing.T) {
+	n := pkg.New(setNodeConfig(pkg.NoMinerConfig(GetFreePort()), 0))
+	defer CleanUp([]*blockchain.BlockChain{n.BlockChain})
+	n.Start()
+	defer n.Kill()
+	time.Sleep(100 * time.Millisecond)
+
+	// asking a node without a miner to mine is harmless, even
+	// across a pause
+	n.StartMiner()
+	n.PauseNetwork()
+	n.StartMiner()
+	if err := n.ResumeNetwork(); err != nil {
+		t.Fatalf("node should have resumed, got %v", err)
+	}
+	n.StopMiner()
+	if n.Miner != nil && n.Miner.Active.Load() {
+		t.Errorf("node without a miner should not be mining")
+	}
+}
@@ -0,0 +1,39 @@
+package test
+
+import (
+	"Coin/pkg/blockchain"
+	"testing"
+	"time"
+)
+
+func TestPingEvictsUnresponsivePeer(t *testing.T) {
+	// set up cluster
+	cluster := NewCluster(2)
+	chains := []*blockchain.BlockChain{cluster[0].BlockChain, cluster[1].BlockChain}
+	defer CleanUp(chains)
+	for _, n := range cluster {
+		n.Config.PeerConfig.PingInterval = 100 * time.Millisecond
+	}
+	StartCluster(cluster)
+	cluster[0].ConnectToPeer(cluster[1].Address)
+
+	// pings should be answered and timed
+	time.Sleep(300 * time.Millisecond)
+	p := cluster[0].PeerDb.Get(cluster[1].Address)
+	if p == nil {
+		t.Fatalf("node 0 should have peered with node 1")
+	}
+	if p.RTT.Load() == 0 {
+		t.Errorf("node 0 should have recorded the round trip time to node 1")
+	}
+	if since := time.Now().Unix() - int64(p.Addr.LastSeen); since < 0 || since > 1 {
+		t.Errorf("last seen should be the current unix time, was %v seconds off", since)
+	}
+
+	// once node 1 stops answering, node 0 should drop it
+	cluster[1].Kill()
+	time.Sleep(time.Duration(cluster[0].Config.PeerConfig.MaxMissedPings+2) * 100 * time.Millisecond)
+	if cluster[0].PeerDb.Get(cluster[1].Address) != nil {
+		t.Errorf("node 0 should have evicted unresponsive node 1")
+	}
+}
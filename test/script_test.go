@@ -0,0 +1,254 @@
+package test
+
+import (
+	"Coin/pkg"
+	"Coin/pkg/block"
+	"Coin/pkg/blockchain"
+	"Coin/pkg/id"
+	"Coin/pkg/script"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"strings"
+	"testing"
+)
+
+// newKey returns a new identity, for scripts to lock coins to.
+func newKey(t *testing.T) *id.SimpleID {
+	k, err := id.CreateSimpleID()
+	if err != nil {
+		t.Fatal(err)
+	}
+	return k
+}
+
+// sign returns a key's signature of an input of a transaction.
+func sign(t *testing.T, k *id.SimpleID, tx *block.Transaction, index int) []byte {
+	sig, err := script.Sign(k.GetPrivateKey(), tx, index)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return sig
+}
+
+// spending returns a transaction whose one input spends a coin,
+// locked until a height.
+func spending(lockTime uint32) *block.Transaction {
+	return &block.Transaction{
+		Inputs:   []*block.TransactionInput{{ReferenceTransactionHash: "coin", OutputIndex: 0}},
+		Outputs:  []*block.TransactionOutput{{Amount: 1, LockingScript: "payee"}},
+		LockTime: lockTime,
+	}
+}
+
+func TestScriptStandard(t *testing.T) {
+	alice, bob, carol := newKey(t), newKey(t), newKey(t)
+	secret := []byte("secret")
+	hash := sha256.Sum256(secret)
+	multiSig, err := script.MultiSig(2, alice.GetPublicKeyBytes(), bob.GetPublicKeyBytes(), carol.GetPublicKeyBytes())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tx := spending(10)
+	other := spending(11)
+	tests := map[string]struct {
+		unlocking string
+		locking   string
+		ok        bool
+	}{
+		"pay to pubkey": {
+			script.UnlockPubKey(sign(t, alice, tx, 0)),
+			script.PayToPubKey(alice.GetPublicKeyBytes()), true},
+		"pay to pubkey by someone else": {
+			script.UnlockPubKey(sign(t, bob, tx, 0)),
+			script.PayToPubKey(alice.GetPublicKeyBytes()), false},
+		"pay to pubkey signed for another transaction": {
+			script.UnlockPubKey(sign(t, alice, other, 0)),
+			script.PayToPubKey(alice.GetPublicKeyBytes()), false},
+		"pay to pubkey signed for another input": {
+			script.UnlockPubKey(sign(t, alice, tx, 1)),
+			script.PayToPubKey(alice.GetPublicKeyBytes()), false},
+		"pay to pubkey hash": {
+			script.UnlockPubKeyHash(sign(t, alice, tx, 0), alice.GetPublicKeyBytes()),
+			script.PayToPubKeyHash(alice.GetPublicKeyBytes()), true},
+		"pay to pubkey hash with the wrong key": {
+			script.UnlockPubKeyHash(sign(t, bob, tx, 0), bob.GetPublicKeyBytes()),
+			script.PayToPubKeyHash(alice.GetPublicKeyBytes()), false},
+		"multisig": {
+			script.UnlockMultiSig(sign(t, alice, tx, 0), sign(t, carol, tx, 0)),
+			multiSig, true},
+		"multisig out of order": {
+			script.UnlockMultiSig(sign(t, carol, tx, 0), sign(t, alice, tx, 0)),
+			multiSig, false},
+		"multisig with the same signature twice": {
+			script.UnlockMultiSig(sign(t, bob, tx, 0), sign(t, bob, tx, 0)),
+			multiSig, false},
+		"multisig with too few signatures": {
+			script.UnlockMultiSig(sign(t, bob, tx, 0)),
+			multiSig, false},
+		"hash lock": {
+			script.UnlockHashLock(secret, nil),
+			script.HashLock(hash[:], nil), true},
+		"hash lock with the wrong secret": {
+			script.UnlockHashLock([]byte("guess"), nil),
+			script.HashLock(hash[:], nil), false},
+		"signed hash lock": {
+			script.UnlockHashLock(secret, sign(t, bob, tx, 0)),
+			script.HashLock(hash[:], bob.GetPublicKeyBytes()), true},
+		"signed hash lock without the signature": {
+			script.UnlockHashLock(secret, nil),
+			script.HashLock(hash[:], bob.GetPublicKeyBytes()), false},
+		"time lock": {
+			script.UnlockPubKey(sign(t, alice, tx, 0)),
+			script.TimeLock(10, alice.GetPublicKeyBytes()), true},
+		"time lock too early": {
+			script.UnlockPubKey(sign(t, alice, tx, 0)),
+			script.TimeLock(11, alice.GetPublicKeyBytes()), false},
+	}
+	for name, test := range tests {
+		err := script.Run(test.unlocking, test.locking, script.NewTxChecker(tx, 0))
+		if test.ok && err != nil {
+			t.Errorf("%v: should have unlocked, got %v", name, err)
+		} else if !test.ok && err == nil {
+			t.Errorf("%v: should not have unlocked", name)
+		}
+	}
+}
+
+func TestScriptRejects(t *testing.T) {
+	tx := spending(0)
+	one := script.NewBuilder().AddOp(script.Op1)
+	tests := map[string]struct {
+		unlocking string
+		locking   string
+		err       string
+	}{
+		"unlocking script that runs ops": {
+			script.NewBuilder().AddOp(script.OpDup).Script(),
+			one.Script(), "may only push"},
+		"unbalanced if": {
+			script.NewBuilder().AddOp(script.Op1).Script(),
+			script.NewBuilder().AddOp(script.OpIf).AddOp(script.Op1).Script(), "OP_ENDIF"},
+		"else without if": {
+			"", script.NewBuilder().AddOp(script.OpElse).Script(), "no OP_IF"},
+		"unknown op": {
+			"", hex.EncodeToString([]byte{script.Version, 0xff}), "0xff"},
+		"truncated push": {
+			"", hex.EncodeToString([]byte{script.Version, 5, 1, 2}), "past the end"},
+		"missing version": {
+			"", "51", "version"},
+		"not hex": {
+			"", "payee", "not hex"},
+		"return": {
+			"", script.NewBuilder().AddOp(script.OpReturn).Script(), "cannot be spent"},
+		"empty stack": {
+			"", script.NewBuilder().AddOp(script.Op1).AddOp(script.OpDrop).Script(), "ended false"},
+		"false": {
+			"", script.NewBuilder().AddInt(0).Script(), "ended false"},
+		"stack underflow": {
+			"", script.NewBuilder().AddOp(script.OpEqual).Script(), "stack is empty"},
+	}
+	for name, test := range tests {
+		unlocking := test.unlocking
+		if unlocking == "" {
+			unlocking = script.NewBuilder().Script()
+		}
+		err := script.Run(unlocking, test.locking, script.NewTxChecker(tx, 0))
+		if err == nil || !strings.Contains(err.Error(), test.err) {
+			t.Errorf("%v: expected an error about %q, got %v", name, test.err, err)
+		}
+	}
+
+	// branches that are not taken are not run
+	branches := script.NewBuilder().AddOp(script.OpNotIf).AddOp(script.OpReturn).
+		AddOp(script.OpElse).AddInt(1000).AddInt(1000).AddOp(script.OpEqual).AddOp(script.OpEndIf).Script()
+	if err := script.Run(one.Script(), branches, script.NewTxChecker(tx, 0)); err != nil {
+		t.Errorf("the OP_ELSE branch should have run, got %v", err)
+	}
+
+	ops := script.NewBuilder().AddOp(script.Op1)
+	for i := 0; i <= script.MaxOps; i++ {
+		ops.AddOp(script.OpNop)
+	}
+	if err := script.Run(script.NewBuilder().Script(), ops.Script(), script.NewTxChecker(tx, 0)); err == nil {
+		t.Errorf("a script that runs too many ops should have failed")
+	}
+}
+
+func TestScriptDisassemble(t *testing.T) {
+	s := script.NewBuilder().AddOp(script.OpDup).AddData([]byte{0xab, 0xcd}).AddInt(-1).AddInt(7).
+		AddInt(300).AddOp(script.OpCheckLockTimeVerify).Script()
+	text, err := script.Disassemble(s)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := "OP_DUP <abcd> OP_1NEGATE OP_7 <2c01> OP_CHECKLOCKTIMEVERIFY"; text != want {
+		t.Errorf("expected %q, got %q", want, text)
+	}
+	if _, err := script.Disassemble(hex.EncodeToString([]byte{script.Version, 0xfe})); err == nil {
+		t.Errorf("a script with an unknown op should not disassemble")
+	}
+}
+
+func TestScriptSpends(t *testing.T) {
+	n := pkg.New(setNodeConfig(GenesisConfig(GetFreePort()), 0))
+	defer CleanUp([]*blockchain.BlockChain{n.BlockChain})
+	alice, bob := newKey(t), newKey(t)
+
+	// coins locked by scripts, made by a block on genesis
+	coinbase := &block.Transaction{Outputs: []*block.TransactionOutput{
+		{Amount: 30, LockingScript: script.PayToPubKeyHash(alice.GetPublicKeyBytes())},
+		{Amount: 20, LockingScript: script.TimeLock(3, bob.GetPublicKeyBytes())},
+	}}
+	n.BlockChain.HandleBlock(&block.Block{
+		Header:       &block.Header{PreviousHash: n.BlockChain.LastHash, Timestamp: 1},
+		Transactions: []*block.Transaction{coinbase},
+	})
+	if n.BlockChain.Length != 2 {
+		t.Fatalf("the block should have been appended")
+	}
+	if balance := n.BlockChain.GetBalance(hex.EncodeToString(alice.GetPublicKeyBytes())); balance != 30 {
+		t.Errorf("coins paid to alice's key hash should count towards her balance, got %v", balance)
+	}
+
+	spend := func(index uint32, lockTime uint32, k *id.SimpleID, unlock func(sig []byte) string) *block.Transaction {
+		tx := &block.Transaction{
+			Inputs:   []*block.TransactionInput{{ReferenceTransactionHash: coinbase.Hash(), OutputIndex: index}},
+			Outputs:  []*block.TransactionOutput{{Amount: 10, LockingScript: "payee"}},
+			LockTime: lockTime,
+		}
+		tx.Inputs[0].UnlockingScript = unlock(sign(t, k, tx, 0))
+		return tx
+	}
+	reason := func(err error) string {
+		var rerr *pkg.RejectError
+		if !errors.As(err, &rerr) {
+			return ""
+		}
+		return rerr.Reason
+	}
+
+	byBob := spend(0, 0, bob, func(sig []byte) string { return script.UnlockPubKeyHash(sig, bob.GetPublicKeyBytes()) })
+	if err := n.SubmitTransaction(byBob); reason(err) != pkg.RejectBadSig {
+		t.Errorf("bob should not have been able to spend alice's coin, got %v", err)
+	}
+	byAlice := spend(0, 0, alice, func(sig []byte) string { return script.UnlockPubKeyHash(sig, alice.GetPublicKeyBytes()) })
+	if err := n.SubmitTransaction(byAlice); err != nil {
+		t.Errorf("alice should have been able to spend her coin, got %v", err)
+	}
+
+	// the next block is at height 3
+	early := spend(1, 2, bob, script.UnlockPubKey)
+	if err := n.SubmitTransaction(early); reason(err) != pkg.RejectBadSig {
+		t.Errorf("the time locked coin should not have been spendable before height 3, got %v", err)
+	}
+	nonFinal := spend(1, 4, bob, script.UnlockPubKey)
+	if err := n.SubmitTransaction(nonFinal); reason(err) != pkg.RejectNonFinal {
+		t.Errorf("a transaction locked until height 4 should not have been accepted yet, got %v", err)
+	}
+	onTime := spend(1, 3, bob, script.UnlockPubKey)
+	if err := n.SubmitTransaction(onTime); err != nil {
+		t.Errorf("the time locked coin should have been spendable at height 3, got %v", err)
+	}
+}
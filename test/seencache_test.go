@@ -0,0 +1,57 @@
+package test
+
+import (
+	"Coin/pkg/utils"
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestSeenCacheIsBounded(t *testing.T) {
+	c := utils.NewSeenCache(10, 0)
+	for i := 0; i < 100; i++ {
+		c.Add(fmt.Sprint(i))
+	}
+	if c.Len() != 10 {
+		t.Errorf("cache should hold 10 objects, holds %v", c.Len())
+	}
+	if c.Contains("0") || !c.Contains("99") {
+		t.Errorf("cache should forget the least recently seen objects")
+	}
+}
+
+func TestSeenCacheExpires(t *testing.T) {
+	c := utils.NewSeenCache(10, 50*time.Millisecond)
+	if !c.Add("tx") || c.Add("tx") {
+		t.Errorf("only the first Add of an object should report it as new")
+	}
+	time.Sleep(100 * time.Millisecond)
+	if c.Contains("tx") {
+		t.Errorf("cache should forget objects after the ttl")
+	}
+}
+
+func TestSeenCacheConcurrentAdd(t *testing.T) {
+	c := utils.NewSeenCache(1000, time.Minute)
+	var wg sync.WaitGroup
+	added := make(chan bool, 100)
+	for i := 0; i < 100; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			added <- c.Add("block")
+		}()
+	}
+	wg.Wait()
+	close(added)
+	count := 0
+	for a := range added {
+		if a {
+			count++
+		}
+	}
+	if count != 1 {
+		t.Errorf("exactly one concurrent Add should report the object as new, %v did", count)
+	}
+}
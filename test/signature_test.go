@@ -0,0 +1,82 @@
+package test
+
+import (
+	"Coin/pkg"
+	"Coin/pkg/block"
+	"Coin/pkg/blockchain"
+	"errors"
+	"testing"
+)
+
+func TestSignatureVerification(t *testing.T) {
+	// the genesis output must be worth something to be spent
+	conf := setNodeConfig(GenesisConfig(GetFreePort()), 0)
+	conf.ChainConfig.InitialSubsidy = 50
+	n := pkg.New(conf)
+	defer CleanUp([]*blockchain.BlockChain{n.BlockChain})
+	genesis := n.BlockChain.LastBlock
+	thief := newKey(t)
+
+	spend := func(amount uint32) *block.Transaction {
+		return &block.Transaction{
+			Inputs:  []*block.TransactionInput{{ReferenceTransactionHash: genesis.Transactions[0].Hash(), OutputIndex: 0}},
+			Outputs: []*block.TransactionOutput{{Amount: amount, LockingScript: "payee"}},
+		}
+	}
+	unsigned := spend(40)
+	stolen := spend(40)
+	stolen.Inputs[0].UnlockingScript, _ = stolen.SignInput(0, thief)
+	// the payee of a signed transaction cannot be changed
+	tampered := spend(40)
+	SignGenesisInputs(tampered)
+	tampered.Outputs[0].LockingScript = "thief"
+	// nor can the signature of one transaction unlock another
+	replayed := spend(30)
+	replayed.Inputs[0].UnlockingScript = tampered.Inputs[0].UnlockingScript
+
+	for name, tx := range map[string]*block.Transaction{
+		"unsigned": unsigned, "stolen": stolen, "tampered": tampered, "replayed": replayed,
+	} {
+		var rerr *pkg.RejectError
+		if err := n.ValidateTransaction(tx); !errors.As(err, &rerr) || rerr.Reason != pkg.RejectBadSig {
+			t.Errorf("%v transaction should have been rejected for its signature, got %v", name, err)
+		}
+		if n.CheckNonOrphanSemantically(tx) {
+			t.Errorf("%v transaction should not unlock the genesis output", name)
+		}
+	}
+
+	// blocks are held to the same signatures
+	bad := &block.Block{
+		Header:       &block.Header{PreviousHash: genesis.Hash(), Timestamp: 1},
+		Transactions: []*block.Transaction{tampered},
+	}
+	var rerr *pkg.RejectError
+	if err := n.ValidateBlock(bad); !errors.As(err, &rerr) || rerr.Reason != pkg.RejectBadSig {
+		t.Errorf("block with a badly signed transaction should have been rejected, got %v", err)
+	}
+	n.BlockChain.HandleBlock(bad)
+	if n.BlockChain.Length != 1 {
+		t.Errorf("block with a badly signed transaction should not have been appended")
+	}
+
+	good := spend(40)
+	SignGenesisInputs(good)
+	if !n.CheckNonOrphanSemantically(good) {
+		t.Errorf("signed transaction should unlock the genesis output")
+	}
+	if err := n.SubmitTransaction(good); err != nil {
+		t.Errorf("signed transaction should have been accepted, got %v", err)
+	}
+	b := &block.Block{
+		Header:       &block.Header{PreviousHash: genesis.Hash(), Timestamp: 2},
+		Transactions: []*block.Transaction{good},
+	}
+	if err := n.ValidateBlock(b); err != nil {
+		t.Errorf("block with a signed transaction should have been valid, got %v", err)
+	}
+	n.BlockChain.HandleBlock(b)
+	if n.BlockChain.Length != 2 {
+		t.Errorf("block with a signed transaction should have been appended")
+	}
+}
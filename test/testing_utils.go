@@ -21,13 +21,15 @@ func setNodeConfig(conf *pkg.Config, i int) *pkg.Config {
 	conf.ChainConfig.BlockInfoDBPath = "blockinfodata" + strconv.Itoa(i)
 	conf.ChainConfig.CoinDBPath = "coindata" + strconv.Itoa(i)
 	conf.ChainConfig.ChainWriterDBPath = "data" + strconv.Itoa(i)
+	conf.PeerConfig.BanListPath = "bandata" + strconv.Itoa(i)
+	conf.AddressDBPath = "addressdata" + strconv.Itoa(i)
 	return conf
 }
 
 // CleanUp is used to clean up testing side effects, where num is
 // the number of blockchains (which create directories)
 func CleanUp(chains []*blockchain.BlockChain) {
-	paths := []string{"coindata", "blockinfodata", "data"}
+	paths := []string{"coindata", "blockinfodata", "data", "bandata", "addressdata"}
 	for i, chain := range chains {
 		// manually close the levelDBs
 		chain.BlockInfoDB.Close()
@@ -59,6 +61,15 @@ func GenesisConfig(port int) *pkg.Config {
 	return c
 }
 
+// SignGenesisInputs signs each input of a transaction as the owner
+// of the genesis output, which is also who MakeBlockFromPrev pays.
+func SignGenesisInputs(tx *block.Transaction) {
+	genesisID, _ := id.LoadInSmplID(blockchain.GENPK, blockchain.GENPVK)
+	for i, txi := range tx.Inputs {
+		txi.UnlockingScript, _ = tx.SignInput(i, genesisID)
+	}
+}
+
 // NewCluster
 // First node is always the genesis node
 func NewCluster(n int) []*pkg.Node {
@@ -108,7 +119,7 @@ func CheckMainChains(t *testing.T, nodes []*pkg.Node) {
 func CheckTransactionSeen(t *testing.T, nodes []*pkg.Node, tx *block.Transaction) {
 	t.Helper()
 	for _, n := range nodes {
-		if _, ok := n.SeenTransactions[tx.Hash()]; !ok {
+		if !n.SeenTransactions.Contains(tx.Hash()) {
 			t.Errorf("Error: node {%v} should have seen transaction {%v}", utils.FmtAddr(n.Address), tx.Hash())
 		}
 	}
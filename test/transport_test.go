@@ -0,0 +1,157 @@
+package test
+
+import (
+	"Coin/pkg/blockchain"
+	"Coin/pkg/id"
+	"Coin/pkg/network"
+	"Coin/pkg/peer"
+	"Coin/pkg/pro"
+	"context"
+	"testing"
+)
+
+func TestSecureHandshakePinsPeers(t *testing.T) {
+	// set up cluster
+	cluster := NewCluster(2)
+	chains := []*blockchain.BlockChain{cluster[0].BlockChain, cluster[1].BlockChain}
+	defer CleanUp(chains)
+	StartCluster(cluster)
+	cluster[0].ConnectToPeer(cluster[1].Address)
+
+	// both nodes should have peered and pinned each other's keys
+	for i, n := range cluster {
+		other := cluster[1-i]
+		if n.PeerDb.Get(other.Address) == nil {
+			t.Errorf("node %v should have peered with node %v", i, 1-i)
+			continue
+		}
+		pk := id.PublicKeyHex(other.Id.GetPublicKey())
+		if n.Conns.PinnedKey(other.Address) != pk {
+			t.Errorf("node %v should have pinned the public key of node %v", i, 1-i)
+		}
+	}
+}
+
+func TestHandshakeRejectsForgedIdentity(t *testing.T) {
+	// set up cluster
+	cluster := NewCluster(3)
+	chains := []*blockchain.BlockChain{cluster[0].BlockChain, cluster[1].BlockChain, cluster[2].BlockChain}
+	defer CleanUp(chains)
+	StartCluster(cluster)
+	honest, target, attacker := cluster[0], cluster[1], cluster[2]
+
+	// the attacker claims to be the honest node, but can only
+	// present its own certificate
+	cc, err := attacker.Conns.Get(target.Address)
+	if err != nil {
+		t.Fatalf("attacker could not dial target: %v", err)
+	}
+	_, err = pro.NewCoinClient(cc).Version(context.Background(), &pro.VersionRequest{
+		Version:     uint32(target.Config.Version),
+		AddrYou:     target.Address,
+		AddrMe:      honest.Address,
+		PublicKey:   id.PublicKeyHex(honest.Id.GetPublicKey()),
+		Magic:       target.Config.Network.Magic,
+		GenesisHash: target.Config.Network.GenesisHash(),
+	})
+	if err == nil {
+		t.Errorf("handshake with a forged identity should have been rejected")
+	}
+	if target.PeerDb.Get(honest.Address) != nil {
+		t.Errorf("target should not have peered with the forged address")
+	}
+}
+
+func TestHandshakeDoesNotPinClaimedAddress(t *testing.T) {
+	// set up cluster
+	cluster := NewCluster(3)
+	chains := []*blockchain.BlockChain{cluster[0].BlockChain, cluster[1].BlockChain, cluster[2].BlockChain}
+	defer CleanUp(chains)
+	StartCluster(cluster)
+	honest, target, attacker := cluster[0], cluster[1], cluster[2]
+
+	// the attacker proves its own key, but claims the honest
+	// node's address before the honest node ever connects
+	cc, err := attacker.Conns.Get(target.Address)
+	if err != nil {
+		t.Fatalf("attacker could not dial target: %v", err)
+	}
+	ack, err := pro.NewCoinClient(cc).Version(context.Background(), &pro.VersionRequest{
+		Version:     uint32(target.Config.Version),
+		AddrYou:     target.Address,
+		AddrMe:      honest.Address,
+		PublicKey:   id.PublicKeyHex(attacker.Id.GetPublicKey()),
+		Magic:       target.Config.Network.Magic,
+		GenesisHash: target.Config.Network.GenesisHash(),
+	})
+	if err == nil && ack.Accepted {
+		t.Errorf("a node claiming an address it does not hold should not have been accepted")
+	}
+	if pk := target.Conns.PinnedKey(honest.Address); pk != id.PublicKeyHex(honest.Id.GetPublicKey()) {
+		t.Errorf("the address should only have been pinned to the key of the node that holds it, got %q", pk)
+	}
+
+	// so the honest node can still peer from its own address
+	if !honest.ConnectToPeer(target.Address) {
+		t.Errorf("the honest node should have been able to peer with target")
+	}
+	if p := target.PeerDb.Get(honest.Address); p == nil || p.Addr.PublicKey != id.PublicKeyHex(honest.Id.GetPublicKey()) {
+		t.Errorf("target should have peered with the honest node")
+	}
+}
+
+func TestHandshakeExchangesCapabilities(t *testing.T) {
+	// set up cluster
+	cluster := NewCluster(2)
+	chains := []*blockchain.BlockChain{cluster[0].BlockChain, cluster[1].BlockChain}
+	defer CleanUp(chains)
+	StartCluster(cluster)
+	cluster[0].ConnectToPeer(cluster[1].Address)
+
+	p := cluster[0].PeerDb.Get(cluster[1].Address)
+	if p == nil {
+		t.Fatalf("node 0 should have peered with node 1")
+	}
+	if p.Services != cluster[1].Services() || !p.Services.Has(peer.ServiceFullChain) {
+		t.Errorf("node 0 should know node 1's services, got %v", p.Services)
+	}
+	if p.UserAgent != cluster[1].Config.UserAgent {
+		t.Errorf("node 0 should know node 1's user agent, got %v", p.UserAgent)
+	}
+	if p.BestHeight() != cluster[1].BlockChain.Length || p.BestHash() != cluster[1].BlockChain.LastHash {
+		t.Errorf("node 0 should know the top of node 1's chain")
+	}
+}
+
+func TestHandshakeRejectsIncompatibleVersion(t *testing.T) {
+	// set up cluster
+	cluster := NewCluster(2)
+	chains := []*blockchain.BlockChain{cluster[0].BlockChain, cluster[1].BlockChain}
+	defer CleanUp(chains)
+	cluster[1].Config.Version = 3
+	cluster[1].Config.MinVersion = 2
+	StartCluster(cluster)
+	cluster[0].ConnectToPeer(cluster[1].Address)
+
+	if cluster[0].PeerDb.Get(cluster[1].Address) != nil || cluster[1].PeerDb.Get(cluster[0].Address) != nil {
+		t.Errorf("nodes without a common protocol version should not peer")
+	}
+}
+
+func TestHandshakeRejectsOtherNetwork(t *testing.T) {
+	// set up cluster
+	cluster := NewCluster(2)
+	chains := []*blockchain.BlockChain{cluster[0].BlockChain, cluster[1].BlockChain}
+	defer CleanUp(chains)
+	// node 1 claims to be on testnet, but keeps its chain
+	cluster[1].Config.Network = network.Testnet
+	StartCluster(cluster)
+	cluster[0].ConnectToPeer(cluster[1].Address)
+
+	if cluster[0].PeerDb.Get(cluster[1].Address) != nil || cluster[1].PeerDb.Get(cluster[0].Address) != nil {
+		t.Errorf("nodes on different networks should not peer")
+	}
+	if network.Mainnet.GenesisHash() == network.Testnet.GenesisHash() {
+		t.Errorf("networks should have different genesis blocks")
+	}
+}